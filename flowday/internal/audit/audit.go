@@ -0,0 +1,23 @@
+package audit
+
+import (
+	"log"
+
+	"flowday/internal/db"
+	"flowday/internal/models"
+)
+
+const EventLoginLockout = "login_lockout"
+
+// Record stores an audit event. Failing to audit must not fail the request
+// that triggered it, so errors are only logged.
+func Record(event string, userID *uint, ip, detail string) {
+	if err := db.DB.Create(&models.AuditEvent{
+		Event:  event,
+		UserID: userID,
+		IP:     ip,
+		Detail: detail,
+	}).Error; err != nil {
+		log.Println("audit record failed:", err)
+	}
+}
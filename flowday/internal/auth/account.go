@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"flowday/internal/db"
+	appErrors "flowday/internal/errors"
+	"flowday/internal/mailer"
+	"flowday/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// reauthWindow is how recently an account without a password must have
+// signed in to confirm a sensitive action without a code.
+const reauthWindow = 5 * time.Minute
+
+// ConfirmIdentity re-checks who is behind a session before something that
+// can't be undone. Accounts with a password re-enter it, and wrong ones
+// count like failed logins. SSO-only accounts give a current TOTP code when
+// 2FA is on; otherwise the session must come from a login within
+// reauthWindow.
+func ConfirmIdentity(userID, sessionID uint, password, code, ip string) error {
+	var user models.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		return appErrors.ErrNotFound
+	}
+
+	if user.Password != "" {
+		account := accountKey(user.Email)
+		if err := checkThrottle(account, ipKey(ip)); err != nil {
+			return err
+		}
+		if !CheckPassword(user.Password, password) {
+			loginFailed(account, &user.ID, ip)
+			return appErrors.ErrInvalidCredentials
+		}
+		attempts.Reset(account)
+		return nil
+	}
+
+	if user.TOTPEnabledAt != nil {
+		key := mfaKey(user.ID)
+		if err := checkThrottle(key, ipKey(ip)); err != nil {
+			return err
+		}
+		if err := checkTOTP(&user, strings.TrimSpace(code)); err != nil {
+			recordFailure(key, settings.LoginFreeAttempts, &user.ID, ip)
+			recordFailure(ipKey(ip), settings.LoginFreeAttempts*ipAttemptFactor, nil, ip)
+			return err
+		}
+		attempts.Reset(key)
+		return nil
+	}
+
+	var session models.Session
+	if sessionID == 0 || db.DB.Where("id = ? AND user_id = ?", sessionID, userID).First(&session).Error != nil ||
+		time.Since(session.CreatedAt) > reauthWindow {
+		return fmt.Errorf("%w: sign in again to confirm", appErrors.ErrInvalidCredentials)
+	}
+	return nil
+}
+
+// ChangePassword replaces the password after checking the current one,
+// voids pending reset links and signs out every other session; the
+// caller's session stays alive.
+func ChangePassword(userID, sessionID uint, current, password string) error {
+	var user models.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		return appErrors.ErrNotFound
+	}
+	if !CheckPassword(user.Password, current) {
+		return appErrors.ErrInvalidCredentials
+	}
+
+	hashed, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	// Reset links mailed before the change would otherwise undo it.
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("password", hashed).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ? AND purpose = ?", userID, PurposePasswordReset).
+			Delete(&models.UserToken{}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	return RevokeUserSessions(userID, sessionID)
+}
+
+// RequestEmailChange mails a confirmation link to the new address. The
+// email only changes once that link is used.
+func RequestEmailChange(userID uint, email, password string) error {
+	var user models.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		return appErrors.ErrNotFound
+	}
+	if !CheckPassword(user.Password, password) {
+		return appErrors.ErrInvalidCredentials
+	}
+	if emailTaken(db.DB, email) {
+		return appErrors.ErrUserExists
+	}
+
+	raw, err := issueUserToken(models.UserToken{
+		UserID:  user.ID,
+		Purpose: PurposeEmailChange,
+		Email:   email,
+	}, settings.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := settings.AppURL + "/confirm-email?token=" + url.QueryEscape(raw)
+	return mailer.Send(mailer.Message{
+		To:      email,
+		Subject: "Confirm your new Flowday email address",
+		Body: fmt.Sprintf(
+			"Open this link within %s to use this address for your Flowday account:\n%s\n",
+			settings.EmailVerificationTTL, link,
+		),
+	})
+}
+
+// ConfirmEmailChange switches the account to the new, now verified,
+// address and lets the old address know.
+func ConfirmEmailChange(rawToken string) error {
+	var oldEmail string
+	var user models.User
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		token, err := consumeUserToken(tx, rawToken, PurposeEmailChange)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.First(&user, token.UserID).Error; err != nil {
+			return appErrors.ErrInvalidToken
+		}
+		if emailTaken(tx, token.Email) {
+			return appErrors.ErrUserExists
+		}
+
+		now := time.Now()
+		oldEmail = user.Email
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"email":             token.Email,
+			"email_verified_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		user.Email, user.EmailVerifiedAt = token.Email, &now
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	claimInvitations(&user)
+
+	if err := mailer.Send(mailer.Message{
+		To:      oldEmail,
+		Subject: "Your Flowday email address was changed",
+		Body: fmt.Sprintf(
+			"Your Flowday account now uses %s.\n\n"+
+				"If you did not make this change, reset your password right away.\n",
+			user.Email,
+		),
+	}); err != nil {
+		log.Println("email change notice failed:", err)
+	}
+
+	return nil
+}
+
+func emailTaken(tx *gorm.DB, email string) bool {
+	var existing models.User
+	err := tx.Where("email = ?", email).First(&existing).Error
+	return !errors.Is(err, gorm.ErrRecordNotFound)
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	appErrors "flowday/internal/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+type ChangeEmailRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+func accountStatus(err error) int {
+	switch {
+	case errors.Is(err, appErrors.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, appErrors.ErrInvalidCredentials):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, appErrors.ErrUserExists):
+		return http.StatusConflict
+	case errors.Is(err, appErrors.ErrInvalidToken):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func ChangePasswordHandler(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := ChangePassword(c.GetUint("user_id"), c.GetUint("session_id"), req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		c.JSON(accountStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func ChangeEmailHandler(c *gin.Context) {
+	var req ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := RequestEmailChange(c.GetUint("user_id"), req.Email, req.Password); err != nil {
+		c.JSON(accountStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "check the new address for a confirmation link",
+	})
+}
+
+func ConfirmEmailChangeHandler(c *gin.Context) {
+	var req TokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := ConfirmEmailChange(req.Token); err != nil {
+		c.JSON(accountStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
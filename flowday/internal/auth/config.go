@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"flowday/internal/config"
+	"flowday/internal/mailer"
+	"flowday/internal/sealbox"
+)
+
+var (
+	settings              = config.DefaultAuth()
+	attempts AttemptStore = NewMemoryAttemptStore()
+	secrets               = sealbox.New("")
+	// Account mails are looked up and sent off the request path.
+	mailQueue = mailer.NewQueue(4, 256)
+)
+
+// Init applies account flow settings; until called the defaults are used.
+func Init(cfg config.Auth) {
+	settings = cfg
+	secrets = sealbox.New(cfg.KeyEncryptionSecret)
+	setOIDCProviders(cfg.OIDCProviders)
+
+	if cfg.LoginAttemptStore == "db" {
+		attempts = DBAttemptStore{}
+	} else {
+		attempts = NewMemoryAttemptStore()
+	}
+}
+
+// UseMailQueue replaces the queue account mails go through, mainly so tests
+// can wait for them.
+func UseMailQueue(q *mailer.Queue) {
+	mailQueue = q
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"flowday/internal/db"
+	"flowday/internal/mailer"
+	"flowday/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// SendVerificationEmail mails a fresh verification link to the user.
+func SendVerificationEmail(user *models.User) error {
+	raw, err := issueUserToken(models.UserToken{UserID: user.ID, Purpose: PurposeEmailVerification}, settings.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := settings.AppURL + "/verify-email?token=" + url.QueryEscape(raw)
+	return mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your Flowday email address",
+		Body: fmt.Sprintf(
+			"Welcome to Flowday!\n\n"+
+				"Open this link within %s to confirm your email address:\n%s\n",
+			settings.EmailVerificationTTL, link,
+		),
+	})
+}
+
+// ResendVerification mails a new link to an unverified account. Like the
+// password reset it runs on the mail queue, is limited per address and IP,
+// and stays silent about unknown addresses.
+func ResendVerification(email, ip string) {
+	if !allowAccountMail("verify", email, ip) {
+		return
+	}
+	if !mailQueue.Enqueue(func() error { return resendVerification(email) }) {
+		log.Println("verification resend dropped: mail queue is full")
+	}
+}
+
+func resendVerification(email string) error {
+	var user models.User
+	if err := db.DB.
+		Where("email = ? AND email_verified_at IS NULL", email).
+		First(&user).Error; err != nil {
+		return nil
+	}
+
+	return SendVerificationEmail(&user)
+}
+
+// VerifyEmail marks the address as verified, which also wins the user the
+// invitations sent to it.
+func VerifyEmail(rawToken string) error {
+	var user models.User
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		token, err := consumeUserToken(tx, rawToken, PurposeEmailVerification)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.User{}).
+			Where("id = ?", token.UserID).
+			Update("email_verified_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.First(&user, token.UserID).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	claimInvitations(&user)
+	return nil
+}
+
+// EmailVerificationRequired tells whether unverified accounts are limited.
+func EmailVerificationRequired() bool {
+	return settings.RequireVerifiedEmail
+}
+
+func IsEmailVerified(userID uint) bool {
+	var count int64
+	db.DB.Model(&models.User{}).
+		Where("id = ? AND email_verified_at IS NOT NULL", userID).
+		Count(&count)
+	return count > 0
+}
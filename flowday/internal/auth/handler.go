@@ -1,7 +1,12 @@
 package auth
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
+
+	appErrors "flowday/internal/errors"
 
 	"github.com/gin-gonic/gin"
 )
@@ -11,6 +16,23 @@ type AuthRequest struct {
 	Password string `json:"password" binding:"required,min=6"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type EmailRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type TokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
 func RegisterHandler(c *gin.Context) {
 	var req AuthRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -25,8 +47,9 @@ func RegisterHandler(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
-		"id":    user.ID,
-		"email": user.Email,
+		"id":             user.ID,
+		"email":          user.Email,
+		"email_verified": user.EmailVerifiedAt != nil,
 	})
 }
 
@@ -37,13 +60,145 @@ func LoginHandler(c *gin.Context) {
 		return
 	}
 
-	token, err := Login(req.Email, req.Password)
+	result, err := Login(req.Email, req.Password, c.Request.UserAgent(), c.ClientIP())
 	if err != nil {
+		loginError(c, err)
+		return
+	}
+
+	loginResponse(c, result)
+}
+
+// loginResponse sends the session's tokens, or the 2FA challenge.
+func loginResponse(c *gin.Context, result *LoginResult) {
+	if result.ChallengeToken != "" {
+		c.JSON(http.StatusOK, gin.H{
+			"mfa_required":    true,
+			"challenge_token": result.ChallengeToken,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokenResponse(result.Tokens))
+}
+
+func RefreshHandler(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pair, err := RefreshSession(req.RefreshToken, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokenResponse(pair))
+}
+
+func LogoutHandler(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := RevokeSession(req.RefreshToken); err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"token": token,
+	c.Status(http.StatusNoContent)
+}
+
+func ForgotPasswordHandler(c *gin.Context) {
+	var req EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Same answer whether or not the account exists.
+	RequestPasswordReset(req.Email, c.ClientIP())
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "if the account exists, a reset link has been sent",
 	})
 }
+
+func ResetPasswordHandler(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := ResetPassword(req.Token, req.Password); err != nil {
+		if errors.Is(err, appErrors.ErrInvalidToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func VerifyEmailHandler(c *gin.Context) {
+	var req TokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := VerifyEmail(req.Token); err != nil {
+		if errors.Is(err, appErrors.ErrInvalidToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func ResendVerificationHandler(c *gin.Context) {
+	var req EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Same answer whether or not the account exists.
+	ResendVerification(req.Email, c.ClientIP())
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "if the account needs verification, a new link has been sent",
+	})
+}
+
+// loginError answers a failed login; lockouts get 429 with Retry-After.
+func loginError(c *gin.Context, err error) {
+	var locked *LockoutError
+	if errors.As(err, &locked) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+}
+
+// "token" is kept as the access token key so existing clients keep working.
+func tokenResponse(pair *TokenPair) gin.H {
+	return gin.H{
+		"token":         pair.AccessToken,
+		"refresh_token": pair.RefreshToken,
+		"token_type":    "Bearer",
+		"expires_in":    pair.ExpiresIn,
+	}
+}
@@ -1,21 +1,10 @@
 package auth
 
-import (
-	"os"
-	"time"
+import "flowday/internal/tokens"
 
-	"github.com/golang-jwt/jwt/v5"
-)
-
-var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
-
-func GenerateToken(userID uint) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 72).Unix(),
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	return token.SignedString(jwtSecret)
+func GenerateToken(userID, sessionID uint) (string, error) {
+	return tokens.Issue(tokens.Claims{
+		UserID:    userID,
+		SessionID: sessionID,
+	})
 }
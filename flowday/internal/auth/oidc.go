@@ -0,0 +1,370 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"flowday/internal/config"
+	"flowday/internal/db"
+	appErrors "flowday/internal/errors"
+	"flowday/internal/models"
+	"flowday/internal/randtoken"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	oidcStateTTL = 10 * time.Minute
+	// Minimum time between JWKS downloads caused by an unknown key id.
+	oidcJWKSCooldown = time.Minute
+)
+
+var oidcHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcProvider caches the discovery document and signing keys of one
+// configured provider.
+type oidcProvider struct {
+	cfg config.OIDCProvider
+
+	mu          sync.Mutex
+	discovery   *oidcDiscovery
+	keys        map[string]interface{}
+	keysFetched time.Time
+}
+
+var oidcProviders = map[string]*oidcProvider{}
+
+func setOIDCProviders(cfgs []config.OIDCProvider) {
+	providers := make(map[string]*oidcProvider, len(cfgs))
+	for _, cfg := range cfgs {
+		providers[cfg.Name] = &oidcProvider{cfg: cfg}
+	}
+	oidcProviders = providers
+}
+
+func (p *oidcProvider) discover() (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	resp, err := oidcHTTPClient.Get(p.cfg.Issuer + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc discovery: unexpected status %d", resp.StatusCode)
+	}
+
+	var d oidcDiscovery
+	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+		return nil, err
+	}
+	// A provider must not claim to be someone else.
+	if strings.TrimRight(d.Issuer, "/") != p.cfg.Issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer %q does not match %q", d.Issuer, p.cfg.Issuer)
+	}
+
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+// key returns the provider key with the given id, downloading the JWKS
+// again if the provider has rotated to a key we have not seen yet.
+func (p *oidcProvider) key(kid string) (interface{}, error) {
+	d, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetched) < oidcJWKSCooldown {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	keys, err := fetchJWKS(d.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+	p.keys, p.keysFetched = keys, time.Now()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+// BeginOIDCLogin starts an authorization code flow with PKCE and returns
+// the provider URL to send the browser to, plus the state to bind to it.
+func BeginOIDCLogin(name string) (string, string, error) {
+	p, ok := oidcProviders[name]
+	if !ok {
+		return "", "", appErrors.ErrNotFound
+	}
+
+	d, err := p.discover()
+	if err != nil {
+		return "", "", err
+	}
+
+	state, _, err := randtoken.New()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, _, err := randtoken.New()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, _, err := randtoken.New()
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := db.DB.Create(&models.OIDCLoginState{
+		State:        state,
+		Provider:     name,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().Add(oidcStateTTL),
+	}).Error; err != nil {
+		return "", "", err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", p.cfg.RedirectURL)
+	q.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + q.Encode(), state, nil
+}
+
+// CompleteOIDCLogin handles the provider's redirect: it redeems the code,
+// verifies the ID token, links the identity to a user and logs them in like
+// a password would: accounts with 2FA still have to pass the challenge.
+func CompleteOIDCLogin(name, code, state, userAgent, ip string) (*LoginResult, error) {
+	p, ok := oidcProviders[name]
+	if !ok {
+		return nil, appErrors.ErrNotFound
+	}
+
+	login, err := takeOIDCState(name, state)
+	if err != nil {
+		return nil, err
+	}
+
+	rawIDToken, err := p.exchange(code, login.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := p.verifyIDToken(rawIDToken, login.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := linkExternalIdentity(name, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return startLogin(user, userAgent, ip)
+}
+
+// takeOIDCState loads and deletes the login state, so each one works once.
+func takeOIDCState(name, state string) (*models.OIDCLoginState, error) {
+	var login models.OIDCLoginState
+	if err := db.DB.Where("state = ? AND provider = ?", state, name).First(&login).Error; err != nil {
+		return nil, appErrors.ErrInvalidToken
+	}
+
+	res := db.DB.Delete(&models.OIDCLoginState{}, login.ID)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 || time.Now().After(login.ExpiresAt) {
+		return nil, appErrors.ErrInvalidToken
+	}
+
+	// Abandoned logins pile up otherwise.
+	db.DB.Where("expires_at < ?", time.Now()).Delete(&models.OIDCLoginState{})
+
+	return &login, nil
+}
+
+func (p *oidcProvider) exchange(code, verifier string) (string, error) {
+	d, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequest("POST", d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+
+	resp, err := oidcHTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken string `json:"id_token"`
+		Error   string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK || body.IDToken == "" {
+		return "", fmt.Errorf("oidc token exchange failed: %d %s", resp.StatusCode, body.Error)
+	}
+
+	return body.IDToken, nil
+}
+
+type idTokenClaims struct {
+	Email         string          `json:"email"`
+	EmailVerified json.RawMessage `json:"email_verified"`
+	Nonce         string          `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+// Some providers send email_verified as the string "true".
+func (c *idTokenClaims) emailVerified() bool {
+	v := strings.Trim(string(c.EmailVerified), `"`)
+	return v == "true"
+}
+
+func (p *oidcProvider) verifyIDToken(raw, nonce string) (*idTokenClaims, error) {
+	claims := &idTokenClaims{}
+
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.key(kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}),
+		jwt.WithIssuer(p.cfg.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", appErrors.ErrInvalidToken, err)
+	}
+	if claims.Nonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", appErrors.ErrInvalidToken)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", appErrors.ErrInvalidToken)
+	}
+
+	return claims, nil
+}
+
+// linkExternalIdentity finds the user behind an external identity. Unknown
+// identities attach to the account with the same email only when the
+// provider vouches for that email; otherwise anyone could take over an
+// account by registering its address at a provider. The account must have
+// verified the address too, or whoever registered it first would keep
+// their password and sessions on an account its owner now signs into.
+func linkExternalIdentity(provider string, claims *idTokenClaims) (*models.User, error) {
+	var user models.User
+	var created bool
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var identity models.ExternalIdentity
+		err := tx.Where("provider = ? AND subject = ?", provider, claims.Subject).First(&identity).Error
+		if err == nil {
+			return tx.First(&user, identity.UserID).Error
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if claims.Email == "" {
+			return fmt.Errorf("%w: provider did not share an email address", appErrors.ErrInvalidInput)
+		}
+
+		verified := claims.emailVerified()
+		err = tx.Where("email = ?", claims.Email).First(&user).Error
+		switch {
+		case err == nil && !verified:
+			return fmt.Errorf("%w: provider did not verify %s", appErrors.ErrInvalidInput, claims.Email)
+		case err == nil && user.EmailVerifiedAt == nil:
+			return fmt.Errorf("%w: verify %s with your Flowday account before signing in with %s",
+				appErrors.ErrInvalidInput, claims.Email, provider)
+		case err == nil:
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			// SSO-only accounts have no password and can't use password login.
+			user = models.User{Email: claims.Email}
+			if verified {
+				now := time.Now()
+				user.EmailVerifiedAt = &now
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			created = true
+		default:
+			return err
+		}
+
+		return tx.Create(&models.ExternalIdentity{
+			UserID:   user.ID,
+			Provider: provider,
+			Subject:  claims.Subject,
+			Email:    claims.Email,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		claimInvitations(&user)
+	}
+
+	return &user, nil
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	appErrors "flowday/internal/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The state is also kept in a cookie so a callback only completes in the
+// browser that started the login (no login CSRF).
+const oidcStateCookie = "flowday_oidc_state"
+
+func OIDCLoginHandler(c *gin.Context) {
+	authURL, state, err := BeginOIDCLogin(c.Param("provider"))
+	if errors.Is(err, appErrors.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown identity provider"})
+		return
+	}
+	if err != nil {
+		log.Println("oidc login failed:", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "identity provider unavailable"})
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, int(oidcStateTTL.Seconds()), "/api/v1/auth/oidc", "", c.Request.TLS != nil, true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+func OIDCCallbackHandler(c *gin.Context) {
+	if e := c.Query("error"); e != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": e})
+		return
+	}
+
+	state := c.Query("state")
+	cookie, err := c.Cookie(oidcStateCookie)
+	if err != nil || state == "" || cookie != state {
+		c.JSON(http.StatusBadRequest, gin.H{"error": appErrors.ErrInvalidToken.Error()})
+		return
+	}
+	c.SetCookie(oidcStateCookie, "", -1, "/api/v1/auth/oidc", "", c.Request.TLS != nil, true)
+
+	result, err := CompleteOIDCLogin(c.Param("provider"), c.Query("code"), state, c.Request.UserAgent(), c.ClientIP())
+	switch {
+	case err == nil:
+		loginResponse(c, result)
+	case errors.Is(err, appErrors.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown identity provider"})
+	case errors.Is(err, appErrors.ErrInvalidToken), errors.Is(err, appErrors.ErrInvalidInput):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	default:
+		log.Println("oidc callback failed:", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "identity provider unavailable"})
+	}
+}
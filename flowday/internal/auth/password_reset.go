@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+
+	"flowday/internal/db"
+	"flowday/internal/mailer"
+	"flowday/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// RequestPasswordReset mails a reset link if the account exists. The lookup
+// and the mail happen on the mail queue, so neither the answer nor the time
+// it takes tell whether an account uses the address. Requests past the
+// per-address or per-IP limit are dropped just as quietly.
+func RequestPasswordReset(email, ip string) {
+	if !allowAccountMail("reset", email, ip) {
+		return
+	}
+	if !mailQueue.Enqueue(func() error { return sendPasswordReset(email) }) {
+		log.Println("password reset dropped: mail queue is full")
+	}
+}
+
+func sendPasswordReset(email string) error {
+	var user models.User
+	if err := db.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		return nil
+	}
+
+	raw, err := issueUserToken(models.UserToken{UserID: user.ID, Purpose: PurposePasswordReset}, settings.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	link := settings.AppURL + "/reset-password?token=" + url.QueryEscape(raw)
+	return mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Flowday password",
+		Body: fmt.Sprintf(
+			"Someone asked to reset the password of your Flowday account.\n\n"+
+				"Open this link within %s to choose a new one:\n%s\n\n"+
+				"If it wasn't you, ignore this email.\n",
+			settings.PasswordResetTTL, link,
+		),
+	})
+}
+
+// ResetPassword sets a new password using a reset token and signs the user
+// out everywhere. The token is checked before the password is hashed, so
+// bad tokens don't cost a bcrypt run.
+func ResetPassword(rawToken, password string) error {
+	if _, err := findUserToken(db.DB, rawToken, PurposePasswordReset); err != nil {
+		return err
+	}
+
+	hashed, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	var userID uint
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		token, err := consumeUserToken(tx, rawToken, PurposePasswordReset)
+		if err != nil {
+			return err
+		}
+		userID = token.UserID
+
+		return tx.Model(&models.User{}).
+			Where("id = ?", token.UserID).
+			Update("password", hashed).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	return RevokeUserSessions(userID, 0)
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	appErrors "flowday/internal/errors"
+	"flowday/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateTokenRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type tokenView struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Token      string     `json:"token,omitempty"`
+}
+
+func newTokenView(pat *models.PersonalAccessToken) tokenView {
+	return tokenView{
+		ID:         pat.ID,
+		Name:       pat.Name,
+		Prefix:     pat.Prefix,
+		Scopes:     TokenScopes(pat),
+		ExpiresAt:  pat.ExpiresAt,
+		LastUsedAt: pat.LastUsedAt,
+		CreatedAt:  pat.CreatedAt,
+	}
+}
+
+func CreateTokenHandler(c *gin.Context) {
+	var req CreateTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pat, raw, err := CreatePersonalAccessToken(c.GetUint("user_id"), req.Name, req.Scopes, req.ExpiresAt)
+	if errors.Is(err, appErrors.ErrInvalidInput) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	view := newTokenView(pat)
+	view.Token = raw
+	c.JSON(http.StatusCreated, view)
+}
+
+func ListTokensHandler(c *gin.Context) {
+	pats, err := ListPersonalAccessTokens(c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	views := make([]tokenView, 0, len(pats))
+	for i := range pats {
+		views = append(views, newTokenView(&pats[i]))
+	}
+	c.JSON(http.StatusOK, views)
+}
+
+func RevokeTokenHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	if err := RevokePersonalAccessToken(c.GetUint("user_id"), uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -1,43 +1,119 @@
-package auth 
+package auth
 
 import (
-
-	"errors"
+	"log"
 
 	"flowday/internal/db"
+	appErrors "flowday/internal/errors"
 	"flowday/internal/models"
+
+	"gorm.io/gorm"
 )
 
-func Register(email , password string) (*models.User , error) {
-	hashed , err := HashPassword(password);
+func Register(email, password string) (*models.User, error) {
+	hashed, err := HashPassword(password)
 
 	if err != nil {
-		return nil , err 
+		return nil, err
 	}
 
-	user := models.User {
-		Email: email,
+	user := models.User{
+		Email:    email,
 		Password: hashed,
 	}
 
 	if err := db.DB.Create(&user).Error; err != nil {
-		return nil , err
+		return nil, err
+
+	}
+	claimInvitations(&user)
+
+	// The account exists either way; the user can ask for another link.
+	if err := SendVerificationEmail(&user); err != nil {
+		log.Println("verification mail failed:", err)
+	}
 
+	return &user, nil
+}
+
+// claimInvitations hands pending project invitations sent to the user's
+// address over to the user, and lets go of those sent to an address the
+// user no longer has. Unverified accounts only pick up unclaimed ones; once
+// the address is verified, the user takes them over from any other account
+// that registered it first.
+func claimInvitations(user *models.User) {
+	email := models.NormalizeEmail(user.Email)
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.ProjectInvitation{}).
+			Where("invitee_id = ? AND email <> ? AND status = ?", user.ID, email, models.InvitationPending).
+			Update("invitee_id", nil).Error; err != nil {
+			return err
+		}
+
+		claim := tx.Model(&models.ProjectInvitation{}).
+			Where("email = ? AND status = ?", email, models.InvitationPending)
+		if user.EmailVerifiedAt == nil {
+			claim = claim.Where("invitee_id IS NULL")
+		}
+		return claim.Update("invitee_id", user.ID).Error
+	})
+	if err != nil {
+		log.Println("claiming invitations failed:", err)
 	}
+}
 
-	return &user , nil
+// LoginResult holds either a session's tokens or, for accounts with 2FA,
+// the challenge token to complete the login with.
+type LoginResult struct {
+	Tokens         *TokenPair
+	ChallengeToken string
 }
 
-func Login(email, password string) (string, error) {
+// Login checks the password, throttled per account and per IP. Locked keys
+// are refused before the (deliberately slow) bcrypt comparison runs.
+func Login(email, password, userAgent, ip string) (*LoginResult, error) {
+	account := accountKey(email)
+	if err := checkThrottle(account, ipKey(ip)); err != nil {
+		return nil, err
+	}
+
 	var user models.User
 
 	if err := db.DB.Where("email = ?", email).First(&user).Error; err != nil {
-		return "", errors.New("invalid credentials")
+		loginFailed(account, nil, ip)
+		return nil, appErrors.ErrInvalidCredentials
 	}
 
 	if !CheckPassword(user.Password, password) {
-		return "", errors.New("invalid credentials")
+		loginFailed(account, &user.ID, ip)
+		return nil, appErrors.ErrInvalidCredentials
+	}
+
+	attempts.Reset(account)
+
+	return startLogin(&user, userAgent, ip)
+}
+
+// startLogin finishes a successful first factor: accounts with 2FA get a
+// challenge, everyone else a session.
+func startLogin(user *models.User, userAgent, ip string) (*LoginResult, error) {
+	if user.TOTPEnabledAt != nil {
+		challenge, err := IssueMFAChallenge(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{ChallengeToken: challenge}, nil
+	}
+
+	pair, err := CreateSession(user.ID, userAgent, ip)
+	if err != nil {
+		return nil, err
 	}
+	return &LoginResult{Tokens: pair}, nil
+}
 
-	return GenerateToken(user.ID)
-}
\ No newline at end of file
+func loginFailed(account string, userID *uint, ip string) {
+	recordFailure(account, settings.LoginFreeAttempts, userID, ip)
+	recordFailure(ipKey(ip), settings.LoginFreeAttempts*ipAttemptFactor, nil, ip)
+}
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"time"
+
+	"flowday/internal/db"
+	appErrors "flowday/internal/errors"
+	"flowday/internal/models"
+	"flowday/internal/randtoken"
+	"flowday/internal/tokens"
+)
+
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64
+}
+
+// CreateSession starts a new session for the user and returns its first
+// access/refresh token pair.
+func CreateSession(userID uint, userAgent, ip string) (*TokenPair, error) {
+	raw, hash, err := randtoken.New()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		UserID:     userID,
+		TokenHash:  hash,
+		UserAgent:  userAgent,
+		IP:         ip,
+		ExpiresAt:  now.Add(RefreshTokenTTL),
+		LastUsedAt: now,
+	}
+
+	if err := db.DB.Create(&session).Error; err != nil {
+		return nil, err
+	}
+
+	return issuePair(session.UserID, session.ID, raw)
+}
+
+// RefreshSession exchanges a refresh token for a new pair. The presented
+// token stops working; presenting it again revokes the whole session, since
+// that only happens when it was stolen.
+func RefreshSession(refreshToken, userAgent, ip string) (*TokenPair, error) {
+	hash := randtoken.Hash(refreshToken)
+	now := time.Now()
+
+	var session models.Session
+	if err := db.DB.Where("token_hash = ?", hash).First(&session).Error; err != nil {
+		var reused models.Session
+		if db.DB.Where("previous_token_hash = ?", hash).First(&reused).Error == nil {
+			db.DB.Model(&reused).Where("revoked_at IS NULL").Update("revoked_at", now)
+		}
+		return nil, appErrors.ErrInvalidRefreshToken
+	}
+
+	if session.RevokedAt != nil || now.After(session.ExpiresAt) {
+		return nil, appErrors.ErrInvalidRefreshToken
+	}
+
+	raw, newHash, err := randtoken.New()
+	if err != nil {
+		return nil, err
+	}
+
+	// Guard on the old hash so two concurrent refreshes cannot both win.
+	res := db.DB.Model(&models.Session{}).
+		Where("id = ? AND token_hash = ?", session.ID, hash).
+		Updates(map[string]interface{}{
+			"token_hash":          newHash,
+			"previous_token_hash": hash,
+			"user_agent":          userAgent,
+			"ip":                  ip,
+			"expires_at":          now.Add(RefreshTokenTTL),
+			"last_used_at":        now,
+		})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, appErrors.ErrInvalidRefreshToken
+	}
+
+	return issuePair(session.UserID, session.ID, raw)
+}
+
+// RevokeSession ends the session the refresh token belongs to.
+func RevokeSession(refreshToken string) error {
+	res := db.DB.Model(&models.Session{}).
+		Where("token_hash = ? AND revoked_at IS NULL", randtoken.Hash(refreshToken)).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return appErrors.ErrInvalidRefreshToken
+	}
+	return nil
+}
+
+// RevokeUserSessions ends every session of the user except keepID (pass 0
+// to end all of them).
+func RevokeUserSessions(userID, keepID uint) error {
+	return db.DB.Model(&models.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keepID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// SessionActive reports whether access tokens of the session are still
+// accepted.
+func SessionActive(sessionID uint) bool {
+	var count int64
+	db.DB.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL AND expires_at > ?", sessionID, time.Now()).
+		Count(&count)
+	return count > 0
+}
+
+func issuePair(userID, sessionID uint, refreshToken string) (*TokenPair, error) {
+	access, err := GenerateToken(userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(tokens.Default().AccessTTL().Seconds()),
+	}, nil
+}
@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"flowday/internal/audit"
+	"flowday/internal/db"
+	appErrors "flowday/internal/errors"
+	"flowday/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// Failures older than this are forgotten.
+	attemptWindow = time.Hour
+	// First lock after the free attempts; doubles with every failure.
+	baseLockout = time.Second
+	// IPs front many users (offices, NAT), so they get more slack.
+	ipAttemptFactor = 10
+)
+
+// Attempts is the failure history of one throttling key.
+type Attempts struct {
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   time.Time
+}
+
+// AttemptStore keeps failure counters. Update must apply fn atomically and
+// may call it more than once to get there.
+type AttemptStore interface {
+	Get(key string) (Attempts, error)
+	Update(key string, fn func(a *Attempts)) (Attempts, error)
+	Reset(key string) error
+}
+
+// LockoutError is returned while a key is locked.
+type LockoutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	return appErrors.ErrTooManyAttempts.Error()
+}
+
+func (e *LockoutError) Unwrap() error {
+	return appErrors.ErrTooManyAttempts
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func mailKey(purpose, email string) string {
+	return "mail:" + purpose + ":" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func mailIPKey(ip string) string {
+	return "mail-ip:" + ip
+}
+
+func mfaKey(userID uint) string {
+	return fmt.Sprintf("mfa:%d", userID)
+}
+
+// checkThrottle fails if any of the keys is currently locked.
+func checkThrottle(keys ...string) error {
+	now := time.Now()
+	for _, key := range keys {
+		a, err := attempts.Get(key)
+		if err != nil {
+			return err
+		}
+		if now.Before(a.LockedUntil) {
+			return &LockoutError{RetryAfter: a.LockedUntil.Sub(now)}
+		}
+	}
+	return nil
+}
+
+// recordFailure counts a failed attempt and locks the key once it has used
+// up its free attempts. Locks are audited.
+func recordFailure(key string, freeAttempts int, userID *uint, ip string) {
+	now := time.Now()
+	var lockedFor time.Duration
+
+	a, err := attempts.Update(key, func(a *Attempts) {
+		lockedFor = 0
+		if now.Sub(a.LastFailureAt) > attemptWindow {
+			*a = Attempts{}
+		}
+		a.Failures++
+		a.LastFailureAt = now
+
+		if a.Failures > freeAttempts {
+			lockedFor = lockoutFor(a.Failures - freeAttempts)
+			a.LockedUntil = now.Add(lockedFor)
+		}
+	})
+	if err != nil || lockedFor == 0 {
+		return
+	}
+
+	audit.Record(audit.EventLoginLockout, userID, ip,
+		fmt.Sprintf("%s locked for %s after %d failed attempts", key, lockedFor, a.Failures))
+}
+
+// allowRequest counts a request against key unless limit requests were
+// already let through in the window, in which case it reports false. Refused
+// requests aren't counted, so the window runs from the last allowed one.
+func allowRequest(key string, limit int) (bool, error) {
+	now := time.Now()
+	allowed := false
+
+	_, err := attempts.Update(key, func(a *Attempts) {
+		allowed = false
+		if now.Sub(a.LastFailureAt) > attemptWindow {
+			*a = Attempts{}
+		}
+		if a.Failures >= limit {
+			return
+		}
+		a.Failures++
+		a.LastFailureAt = now
+		allowed = true
+	})
+	return allowed, err
+}
+
+// allowAccountMail limits the mails one address, and one IP, can trigger.
+func allowAccountMail(purpose, email, ip string) bool {
+	for _, limit := range []struct {
+		key string
+		max int
+	}{
+		{mailIPKey(ip), settings.AccountMailsPerHour * ipAttemptFactor},
+		{mailKey(purpose, email), settings.AccountMailsPerHour},
+	} {
+		ok, err := allowRequest(limit.key, limit.max)
+		if err != nil || !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// lockoutFor doubles the lock with every failure past the free ones.
+func lockoutFor(excess int) time.Duration {
+	d := time.Duration(float64(baseLockout) * math.Pow(2, float64(excess-1)))
+	if d > settings.LoginMaxLockout || d <= 0 {
+		return settings.LoginMaxLockout
+	}
+	return d
+}
+
+// MemoryAttemptStore keeps counters in process memory. It is the default
+// and fine for a single instance.
+type MemoryAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]Attempts
+}
+
+func NewMemoryAttemptStore() *MemoryAttemptStore {
+	return &MemoryAttemptStore{attempts: map[string]Attempts{}}
+}
+
+func (s *MemoryAttemptStore) Get(key string) (Attempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attempts[key], nil
+}
+
+func (s *MemoryAttemptStore) Update(key string, fn func(a *Attempts)) (Attempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := s.attempts[key]
+	fn(&a)
+	s.attempts[key] = a
+
+	if len(s.attempts) > 10000 {
+		s.prune()
+	}
+	return a, nil
+}
+
+func (s *MemoryAttemptStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.attempts, key)
+	return nil
+}
+
+func (s *MemoryAttemptStore) prune() {
+	now := time.Now()
+	for key, a := range s.attempts {
+		if now.Sub(a.LastFailureAt) > attemptWindow && now.After(a.LockedUntil) {
+			delete(s.attempts, key)
+		}
+	}
+}
+
+// DBAttemptStore shares counters between instances via the login_attempts
+// table.
+type DBAttemptStore struct{}
+
+func (DBAttemptStore) Get(key string) (Attempts, error) {
+	var row models.LoginAttempt
+	err := db.DB.Where("attempt_key = ?", key).Limit(1).Find(&row).Error
+	return attemptsFromRow(row), err
+}
+
+// Update is optimistic: the write only lands if the row is still at the
+// version that was read, otherwise fn runs again on the fresh row. That
+// keeps concurrent failures from overwriting each other on any database.
+func (DBAttemptStore) Update(key string, fn func(a *Attempts)) (Attempts, error) {
+	for {
+		var row models.LoginAttempt
+		if err := db.DB.Where("attempt_key = ?", key).Limit(1).Find(&row).Error; err != nil {
+			return Attempts{}, err
+		}
+
+		a := attemptsFromRow(row)
+		fn(&a)
+
+		var lockedUntil *time.Time
+		if !a.LockedUntil.IsZero() {
+			lockedUntil = &a.LockedUntil
+		}
+
+		var res *gorm.DB
+		if row.Key == "" {
+			res = db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.LoginAttempt{
+				Key:           key,
+				Failures:      a.Failures,
+				LastFailureAt: a.LastFailureAt,
+				LockedUntil:   lockedUntil,
+				Version:       1,
+			})
+		} else {
+			res = db.DB.Model(&models.LoginAttempt{}).
+				Where("attempt_key = ? AND version = ?", key, row.Version).
+				Updates(map[string]interface{}{
+					"failures":        a.Failures,
+					"last_failure_at": a.LastFailureAt,
+					"locked_until":    lockedUntil,
+					"version":         row.Version + 1,
+				})
+		}
+		if res.Error != nil {
+			return Attempts{}, res.Error
+		}
+		if res.RowsAffected > 0 {
+			return a, nil
+		}
+	}
+}
+
+func (DBAttemptStore) Reset(key string) error {
+	return db.DB.Where("attempt_key = ?", key).Delete(&models.LoginAttempt{}).Error
+}
+
+func attemptsFromRow(row models.LoginAttempt) Attempts {
+	a := Attempts{Failures: row.Failures, LastFailureAt: row.LastFailureAt}
+	if row.LockedUntil != nil {
+		a.LockedUntil = *row.LockedUntil
+	}
+	return a
+}
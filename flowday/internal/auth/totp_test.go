@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test vectors from RFC 6238 appendix B (SHA1, 8 digits)
+func TestHOTPVectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+	vectors := map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	}
+
+	for unix, want := range vectors {
+		assert.Equal(t, want, hotp(key, uint64(unix/totpPeriod), 8), unix)
+	}
+}
+
+func TestValidateTOTPWindow(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1111111111, 0)
+	code := hotp([]byte("12345678901234567890"), uint64(now.Unix()/totpPeriod), totpDigits)
+
+	step, ok := validateTOTP(secret, code, now)
+	assert.True(t, ok)
+	assert.Equal(t, now.Unix()/totpPeriod, step)
+
+	_, ok = validateTOTP(secret, code, now.Add(totpPeriod*time.Second))
+	assert.True(t, ok, "one step of drift is tolerated")
+
+	_, ok = validateTOTP(secret, code, now.Add(3*totpPeriod*time.Second))
+	assert.False(t, ok)
+}
@@ -0,0 +1,305 @@
+package auth
+
+import (
+	"crypto/rand"
+	"strings"
+	"time"
+
+	"flowday/internal/db"
+	appErrors "flowday/internal/errors"
+	"flowday/internal/models"
+	"flowday/internal/tokens"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	mfaChallengeTTL   = 5 * time.Minute
+	recoveryCodeCount = 10
+)
+
+type Enrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// EnrollTOTP stores a new, not yet active secret. 2FA only turns on once a
+// first code proves the authenticator app has it.
+func EnrollTOTP(userID uint) (*Enrollment, error) {
+	var user models.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		return nil, appErrors.ErrNotFound
+	}
+	if user.TOTPEnabledAt != nil {
+		return nil, appErrors.ErrTwoFactorEnabled
+	}
+
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := secrets.Seal([]byte(secret))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.DB.Model(&user).Updates(map[string]interface{}{
+		"totp_secret":    sealed,
+		"totp_last_step": 0,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	return &Enrollment{Secret: secret, URI: totpURI(secret, user.Email)}, nil
+}
+
+// ConfirmTOTP enables 2FA with the first code and returns the recovery
+// codes, which are shown to the user exactly once.
+func ConfirmTOTP(userID uint, code string) ([]string, error) {
+	var user models.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		return nil, appErrors.ErrNotFound
+	}
+	if user.TOTPEnabledAt != nil {
+		return nil, appErrors.ErrTwoFactorEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, appErrors.ErrTwoFactorDisabled
+	}
+
+	secret, err := totpSecret(&user)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := validateTOTP(secret, code, time.Now())
+	if !ok {
+		return nil, appErrors.ErrInvalidCode
+	}
+
+	var codes []string
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"totp_enabled_at": time.Now(),
+			"totp_last_step":  step,
+		}).Error; err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+
+	return codes, err
+}
+
+// DisableTOTP turns 2FA off after re-checking the password. Wrong
+// passwords count against the same limits as failed logins.
+func DisableTOTP(userID uint, password, ip string) error {
+	var user models.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		return appErrors.ErrNotFound
+	}
+
+	account := accountKey(user.Email)
+	if err := checkThrottle(account, ipKey(ip)); err != nil {
+		return err
+	}
+	if !CheckPassword(user.Password, password) {
+		loginFailed(account, &user.ID, ip)
+		return appErrors.ErrInvalidCredentials
+	}
+	attempts.Reset(account)
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"totp_secret":     "",
+			"totp_enabled_at": nil,
+			"totp_last_step":  0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes; it needs a current
+// TOTP code so a stolen session alone can't mint new ones.
+func RegenerateRecoveryCodes(userID uint, code string) ([]string, error) {
+	var user models.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		return nil, appErrors.ErrNotFound
+	}
+	if user.TOTPEnabledAt == nil {
+		return nil, appErrors.ErrTwoFactorDisabled
+	}
+	if err := checkTOTP(&user, code); err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+// IssueMFAChallenge returns the token that stands in for a successful
+// password check until the second factor is verified. Its id is stored as
+// a user token, so each challenge completes one login at most and only the
+// latest one works.
+func IssueMFAChallenge(userID uint) (string, error) {
+	jti, err := issueUserToken(models.UserToken{UserID: userID, Purpose: PurposeMFAChallenge}, mfaChallengeTTL)
+	if err != nil {
+		return "", err
+	}
+
+	return tokens.Issue(tokens.Claims{
+		UserID:  userID,
+		Purpose: tokens.PurposeMFA,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(mfaChallengeTTL)),
+		},
+	})
+}
+
+// CompleteMFALogin finishes a two-step login with either a TOTP code or a
+// recovery code.
+func CompleteMFALogin(challenge, code, userAgent, ip string) (*TokenPair, error) {
+	claims, err := tokens.Verify(challenge)
+	if err != nil || claims.Purpose != tokens.PurposeMFA {
+		return nil, appErrors.ErrInvalidToken
+	}
+	// checked up front so a spent challenge doesn't burn a recovery code
+	if _, err := findUserToken(db.DB, claims.ID, PurposeMFAChallenge); err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := db.DB.First(&user, claims.UserID).Error; err != nil || user.TOTPEnabledAt == nil {
+		return nil, appErrors.ErrInvalidToken
+	}
+
+	key := mfaKey(user.ID)
+	if err := checkThrottle(key, ipKey(ip)); err != nil {
+		return nil, err
+	}
+	if err := checkSecondFactor(&user, code); err != nil {
+		recordFailure(key, settings.LoginFreeAttempts, &user.ID, ip)
+		recordFailure(ipKey(ip), settings.LoginFreeAttempts*ipAttemptFactor, nil, ip)
+		return nil, err
+	}
+	attempts.Reset(key)
+
+	if _, err := consumeUserToken(db.DB, claims.ID, PurposeMFAChallenge); err != nil {
+		return nil, err
+	}
+	return CreateSession(user.ID, userAgent, ip)
+}
+
+func checkSecondFactor(user *models.User, code string) error {
+	code = strings.TrimSpace(code)
+	if len(code) == totpDigits {
+		return checkTOTP(user, code)
+	}
+	return useRecoveryCode(user.ID, code)
+}
+
+// checkTOTP validates a code and burns its time step, so a code seen over
+// someone's shoulder can't be replayed within its window.
+func checkTOTP(user *models.User, code string) error {
+	secret, err := totpSecret(user)
+	if err != nil {
+		return err
+	}
+
+	step, ok := validateTOTP(secret, code, time.Now())
+	if !ok || step <= user.TOTPLastStep {
+		return appErrors.ErrInvalidCode
+	}
+
+	res := db.DB.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", user.ID, step).
+		Update("totp_last_step", step)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return appErrors.ErrInvalidCode
+	}
+	return nil
+}
+
+// totpSecret opens the user's sealed TOTP secret.
+func totpSecret(user *models.User) (string, error) {
+	secret, err := secrets.Open(user.TOTPSecret)
+	return string(secret), err
+}
+
+func useRecoveryCode(userID uint, code string) error {
+	hash, err := hashRecoveryCode(code)
+	if err != nil {
+		return err
+	}
+
+	res := db.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return appErrors.ErrInvalidCode
+	}
+	return nil
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		hash, err := hashRecoveryCode(code)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := tx.Create(&models.RecoveryCode{
+			UserID:   userID,
+			CodeHash: hash,
+		}).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// newRecoveryCode returns ten base32 characters (50 bits) as "xxxxx-xxxxx".
+func newRecoveryCode() (string, error) {
+	buf := make([]byte, 7)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	raw := strings.ToLower(totpEncoding.EncodeToString(buf))
+	return raw[:5] + "-" + raw[5:10], nil
+}
+
+// hashRecoveryCode keys the hash with the server secret: the codes only
+// have 50 bits, which a plain hash wouldn't protect. They are typed by
+// hand, so compare them case-insensitively.
+func hashRecoveryCode(code string) (string, error) {
+	return secrets.MAC(strings.ToLower(strings.TrimSpace(code)))
+}
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	appErrors "flowday/internal/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type PasswordRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+type MFALoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+func twoFactorStatus(err error) int {
+	switch {
+	case errors.Is(err, appErrors.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, appErrors.ErrTwoFactorEnabled), errors.Is(err, appErrors.ErrTwoFactorDisabled):
+		return http.StatusConflict
+	case errors.Is(err, appErrors.ErrInvalidCode), errors.Is(err, appErrors.ErrInvalidCredentials):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, appErrors.ErrInvalidToken):
+		return http.StatusUnauthorized
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func EnrollTOTPHandler(c *gin.Context) {
+	enrollment, err := EnrollTOTP(c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(twoFactorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+func ConfirmTOTPHandler(c *gin.Context) {
+	var req CodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := ConfirmTOTP(c.GetUint("user_id"), req.Code)
+	if err != nil {
+		c.JSON(twoFactorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+func DisableTOTPHandler(c *gin.Context) {
+	var req PasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := DisableTOTP(c.GetUint("user_id"), req.Password, c.ClientIP()); err != nil {
+		if errors.Is(err, appErrors.ErrTooManyAttempts) {
+			loginError(c, err)
+			return
+		}
+		c.JSON(twoFactorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func RegenerateRecoveryCodesHandler(c *gin.Context) {
+	var req CodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := RegenerateRecoveryCodes(c.GetUint("user_id"), req.Code)
+	if err != nil {
+		c.JSON(twoFactorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+func MFALoginHandler(c *gin.Context) {
+	var req MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pair, err := CompleteMFALogin(req.ChallengeToken, req.Code, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		loginError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokenResponse(pair))
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"time"
+
+	"flowday/internal/db"
+	appErrors "flowday/internal/errors"
+	"flowday/internal/models"
+	"flowday/internal/randtoken"
+
+	"gorm.io/gorm"
+)
+
+const (
+	PurposePasswordReset     = "password_reset"
+	PurposeEmailVerification = "email_verification"
+	PurposeEmailChange       = "email_change"
+	PurposeMFAChallenge      = "mfa_challenge"
+)
+
+// issueUserToken stores a single-use token for the user and purpose of
+// the template and invalidates any earlier unused token of the same kind,
+// so only the latest email works.
+func issueUserToken(template models.UserToken, ttl time.Duration) (string, error) {
+	raw, hash, err := randtoken.New()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", template.UserID, template.Purpose).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		template.TokenHash = hash
+		template.ExpiresAt = now.Add(ttl)
+		return tx.Create(&template).Error
+	})
+
+	return raw, err
+}
+
+// findUserToken returns a token that is unused and unexpired, without
+// using it up.
+func findUserToken(tx *gorm.DB, raw, purpose string) (*models.UserToken, error) {
+	var token models.UserToken
+	if err := tx.
+		Where("token_hash = ? AND purpose = ?", randtoken.Hash(raw), purpose).
+		First(&token).Error; err != nil {
+		return nil, appErrors.ErrInvalidToken
+	}
+
+	if token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+		return nil, appErrors.ErrInvalidToken
+	}
+	return &token, nil
+}
+
+// consumeUserToken marks a token as used and returns it. The conditional
+// update makes sure a token can only ever be redeemed once.
+func consumeUserToken(tx *gorm.DB, raw, purpose string) (*models.UserToken, error) {
+	token, err := findUserToken(tx, raw, purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	res := tx.Model(&models.UserToken{}).
+		Where("id = ? AND used_at IS NULL", token.ID).
+		Update("used_at", time.Now())
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, appErrors.ErrInvalidToken
+	}
+
+	return token, nil
+}
@@ -0,0 +1,261 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	HTTP     HTTP
+	JWT      JWT
+	Auth     Auth
+	Projects Projects
+	Mail     Mail
+}
+
+// Auth holds account flow settings. AppURL is where links in emails point.
+// RequireVerifiedEmail stops unverified accounts from creating projects.
+// Login throttling allows LoginFreeAttempts failures per account (ten times
+// as many per IP), then locks with exponential backoff up to
+// LoginMaxLockout. LoginAttemptStore is "memory" or "db"; use "db" when
+// running more than one instance. Reset and verification mails are limited
+// to AccountMailsPerHour per address, again ten times as many per IP.
+// KeyEncryptionSecret is the JWT one; it seals TOTP secrets and keys the
+// recovery code hashes, so 2FA can't be enrolled without it.
+type Auth struct {
+	AppURL               string
+	PasswordResetTTL     time.Duration
+	EmailVerificationTTL time.Duration
+	RequireVerifiedEmail bool
+	LoginFreeAttempts    int
+	LoginMaxLockout      time.Duration
+	LoginAttemptStore    string
+	AccountMailsPerHour  int
+	KeyEncryptionSecret  string
+	OIDCProviders        []OIDCProvider
+}
+
+// OIDCProvider is an external identity provider users can sign in with.
+// Name appears in the login URL: /api/v1/auth/oidc/<name>/login.
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	RedirectURL  string
+}
+
+// Projects holds collaboration settings. AppURL is where links in emails
+// point, as for Auth. Deleted projects and tasks stay restorable for
+// TrashRetention before they are purged for good. Subtasks nest at most
+// MaxTaskDepth levels, counting top-level tasks as the first.
+type Projects struct {
+	AppURL         string
+	InvitationTTL  time.Duration
+	TrashRetention time.Duration
+	MaxTaskDepth   int
+}
+
+func DefaultProjects() Projects {
+	return Projects{
+		AppURL:         "http://localhost:8080",
+		InvitationTTL:  7 * 24 * time.Hour,
+		TrashRetention: 30 * 24 * time.Hour,
+		MaxTaskDepth:   5,
+	}
+}
+
+// Mail selects how emails are delivered: "smtp", or "log" which writes
+// them to LogFile (stdout when empty).
+type Mail struct {
+	Driver       string
+	From         string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	LogFile      string
+}
+
+func DefaultAuth() Auth {
+	return Auth{
+		AppURL:               "http://localhost:8080",
+		PasswordResetTTL:     time.Hour,
+		EmailVerificationTTL: 48 * time.Hour,
+		LoginFreeAttempts:    5,
+		LoginMaxLockout:      15 * time.Minute,
+		LoginAttemptStore:    "memory",
+		AccountMailsPerHour:  5,
+	}
+}
+
+// HTTP configures the server. Client IPs, which login throttling keys on,
+// are only read from X-Forwarded-For when the request comes from one of
+// TrustedProxies (addresses or CIDR ranges). By default none are trusted.
+type HTTP struct {
+	TrustedProxies []string
+}
+
+// JWT describes how access tokens are signed and verified. The active key
+// signs new tokens; VerifyKeys are only accepted on incoming tokens so old
+// keys keep working while they are rotated out. With RotateEvery set, fresh
+// keys of the active key's algorithm are generated and shared through the
+// database, encrypted with KeyEncryptionSecret, which rotation requires.
+type JWT struct {
+	Active              JWTKey
+	VerifyKeys          []JWTKey
+	Issuer              string
+	Audience            string
+	AccessTTL           time.Duration
+	RotateEvery         time.Duration
+	KeyEncryptionSecret string
+}
+
+// JWTKey holds either an HMAC secret or a path to a PEM file, depending on
+// the algorithm (HS256, RS256 or EdDSA).
+type JWTKey struct {
+	ID        string
+	Algorithm string
+	Secret    string
+	File      string
+}
+
+func Load() Config {
+	auth := DefaultAuth()
+	projects := DefaultProjects()
+
+	return Config{
+		HTTP: HTTP{
+			TrustedProxies: getList("TRUSTED_PROXIES"),
+		},
+		JWT: JWT{
+			Active: JWTKey{
+				ID:        getEnv("JWT_KEY_ID", "default"),
+				Algorithm: getEnv("JWT_ALGORITHM", "HS256"),
+				Secret:    os.Getenv("JWT_SECRET"),
+				File:      os.Getenv("JWT_PRIVATE_KEY_FILE"),
+			},
+			VerifyKeys:          parseKeys(os.Getenv("JWT_VERIFY_KEYS")),
+			Issuer:              getEnv("JWT_ISSUER", "flowday"),
+			Audience:            getEnv("JWT_AUDIENCE", "flowday-api"),
+			AccessTTL:           getDuration("JWT_ACCESS_TTL", 15*time.Minute),
+			RotateEvery:         getDuration("JWT_ROTATE_EVERY", 0),
+			KeyEncryptionSecret: os.Getenv("JWT_KEY_ENCRYPTION_SECRET"),
+		},
+		Auth: Auth{
+			AppURL:               strings.TrimRight(getEnv("APP_URL", auth.AppURL), "/"),
+			PasswordResetTTL:     getDuration("PASSWORD_RESET_TTL", auth.PasswordResetTTL),
+			EmailVerificationTTL: getDuration("EMAIL_VERIFICATION_TTL", auth.EmailVerificationTTL),
+			RequireVerifiedEmail: getBool("REQUIRE_VERIFIED_EMAIL", auth.RequireVerifiedEmail),
+			LoginFreeAttempts:    getInt("LOGIN_FREE_ATTEMPTS", auth.LoginFreeAttempts),
+			LoginMaxLockout:      getDuration("LOGIN_MAX_LOCKOUT", auth.LoginMaxLockout),
+			LoginAttemptStore:    getEnv("LOGIN_ATTEMPT_STORE", auth.LoginAttemptStore),
+			AccountMailsPerHour:  getInt("ACCOUNT_MAILS_PER_HOUR", auth.AccountMailsPerHour),
+			KeyEncryptionSecret:  os.Getenv("JWT_KEY_ENCRYPTION_SECRET"),
+			OIDCProviders:        loadOIDCProviders(strings.TrimRight(getEnv("APP_URL", auth.AppURL), "/")),
+		},
+		Projects: Projects{
+			AppURL:         strings.TrimRight(getEnv("APP_URL", projects.AppURL), "/"),
+			InvitationTTL:  getDuration("PROJECT_INVITATION_TTL", projects.InvitationTTL),
+			TrashRetention: getDuration("TRASH_RETENTION", projects.TrashRetention),
+			MaxTaskDepth:   getInt("MAX_TASK_DEPTH", projects.MaxTaskDepth),
+		},
+		Mail: Mail{
+			Driver:       getEnv("MAIL_DRIVER", "log"),
+			From:         getEnv("MAIL_FROM", "flowday@localhost"),
+			SMTPHost:     os.Getenv("SMTP_HOST"),
+			SMTPPort:     getEnv("SMTP_PORT", "587"),
+			SMTPUsername: os.Getenv("SMTP_USERNAME"),
+			SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+			LogFile:      os.Getenv("MAIL_LOG_FILE"),
+		},
+	}
+}
+
+// loadOIDCProviders reads OIDC_PROVIDERS (comma separated names) and the
+// OIDC_<NAME>_* variables of each provider.
+func loadOIDCProviders(appURL string) []OIDCProvider {
+	var providers []OIDCProvider
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		providers = append(providers, OIDCProvider{
+			Name:         name,
+			Issuer:       strings.TrimRight(os.Getenv(prefix+"ISSUER"), "/"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			Scopes:       strings.Fields(getEnv(prefix+"SCOPES", "openid email profile")),
+			RedirectURL:  getEnv(prefix+"REDIRECT_URL", appURL+"/api/v1/auth/oidc/"+name+"/callback"),
+		})
+	}
+	return providers
+}
+
+// parseKeys reads "kid:ALG:value" entries separated by commas, where value
+// is the secret for HS256 and a PEM file path otherwise.
+func parseKeys(raw string) []JWTKey {
+	var keys []JWTKey
+	for _, entry := range strings.Split(raw, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+
+		key := JWTKey{ID: parts[0], Algorithm: parts[1]}
+		if parts[1] == "HS256" {
+			key.Secret = parts[2]
+		} else {
+			key.File = parts[2]
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func getEnv(name, fallback string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func getList(name string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(name), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func getInt(name string, fallback int) int {
+	n, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return fallback
+	}
+	return n
+}
+
+func getBool(name string, fallback bool) bool {
+	b, err := strconv.ParseBool(os.Getenv(name))
+	if err != nil {
+		return fallback
+	}
+	return b
+}
+
+func getDuration(name string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(name))
+	if err != nil {
+		return fallback
+	}
+	return d
+}
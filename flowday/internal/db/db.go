@@ -7,15 +7,18 @@ import (
 	"gorm.io/gorm"
 )
 
-var DB *gorm.DB;
+var DB *gorm.DB
 
+// SQLite only enforces foreign keys, and so their cascades, on connections
+// that ask for it.
+const foreignKeys = "?_foreign_keys=on"
 
 func Init() {
-	database , err := gorm.Open(sqlite.Open("flowday.db") , &gorm.Config{})
+	database, err := gorm.Open(sqlite.Open("flowday.db"+foreignKeys), &gorm.Config{})
 	if err != nil {
 		log.Fatal("Failed to connect to database")
 	}
 
-	DB = database;
+	DB = database
 	log.Println("Connected to database")
-}
\ No newline at end of file
+}
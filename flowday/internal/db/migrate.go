@@ -1,7 +1,121 @@
 package db
 
-import "flowday/internal/models"
+import (
+	"log"
+	"time"
+
+	"flowday/internal/models"
+
+	"gorm.io/gorm"
+)
 
 func Migrate() {
-	DB.AutoMigrate(&models.User{}, &models.Project{}, &models.Task{})
-}
\ No newline at end of file
+	// Accounts created before email verification existed are trusted.
+	grandfatherEmails := DB.Migrator().HasTable(&models.User{}) &&
+		!DB.Migrator().HasColumn(&models.User{}, "EmailVerifiedAt")
+
+	// Adding a constraint rebuilds the table, and dropping the old copy would
+	// cascade into other tables with foreign keys on. The pragma only holds
+	// for one connection, so the schema is migrated on a single one.
+	DB.Connection(func(conn *gorm.DB) error {
+		conn.Exec("PRAGMA foreign_keys = OFF")
+		defer conn.Exec("PRAGMA foreign_keys = ON")
+		return conn.AutoMigrate(&models.User{}, &models.Project{}, &models.Task{}, &models.Session{}, &models.SigningKey{}, &models.PersonalAccessToken{}, &models.UserToken{}, &models.RecoveryCode{}, &models.AuditEvent{}, &models.LoginAttempt{}, &models.ExternalIdentity{}, &models.OIDCLoginState{}, &models.ProjectMember{}, &models.Workspace{}, &models.WorkspaceMember{}, &models.ProjectInvitation{}, &models.WorkflowState{}, &models.WorkflowTransition{}, &models.ChecklistItem{}, &models.Label{}, &models.Comment{}, &models.CommentRevision{})
+	})
+
+	// Projects from before sharing are owned by their creator.
+	DB.Exec(`INSERT INTO project_members (project_id, user_id, role, created_at)
+		SELECT id, user_id, ?, created_at FROM projects
+		WHERE user_id <> 0 AND id NOT IN (SELECT project_id FROM project_members)`, models.RoleOwner)
+
+	// Projects from before workspaces move to their creator's personal one.
+	var creators []uint
+	DB.Model(&models.Project{}).Where("workspace_id = 0 OR workspace_id IS NULL").Distinct().Pluck("user_id", &creators)
+	for _, userID := range creators {
+		workspace, err := models.PersonalWorkspace(DB, userID)
+		if err != nil {
+			log.Println("personal workspace:", err)
+			continue
+		}
+		DB.Model(&models.Project{}).
+			Where("user_id = ? AND (workspace_id = 0 OR workspace_id IS NULL)", userID).
+			Update("workspace_id", workspace.ID)
+	}
+
+	// Accounts from before personal workspaces were made at sign-up.
+	var homeless []uint
+	DB.Model(&models.User{}).
+		Where("id NOT IN (SELECT personal_user_id FROM workspaces WHERE personal_user_id IS NOT NULL)").
+		Pluck("id", &homeless)
+	for _, userID := range homeless {
+		if _, err := models.PersonalWorkspace(DB, userID); err != nil {
+			log.Println("personal workspace:", err)
+		}
+	}
+
+	// Invitations from before addresses were normalized.
+	DB.Exec(`UPDATE project_invitations SET email = lower(trim(email)) WHERE email <> lower(trim(email))`)
+
+	// Tasks from before workflows get the category of the matching default
+	// state; anything unrecognised counts as not started.
+	DB.Exec(`UPDATE tasks SET status_category = CASE
+			WHEN lower(status) = ? THEN ?
+			WHEN lower(status) = ? THEN ?
+			ELSE ? END
+		WHERE status_category IS NULL OR status_category = ''`,
+		"done", models.CategoryDone, "in_progress", models.CategoryInProgress, models.CategoryTodo)
+
+	utcDueDates()
+	orphanedTasks()
+
+	if grandfatherEmails {
+		DB.Model(&models.User{}).Where("email_verified_at IS NULL").Update("email_verified_at", time.Now())
+	}
+}
+
+// orphanedTasks removes tasks, with everything on them, left behind by
+// projects deleted before deletes cascaded. No query can reach them.
+func orphanedTasks() {
+	const tasks = `SELECT id FROM tasks WHERE project_id NOT IN (SELECT id FROM projects)`
+	const comments = `SELECT id FROM comments WHERE task_id IN (` + tasks + `)`
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range []string{
+			`DELETE FROM comment_revisions WHERE comment_id IN (` + comments + `)`,
+			`DELETE FROM comment_mentions WHERE comment_id IN (` + comments + `)`,
+			`DELETE FROM comments WHERE task_id IN (` + tasks + `)`,
+			`DELETE FROM checklist_items WHERE task_id IN (` + tasks + `)`,
+			`DELETE FROM task_labels WHERE task_id IN (` + tasks + `)`,
+			`DELETE FROM task_assignees WHERE task_id IN (` + tasks + `)`,
+			`DELETE FROM tasks WHERE id IN (` + tasks + `)`,
+		} {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println("orphaned tasks:", err)
+	}
+}
+
+// utcDueDates rewrites due dates stored with the server's offset, from
+// before they were written in UTC. Date queries compare the stored text, so
+// those tasks would otherwise land on the wrong day. Once rewritten a row
+// ends in +00:00 and is left alone.
+func utcDueDates() {
+	var tasks []models.Task
+	if err := DB.Unscoped().Select("id", "due_date").
+		Where("due_date IS NOT NULL AND due_date NOT LIKE ?", "%+00:00").
+		Find(&tasks).Error; err != nil {
+		log.Println("due dates to UTC:", err)
+		return
+	}
+
+	for _, task := range tasks {
+		DB.Unscoped().Model(&models.Task{}).
+			Where("id = ?", task.ID).
+			UpdateColumn("due_date", task.DueDate.UTC())
+	}
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"flowday/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateRewritesDueDatesInUTC(t *testing.T) {
+	database, err := gorm.Open(sqlite.Open(":memory:?_foreign_keys=on"), &gorm.Config{})
+	require.NoError(t, err)
+	DB = database
+	Migrate()
+
+	// written with time.Local before due dates were stored in UTC
+	DB.Exec(`INSERT INTO projects (id, name) VALUES (1, 'Old')`)
+	DB.Exec(`INSERT INTO tasks (id, title, project_id, due_date) VALUES (1, 'Old', 1, '2024-03-10 23:30:00-05:00')`)
+	DB.Exec(`INSERT INTO tasks (id, title, project_id, due_date) VALUES (2, 'New', 1, '2024-03-11 04:30:00+00:00')`)
+
+	Migrate()
+
+	var dueDates []string
+	DB.Raw(`SELECT CAST(due_date AS TEXT) FROM tasks ORDER BY id`).Scan(&dueDates)
+	assert.Equal(t, []string{"2024-03-11 04:30:00+00:00", "2024-03-11 04:30:00+00:00"}, dueDates)
+}
+
+func TestMigrateGivesOldAccountsAPersonalWorkspace(t *testing.T) {
+	database, err := gorm.Open(sqlite.Open(":memory:?_foreign_keys=on"), &gorm.Config{})
+	require.NoError(t, err)
+	DB = database
+	Migrate()
+
+	// signed up before personal workspaces were made at registration
+	DB.Exec(`INSERT INTO users (id, email) VALUES (1, 'old@example.com')`)
+
+	Migrate()
+
+	var workspace models.Workspace
+	require.NoError(t, DB.Where("personal_user_id = ?", 1).First(&workspace).Error)
+	var members int64
+	DB.Model(&models.WorkspaceMember{}).Where("workspace_id = ? AND user_id = ?", workspace.ID, 1).Count(&members)
+	assert.EqualValues(t, 1, members)
+}
+
+func TestMigrateRemovesOrphanedTasks(t *testing.T) {
+	database, err := gorm.Open(sqlite.Open(":memory:?_foreign_keys=on"), &gorm.Config{})
+	require.NoError(t, err)
+	DB = database
+	Migrate()
+
+	// left behind when deleting a project didn't take its tasks along
+	DB.Exec(`INSERT INTO projects (id, name) VALUES (1, 'Kept')`)
+	DB.Exec(`INSERT INTO tasks (id, title, project_id) VALUES (1, 'Kept', 1)`)
+	DB.Exec(`PRAGMA foreign_keys = OFF`)
+	DB.Exec(`INSERT INTO tasks (id, title, project_id) VALUES (2, 'Orphan', 2)`)
+	DB.Exec(`INSERT INTO checklist_items (task_id, text) VALUES (2, 'Orphan')`)
+	DB.Exec(`PRAGMA foreign_keys = ON`)
+
+	Migrate()
+
+	var tasks []uint
+	DB.Unscoped().Model(&models.Task{}).Pluck("id", &tasks)
+	assert.Equal(t, []uint{1}, tasks)
+	var items int64
+	DB.Model(&models.ChecklistItem{}).Count(&items)
+	assert.Zero(t, items)
+}
+
+func TestMigrateRebuildsTablesWithoutCascading(t *testing.T) {
+	database, err := gorm.Open(sqlite.Open(":memory:?_foreign_keys=on"), &gorm.Config{})
+	require.NoError(t, err)
+	DB = database
+	Migrate()
+
+	// a database from before tasks had their project constraint
+	require.NoError(t, DB.Migrator().DropConstraint(&models.Task{}, "Project"))
+	DB.Exec(`INSERT INTO projects (id, name) VALUES (1, 'Kept')`)
+	DB.Exec(`INSERT INTO tasks (id, title, project_id) VALUES (1, 'Kept', 1)`)
+	DB.Exec(`INSERT INTO checklist_items (task_id, text) VALUES (1, 'Kept')`)
+
+	Migrate()
+
+	assert.True(t, DB.Migrator().HasConstraint(&models.Task{}, "Project"))
+	var items int64
+	DB.Model(&models.ChecklistItem{}).Count(&items)
+	assert.EqualValues(t, 1, items)
+}
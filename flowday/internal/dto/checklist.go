@@ -0,0 +1,15 @@
+package dto
+
+// AddChecklistItemRequest appends the item unless a position is given.
+type AddChecklistItemRequest struct {
+	Text     string `json:"text" binding:"required,max=500"`
+	Position *int   `json:"position" binding:"omitempty,min=0"`
+}
+
+// UpdateChecklistItemRequest changes only the fields that are present;
+// position moves the item within the checklist.
+type UpdateChecklistItemRequest struct {
+	Text     *string `json:"text" binding:"omitempty,min=1,max=500"`
+	Checked  *bool   `json:"checked"`
+	Position *int    `json:"position" binding:"omitempty,min=0"`
+}
@@ -0,0 +1,12 @@
+package dto
+
+// CreateCommentRequest starts a thread, or replies to one when parent_id
+// is set.
+type CreateCommentRequest struct {
+	Body     string `json:"body" binding:"required,max=10000"`
+	ParentID *uint  `json:"parent_id"`
+}
+
+type UpdateCommentRequest struct {
+	Body string `json:"body" binding:"required,max=10000"`
+}
@@ -0,0 +1,29 @@
+package dto
+
+import (
+	"fmt"
+
+	appErrors "flowday/internal/errors"
+)
+
+// CreateLabelRequest makes a personal label unless a project is given.
+type CreateLabelRequest struct {
+	Name      string `json:"name" binding:"required,max=50"`
+	Color     string `json:"color" binding:"omitempty,hexcolor"`
+	ProjectID uint   `json:"project_id"`
+}
+
+// UpdateLabelRequest changes only the fields that are present. A null
+// color clears it.
+type UpdateLabelRequest struct {
+	Name  *string          `json:"name" binding:"omitempty,min=1,max=50"`
+	Color Optional[string] `json:"color"`
+}
+
+// Validate checks the color, which struct tags can't reach.
+func (r *UpdateLabelRequest) Validate() error {
+	if r.Color.Set && !r.Color.Null && !hexColor.MatchString(r.Color.Value) {
+		return fmt.Errorf("%w: color must be a hex color", appErrors.ErrInvalidInput)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package dto
+
+import "encoding/json"
+
+// Optional is a field of a JSON Merge Patch (RFC 7386). It tells a missing
+// member (leave as is) apart from an explicit null (clear) and a value.
+type Optional[T any] struct {
+	Set   bool
+	Null  bool
+	Value T
+}
+
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		o.Null = true
+		return nil
+	}
+	return json.Unmarshal(data, &o.Value)
+}
+
+// Apply records the field in a column update map: nil for null, the value
+// otherwise, nothing when the member was missing.
+func (o Optional[T]) Apply(updates map[string]interface{}, column string) {
+	if !o.Set {
+		return
+	}
+	if o.Null {
+		updates[column] = nil
+		return
+	}
+	updates[column] = o.Value
+}
@@ -1,5 +1,38 @@
 package dto
 
+import (
+	"fmt"
+	"regexp"
+
+	appErrors "flowday/internal/errors"
+)
+
+// hexColor matches what the hexcolor binding accepts.
+var hexColor = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{4}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+
+// CreateProjectRequest puts the project in the user's personal workspace
+// when no workspace is given.
 type CreateProjectRequest struct {
-	Name string `json:"name" binding:"required"`
-}
\ No newline at end of file
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description" binding:"max=2000"`
+	Color       string `json:"color" binding:"omitempty,hexcolor"`
+	Icon        string `json:"icon" binding:"max=32"`
+	WorkspaceID uint   `json:"workspace_id"`
+}
+
+// UpdateProjectRequest changes only the fields that are present. A null
+// color clears it.
+type UpdateProjectRequest struct {
+	Name        *string          `json:"name" binding:"omitempty,min=1,max=100"`
+	Description *string          `json:"description" binding:"omitempty,max=2000"`
+	Color       Optional[string] `json:"color"`
+	Icon        *string          `json:"icon" binding:"omitempty,max=32"`
+}
+
+// Validate checks the color, which struct tags can't reach.
+func (r *UpdateProjectRequest) Validate() error {
+	if r.Color.Set && !r.Color.Null && !hexColor.MatchString(r.Color.Value) {
+		return fmt.Errorf("%w: color must be a hex color", appErrors.ErrInvalidInput)
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+package dto
+
+type AddProjectMemberRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required,oneof=owner editor commenter viewer"`
+}
+
+type UpdateProjectMemberRequest struct {
+	Role string `json:"role" binding:"required,oneof=owner editor commenter viewer"`
+}
@@ -0,0 +1,9 @@
+package dto
+
+// MoveTaskRequest reparents and reorders a task. A null parent_id makes it
+// a top-level task and a missing one keeps its parent. Without a position
+// the task goes after its new siblings.
+type MoveTaskRequest struct {
+	ParentID Optional[uint] `json:"parent_id"`
+	Position *int           `json:"position" binding:"omitempty,min=0"`
+}
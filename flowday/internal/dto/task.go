@@ -1,16 +1,82 @@
 package dto
 
-import "time"
+import (
+	"fmt"
+	"time"
 
+	appErrors "flowday/internal/errors"
+)
+
+// CreateTaskRequest: without a due date the task is due today, all day;
+// without a status it starts in the project's default state. A parent_id
+// makes it a subtask of a task in the same project.
 type CreateTaskRequest struct {
-	Title     string     `json:"title" binding:"required"`
-	Priority  string     `json:"priority"`
-	DueDate   *time.Time `json:"due_date"`
-	ProjectID uint       `json:"project_id" binding:"required"`
+	Title           string     `json:"title" binding:"required,max=200"`
+	Description     string     `json:"description" binding:"max=20000"`
+	Status          string     `json:"status" binding:"max=50"`
+	Priority        string     `json:"priority"`
+	StartDate       *time.Time `json:"start_date"`
+	DueDate         *time.Time `json:"due_date"`
+	AllDay          *bool      `json:"all_day"`
+	EstimateMinutes *int       `json:"estimate_minutes" binding:"omitempty,min=0"`
+	ProjectID       uint       `json:"project_id" binding:"required"`
+	ParentID        *uint      `json:"parent_id"`
 }
 
+// UpdateTaskRequest is a JSON Merge Patch: members that are left out stay
+// unchanged and null clears a field.
 type UpdateTaskRequest struct {
-	Status   *string    `json:"status"`
-	Priority *string    `json:"priority"`
-	DueDate  *time.Time `json:"due_date"`
+	Title           Optional[string]    `json:"title"`
+	Description     Optional[string]    `json:"description"`
+	Status          Optional[string]    `json:"status"`
+	Priority        Optional[string]    `json:"priority"`
+	StartDate       Optional[time.Time] `json:"start_date"`
+	DueDate         Optional[time.Time] `json:"due_date"`
+	AllDay          Optional[bool]      `json:"all_day"`
+	EstimateMinutes Optional[int]       `json:"estimate_minutes"`
+	CompletedAt     Optional[time.Time] `json:"completed_at"`
+}
+
+// Validate checks what struct tags can't express for merge patch fields.
+func (r *UpdateTaskRequest) Validate() error {
+	switch {
+	case r.Title.Set && (r.Title.Null || r.Title.Value == "" || len(r.Title.Value) > 200):
+		return fmt.Errorf("%w: title must be 1 to 200 characters", appErrors.ErrInvalidInput)
+	case r.Description.Set && len(r.Description.Value) > 20000:
+		return fmt.Errorf("%w: description is too long", appErrors.ErrInvalidInput)
+	case r.Status.Set && (r.Status.Null || r.Status.Value == ""):
+		return fmt.Errorf("%w: status cannot be cleared", appErrors.ErrInvalidInput)
+	case r.AllDay.Null:
+		return fmt.Errorf("%w: all_day cannot be null", appErrors.ErrInvalidInput)
+	case r.EstimateMinutes.Set && r.EstimateMinutes.Value < 0:
+		return fmt.Errorf("%w: estimate_minutes cannot be negative", appErrors.ErrInvalidInput)
+	}
+
+	// Timestamps are stored in UTC so date queries compare like with like.
+	r.StartDate.Value = r.StartDate.Value.UTC()
+	r.DueDate.Value = r.DueDate.Value.UTC()
+	r.CompletedAt.Value = r.CompletedAt.Value.UTC()
+	return nil
+}
+
+// Updates turns the patch into a column update map.
+func (r UpdateTaskRequest) Updates() map[string]interface{} {
+	updates := map[string]interface{}{}
+	r.Title.Apply(updates, "title")
+	r.Description.Apply(updates, "description")
+	r.Status.Apply(updates, "status")
+	r.Priority.Apply(updates, "priority")
+	r.StartDate.Apply(updates, "start_date")
+	r.DueDate.Apply(updates, "due_date")
+	r.AllDay.Apply(updates, "all_day")
+	r.EstimateMinutes.Apply(updates, "estimate_minutes")
+	r.CompletedAt.Apply(updates, "completed_at")
+
+	if r.Priority.Null {
+		updates["priority"] = ""
+	}
+	if r.Description.Null {
+		updates["description"] = ""
+	}
+	return updates
 }
@@ -0,0 +1,15 @@
+package dto
+
+type UpdateProfileRequest struct {
+	DisplayName *string `json:"display_name" binding:"omitempty,max=100"`
+	TimeZone    *string `json:"time_zone"`
+	Locale      *string `json:"locale"`
+	WeekStart   *string `json:"week_start" binding:"omitempty,oneof=sunday monday tuesday wednesday thursday friday saturday"`
+}
+
+// DeleteAccountRequest confirms the deletion: the password, or a TOTP code
+// for accounts that sign in through SSO only.
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
@@ -0,0 +1,40 @@
+package dto
+
+import "flowday/internal/models"
+
+type WorkflowStateRequest struct {
+	Key      string `json:"key" binding:"required,max=50"`
+	Name     string `json:"name" binding:"required,max=100"`
+	Category string `json:"category" binding:"required"`
+	Default  bool   `json:"default"`
+}
+
+type WorkflowTransitionRequest struct {
+	From string `json:"from" binding:"required"`
+	To   string `json:"to" binding:"required"`
+}
+
+// ReplaceWorkflowRequest lists states in display order. Leaving out
+// transitions allows every move between states.
+type ReplaceWorkflowRequest struct {
+	States      []WorkflowStateRequest      `json:"states" binding:"required,min=1,dive"`
+	Transitions []WorkflowTransitionRequest `json:"transitions" binding:"dive"`
+}
+
+func (r ReplaceWorkflowRequest) Models() ([]models.WorkflowState, []models.WorkflowTransition) {
+	states := make([]models.WorkflowState, 0, len(r.States))
+	for _, s := range r.States {
+		states = append(states, models.WorkflowState{
+			Key:       s.Key,
+			Name:      s.Name,
+			Category:  s.Category,
+			IsDefault: s.Default,
+		})
+	}
+
+	transitions := make([]models.WorkflowTransition, 0, len(r.Transitions))
+	for _, t := range r.Transitions {
+		transitions = append(transitions, models.WorkflowTransition{From: t.From, To: t.To})
+	}
+	return states, transitions
+}
@@ -0,0 +1,24 @@
+package dto
+
+type CreateWorkspaceRequest struct {
+	Name               string `json:"name" binding:"required,max=100"`
+	DefaultProjectRole string `json:"default_project_role" binding:"omitempty,oneof=owner editor commenter viewer"`
+}
+
+// UpdateWorkspaceRequest changes only the fields that are present. An empty
+// default_project_role stops granting access to new projects.
+type UpdateWorkspaceRequest struct {
+	Name               *string `json:"name" binding:"omitempty,min=1,max=100"`
+	MaxProjects        *int    `json:"max_projects" binding:"omitempty,min=0"`
+	MaxMembers         *int    `json:"max_members" binding:"omitempty,min=0"`
+	DefaultProjectRole *string `json:"default_project_role" binding:"omitempty,oneof=owner editor commenter viewer"`
+}
+
+type AddWorkspaceMemberRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required,oneof=admin member"`
+}
+
+type UpdateWorkspaceMemberRequest struct {
+	Role string `json:"role" binding:"required,oneof=admin member"`
+}
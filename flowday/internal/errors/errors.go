@@ -1,15 +1,32 @@
 package errors
 
-
 import (
 	"errors"
 )
 
 var (
-	ErrUnauthorized      = errors.New("unauthorized")
-	ErrInvalidCredentials = errors.New("invalid credentials")
-	ErrUserExists        = errors.New("user already exists")
-	ErrNotFound          = errors.New("not found")
-	ErrForbidden         = errors.New("forbidden")
-	ErrInvalidInput      = errors.New("invalid input")
-)
\ No newline at end of file
+	ErrUnauthorized        = errors.New("unauthorized")
+	ErrInvalidCredentials  = errors.New("invalid credentials")
+	ErrUserExists          = errors.New("user already exists")
+	ErrNotFound            = errors.New("not found")
+	ErrForbidden           = errors.New("forbidden")
+	ErrInvalidInput        = errors.New("invalid input")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrInvalidToken        = errors.New("invalid or expired token")
+	ErrEmailNotVerified    = errors.New("email not verified")
+	ErrInvalidCode         = errors.New("invalid verification code")
+	ErrTwoFactorEnabled    = errors.New("two-factor authentication already enabled")
+	ErrTwoFactorDisabled   = errors.New("two-factor authentication not enabled")
+	ErrTooManyAttempts     = errors.New("too many failed attempts, try again later")
+	ErrAlreadyMember       = errors.New("user is already a member")
+	ErrLastOwner           = errors.New("a project needs at least one owner")
+	ErrLastAdmin           = errors.New("a workspace needs at least one admin")
+	ErrQuotaExceeded       = errors.New("workspace quota exceeded")
+	ErrInvitationClosed    = errors.New("invitation is no longer pending")
+	ErrInvalidStatus       = errors.New("status is not part of the project's workflow")
+	ErrInvalidTransition   = errors.New("status change is not allowed by the project's workflow")
+	ErrInvalidWorkflow     = errors.New("invalid workflow")
+	ErrInvalidParent       = errors.New("invalid parent task")
+	ErrLabelExists         = errors.New("a label with that name already exists")
+	ErrNotProjectMember    = errors.New("user is not a member of the project")
+)
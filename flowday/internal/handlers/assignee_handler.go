@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"flowday/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AssignTask(c *gin.Context) {
+	taskID, _ := strconv.Atoi(c.Param("id"))
+	assigneeID, _ := strconv.Atoi(c.Param("user_id"))
+
+	if err := services.AssignTask(c.GetUint("user_id"), uint(taskID), uint(assigneeID)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func UnassignTask(c *gin.Context) {
+	taskID, _ := strconv.Atoi(c.Param("id"))
+	assigneeID, _ := strconv.Atoi(c.Param("user_id"))
+
+	if err := services.UnassignTask(c.GetUint("user_id"), uint(taskID), uint(assigneeID)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -16,13 +16,25 @@ func GetTasksByDate(c *gin.Context) {
 		return
 	}
 
-	date, err := time.Parse("2006-01-02", dateStr)
+	loc, err := requestLocation(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	date, err := time.ParseInLocation(dateLayout, dateStr, loc)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format"})
 		return
 	}
 
-	tasks, err := services.GetTasksByDate(c.GetUint("user_id"), date)
+	filter, err := taskFilter(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	tasks, err := services.GetTasksByDate(c.GetUint("user_id"), date, filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
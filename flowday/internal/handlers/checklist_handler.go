@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"flowday/internal/dto"
+	"flowday/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetChecklist(c *gin.Context) {
+	taskID, _ := strconv.Atoi(c.Param("id"))
+
+	items, err := services.GetChecklist(c.GetUint("user_id"), uint(taskID))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, items)
+}
+
+func AddChecklistItem(c *gin.Context) {
+	taskID, _ := strconv.Atoi(c.Param("id"))
+
+	var req dto.AddChecklistItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	item, err := services.AddChecklistItem(c.GetUint("user_id"), uint(taskID), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, item)
+}
+
+func UpdateChecklistItem(c *gin.Context) {
+	taskID, _ := strconv.Atoi(c.Param("id"))
+	itemID, _ := strconv.Atoi(c.Param("item_id"))
+
+	var req dto.UpdateChecklistItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	item, err := services.UpdateChecklistItem(c.GetUint("user_id"), uint(taskID), uint(itemID), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
+
+func DeleteChecklistItem(c *gin.Context) {
+	taskID, _ := strconv.Atoi(c.Param("id"))
+	itemID, _ := strconv.Atoi(c.Param("item_id"))
+
+	if err := services.DeleteChecklistItem(c.GetUint("user_id"), uint(taskID), uint(itemID)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"flowday/internal/dto"
+	"flowday/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetComments(c *gin.Context) {
+	taskID, _ := strconv.Atoi(c.Param("id"))
+
+	var q dto.PaginationQuery
+	_ = c.ShouldBindQuery(&q)
+
+	comments, err := services.GetComments(c.GetUint("user_id"), uint(taskID), q.Limit, q.Offset)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, comments)
+}
+
+func CreateComment(c *gin.Context) {
+	taskID, _ := strconv.Atoi(c.Param("id"))
+
+	var req dto.CreateCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	comment, err := services.CreateComment(c.GetUint("user_id"), uint(taskID), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, comment)
+}
+
+func UpdateComment(c *gin.Context) {
+	taskID, _ := strconv.Atoi(c.Param("id"))
+	commentID, _ := strconv.Atoi(c.Param("comment_id"))
+
+	var req dto.UpdateCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	comment, err := services.UpdateComment(c.GetUint("user_id"), uint(taskID), uint(commentID), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, comment)
+}
+
+func DeleteComment(c *gin.Context) {
+	taskID, _ := strconv.Atoi(c.Param("id"))
+	commentID, _ := strconv.Atoi(c.Param("comment_id"))
+
+	if err := services.DeleteComment(c.GetUint("user_id"), uint(taskID), uint(commentID)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func GetCommentRevisions(c *gin.Context) {
+	taskID, _ := strconv.Atoi(c.Param("id"))
+	commentID, _ := strconv.Atoi(c.Param("comment_id"))
+
+	revisions, err := services.GetCommentRevisions(c.GetUint("user_id"), uint(taskID), uint(commentID))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
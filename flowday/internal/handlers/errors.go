@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	appErrors "flowday/internal/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondError maps service errors to a status code; anything unknown is
+// an internal error.
+func respondError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+
+	switch {
+	case errors.Is(err, appErrors.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, appErrors.ErrForbidden), errors.Is(err, appErrors.ErrEmailNotVerified):
+		status = http.StatusForbidden
+	case errors.Is(err, appErrors.ErrInvalidInput), errors.Is(err, appErrors.ErrInvalidToken):
+		status = http.StatusBadRequest
+	case errors.Is(err, appErrors.ErrInvalidCredentials),
+		errors.Is(err, appErrors.ErrInvalidStatus),
+		errors.Is(err, appErrors.ErrInvalidTransition),
+		errors.Is(err, appErrors.ErrInvalidWorkflow),
+		errors.Is(err, appErrors.ErrInvalidParent),
+		errors.Is(err, appErrors.ErrNotProjectMember):
+		status = http.StatusUnprocessableEntity
+	case errors.Is(err, appErrors.ErrAlreadyMember),
+		errors.Is(err, appErrors.ErrLastOwner),
+		errors.Is(err, appErrors.ErrLastAdmin),
+		errors.Is(err, appErrors.ErrQuotaExceeded),
+		errors.Is(err, appErrors.ErrInvitationClosed),
+		errors.Is(err, appErrors.ErrLabelExists):
+		status = http.StatusConflict
+	case errors.Is(err, appErrors.ErrTooManyAttempts):
+		status = http.StatusTooManyRequests
+	}
+
+	c.JSON(status, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	appErrors "flowday/internal/errors"
+	"flowday/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// taskFilter reads the query parameters shared by the listing endpoints.
+// label takes comma separated label ids and may be repeated; assignee takes
+// a user id or "me".
+func taskFilter(c *gin.Context) (services.TaskFilter, error) {
+	includeArchived, _ := strconv.ParseBool(c.Query("include_archived"))
+	filter := services.TaskFilter{IncludeArchived: includeArchived}
+
+	for _, param := range c.QueryArray("label") {
+		for _, raw := range strings.Split(param, ",") {
+			id, err := strconv.ParseUint(strings.TrimSpace(raw), 10, 64)
+			if err != nil {
+				return filter, fmt.Errorf("%w: invalid label %q", appErrors.ErrInvalidInput, raw)
+			}
+			filter.LabelIDs = append(filter.LabelIDs, uint(id))
+		}
+	}
+
+	switch assignee := c.Query("assignee"); assignee {
+	case "":
+	case "me":
+		filter.AssigneeID = c.GetUint("user_id")
+	default:
+		id, err := strconv.ParseUint(assignee, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("%w: invalid assignee %q", appErrors.ErrInvalidInput, assignee)
+		}
+		filter.AssigneeID = uint(id)
+	}
+	return filter, nil
+}
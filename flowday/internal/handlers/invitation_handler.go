@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"flowday/internal/dto"
+	"flowday/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func InviteToProject(c *gin.Context) {
+	projectID, _ := strconv.Atoi(c.Param("id"))
+
+	var req dto.InviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	invitation, err := services.InviteToProject(c.GetUint("user_id"), uint(projectID), req.Email, req.Role)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, invitation)
+}
+
+func GetProjectInvitations(c *gin.Context) {
+	projectID, _ := strconv.Atoi(c.Param("id"))
+
+	invitations, err := services.GetProjectInvitations(c.GetUint("user_id"), uint(projectID))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+func RevokeInvitation(c *gin.Context) {
+	projectID, _ := strconv.Atoi(c.Param("id"))
+	invitationID, _ := strconv.Atoi(c.Param("invitation_id"))
+
+	if err := services.RevokeInvitation(c.GetUint("user_id"), uint(projectID), uint(invitationID)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func GetMyInvitations(c *gin.Context) {
+	invitations, err := services.GetMyInvitations(c.GetUint("user_id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+func AcceptInvitation(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	member, err := services.AcceptInvitation(c.GetUint("user_id"), uint(id))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
+func AcceptInvitationToken(c *gin.Context) {
+	var req dto.AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	member, err := services.AcceptInvitationToken(c.GetUint("user_id"), req.Token)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
+func DeclineInvitation(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	if err := services.DeclineInvitation(c.GetUint("user_id"), uint(id)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
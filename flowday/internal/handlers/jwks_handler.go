@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"net/http"
+
+	"flowday/internal/tokens"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetJWKS(c *gin.Context) {
+	// Short cache so verifiers notice a rotation well before the retired
+	// key stops being accepted.
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, tokens.Default().JWKS())
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"flowday/internal/dto"
+	"flowday/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetLabels(c *gin.Context) {
+	projectID, _ := strconv.Atoi(c.Query("project_id"))
+
+	labels, err := services.GetLabels(c.GetUint("user_id"), uint(projectID))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, labels)
+}
+
+func CreateLabel(c *gin.Context) {
+	var req dto.CreateLabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	label, err := services.CreateLabel(c.GetUint("user_id"), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, label)
+}
+
+func UpdateLabel(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var req dto.UpdateLabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	label, err := services.UpdateLabel(c.GetUint("user_id"), uint(id), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, label)
+}
+
+func DeleteLabel(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	if err := services.DeleteLabel(c.GetUint("user_id"), uint(id)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func AddTaskLabel(c *gin.Context) {
+	taskID, _ := strconv.Atoi(c.Param("id"))
+	labelID, _ := strconv.Atoi(c.Param("label_id"))
+
+	if err := services.AddTaskLabel(c.GetUint("user_id"), uint(taskID), uint(labelID)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func RemoveTaskLabel(c *gin.Context) {
+	taskID, _ := strconv.Atoi(c.Param("id"))
+	labelID, _ := strconv.Atoi(c.Param("label_id"))
+
+	if err := services.RemoveTaskLabel(c.GetUint("user_id"), uint(taskID), uint(labelID)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
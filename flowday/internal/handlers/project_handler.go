@@ -18,9 +18,9 @@ func CreateProject(c *gin.Context) {
 	}
 
 	userID := c.GetUint("user_id")
-	project, err := services.CreateProject(userID, req.Name)
+	project, err := services.CreateProject(userID, req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -29,18 +29,107 @@ func CreateProject(c *gin.Context) {
 
 func GetProjects(c *gin.Context) {
 	userID := c.GetUint("user_id")
-	projects, _ := services.GetProjects(userID)
+	workspaceID, _ := strconv.Atoi(c.Query("workspace_id"))
+
+	filter, err := taskFilter(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	projects, err := services.GetProjects(userID, uint(workspaceID), filter)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
 	c.JSON(http.StatusOK, projects)
 }
 
+func GetProject(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	project, err := services.GetProject(c.GetUint("user_id"), uint(id))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, project)
+}
+
+func UpdateProject(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var req dto.UpdateProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	project, err := services.UpdateProject(c.GetUint("user_id"), uint(id), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, project)
+}
+
+func ArchiveProject(c *gin.Context) {
+	setArchived(c, true)
+}
+
+func UnarchiveProject(c *gin.Context) {
+	setArchived(c, false)
+}
+
+func setArchived(c *gin.Context, archived bool) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	project, err := services.ArchiveProject(c.GetUint("user_id"), uint(id), archived)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, project)
+}
+
 func DeleteProject(c *gin.Context) {
 	userID := c.GetUint("user_id")
 	id, _ := strconv.Atoi(c.Param("id"))
 
 	if err := services.DeleteProject(userID, uint(id)); err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		respondError(c, err)
 		return
 	}
 
 	c.Status(http.StatusNoContent)
 }
+
+func GetTrash(c *gin.Context) {
+	projects, err := services.GetTrash(c.GetUint("user_id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, projects)
+}
+
+func RestoreProject(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	project, err := services.RestoreProject(c.GetUint("user_id"), uint(id))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, project)
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"flowday/internal/dto"
+	"flowday/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetProjectMembers(c *gin.Context) {
+	projectID, _ := strconv.Atoi(c.Param("id"))
+
+	members, err := services.GetProjectMembers(c.GetUint("user_id"), uint(projectID))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+func AddProjectMember(c *gin.Context) {
+	projectID, _ := strconv.Atoi(c.Param("id"))
+
+	var req dto.AddProjectMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	member, err := services.AddProjectMember(c.GetUint("user_id"), uint(projectID), req.Email, req.Role)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, member)
+}
+
+func UpdateProjectMember(c *gin.Context) {
+	projectID, _ := strconv.Atoi(c.Param("id"))
+	memberID, _ := strconv.Atoi(c.Param("user_id"))
+
+	var req dto.UpdateProjectMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	member, err := services.UpdateProjectMemberRole(c.GetUint("user_id"), uint(projectID), uint(memberID), req.Role)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
+func RemoveProjectMember(c *gin.Context) {
+	projectID, _ := strconv.Atoi(c.Param("id"))
+	memberID, _ := strconv.Atoi(c.Param("user_id"))
+
+	if err := services.RemoveProjectMember(c.GetUint("user_id"), uint(projectID), uint(memberID)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
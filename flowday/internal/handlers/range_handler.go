@@ -1,14 +1,14 @@
 package handlers
 
-
 import (
 	"net/http"
 	"time"
+
 	"flowday/internal/services"
+
 	"github.com/gin-gonic/gin"
 )
 
-
 func GetTasksByRange(c *gin.Context) {
 	fromStr := c.Query("from")
 	toStr := c.Query("to")
@@ -18,23 +18,35 @@ func GetTasksByRange(c *gin.Context) {
 		return
 	}
 
-	from, err := time.Parse("2006-01-02", fromStr)
+	loc, err := requestLocation(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	from, err := time.ParseInLocation(dateLayout, fromStr, loc)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date"})
 		return
 	}
 
-	to, err := time.Parse("2006-01-02", toStr)
+	to, err := time.ParseInLocation(dateLayout, toStr, loc)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to date"})
 		return
 	}
 
-	tasks, err := services.GetTaskByRange(c.GetUint("user_id"), from, to)
+	filter, err := taskFilter(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	tasks, err := services.GetTaskByRange(c.GetUint("user_id"), from, to, filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, tasks)
-}
\ No newline at end of file
+}
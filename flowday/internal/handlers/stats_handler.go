@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"net/http"
+	"time"
 
 	"flowday/internal/services"
 
@@ -9,7 +10,19 @@ import (
 )
 
 func GetTaskStats(c *gin.Context) {
-	stats, err := services.GetTaskStats(c.GetUint("user_id"))
+	loc, err := requestLocation(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	filter, err := taskFilter(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	stats, err := services.GetTaskStats(c.GetUint("user_id"), time.Now().In(loc), filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -19,48 +19,83 @@ func CreateTask(c *gin.Context) {
 		return
 	}
 
-	// Default due date to "Today" if not provided
-	var dueDate *time.Time
+	// Default due date to "Today" in the user's time zone if not provided.
+	// Dates are stored in UTC so date queries compare like with like.
+	var dueDate time.Time
+	allDay := req.AllDay != nil && *req.AllDay
 	if req.DueDate != nil {
-		dueDate = req.DueDate
+		dueDate = req.DueDate.UTC()
 	} else {
-		now := time.Now()
-		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
-		dueDate = &today
+		loc, err := requestLocation(c)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		now := time.Now().In(loc)
+		dueDate = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc).UTC()
+		allDay = req.AllDay == nil || *req.AllDay
+	}
+
+	var startDate *time.Time
+	if req.StartDate != nil {
+		start := req.StartDate.UTC()
+		startDate = &start
 	}
 
 	task := models.Task{
-		Title:     req.Title,
-		Priority:  req.Priority,
-		DueDate:   dueDate,
-		ProjectID: req.ProjectID,
-		Status:    "todo",
+		Title:           req.Title,
+		Description:     req.Description,
+		Priority:        req.Priority,
+		StartDate:       startDate,
+		DueDate:         &dueDate,
+		AllDay:          allDay,
+		EstimateMinutes: req.EstimateMinutes,
+		ProjectID:       req.ProjectID,
+		ParentID:        req.ParentID,
+		Status:          req.Status,
 	}
 
 	if err := services.CreateTask(c.GetUint("user_id"), &task); err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusCreated, task)
 }
 
+// GetTasks lists a project's tasks; with an assignee filter project_id may
+// be left out to list tasks across all of the user's projects.
 func GetTasks(c *gin.Context) {
+	filter, err := taskFilter(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
 	projectIDStr := c.Query("project_id")
-	if projectIDStr == "" {
+	if projectIDStr == "" && filter.AssigneeID == 0 {
 		c.JSON(400, gin.H{"error": "project_id is required"})
 		return
 	}
 
-	projectID, err := strconv.Atoi(projectIDStr)
-	if err != nil {
-		c.JSON(400, gin.H{"error": "invalid project_id"})
-		return
+	projectID := 0
+	if projectIDStr != "" {
+		projectID, err = strconv.Atoi(projectIDStr)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "invalid project_id"})
+			return
+		}
 	}
 
 	var q dto.PaginationQuery
 	_ = c.ShouldBindQuery(&q)
 
+	view := c.DefaultQuery("view", "flat")
+	if view != "flat" && view != "top" && view != "tree" {
+		c.JSON(400, gin.H{"error": "view must be flat, top or tree"})
+		return
+	}
+
 	tasks, err := services.GetTasksByProjectPaginated(
 		c.GetUint("user_id"),
 		uint(projectID),
@@ -68,15 +103,28 @@ func GetTasks(c *gin.Context) {
 		q.Offset,
 		q.Order,
 		q.Dir,
+		view,
+		filter,
 	)
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	c.JSON(200, tasks)
 }
 
+func GetTask(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	task, err := services.GetTask(c.GetUint("user_id"), uint(id))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
 
 func UpdateTask(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
@@ -87,30 +135,88 @@ func UpdateTask(c *gin.Context) {
 		return
 	}
 
-	updates := map[string]interface{}{}
-	if req.Status != nil {
-		updates["status"] = *req.Status
-	}
-	if req.Priority != nil {
-		updates["priority"] = *req.Priority
-	}
-	if req.DueDate != nil {
-		updates["due_date"] = req.DueDate
+	if err := req.Validate(); err != nil {
+		respondError(c, err)
+		return
 	}
 
+	updates := req.Updates()
 	if err := services.UpdateTask(c.GetUint("user_id"), uint(id), updates); err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	c.Status(http.StatusNoContent)
 }
 
+func GetSubtasks(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	subtasks, err := services.GetSubtasks(c.GetUint("user_id"), uint(id))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, subtasks)
+}
+
+func MoveTask(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var req dto.MoveTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err := services.MoveTask(c.GetUint("user_id"), uint(id), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
 func DeleteTask(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
 
 	if err := services.DeleteTask(c.GetUint("user_id"), uint(id)); err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func GetTrashedTasks(c *gin.Context) {
+	tasks, err := services.GetTrashedTasks(c.GetUint("user_id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tasks)
+}
+
+func RestoreTask(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	task, err := services.RestoreTask(c.GetUint("user_id"), uint(id))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+func PurgeTask(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	if err := services.PurgeTask(c.GetUint("user_id"), uint(id)); err != nil {
+		respondError(c, err)
 		return
 	}
 
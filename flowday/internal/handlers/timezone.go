@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	appErrors "flowday/internal/errors"
+	"flowday/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const dateLayout = "2006-01-02"
+
+// requestLocation picks the time zone for date handling: the tz query
+// parameter, then the X-Timezone header, then the user's profile.
+func requestLocation(c *gin.Context) (*time.Location, error) {
+	name := c.Query("tz")
+	if name == "" {
+		name = c.GetHeader("X-Timezone")
+	}
+	if name == "" {
+		return services.UserLocation(c.GetUint("user_id")), nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil || name == "Local" {
+		return nil, fmt.Errorf("%w: unknown time zone %q", appErrors.ErrInvalidInput, name)
+	}
+	return loc, nil
+}
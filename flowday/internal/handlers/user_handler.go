@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+
+	"flowday/internal/dto"
+	"flowday/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetProfile(c *gin.Context) {
+	user, err := services.GetProfile(c.GetUint("user_id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func UpdateProfile(c *gin.Context) {
+	var req dto.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := services.UpdateProfile(c.GetUint("user_id"), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func DeleteAccount(c *gin.Context) {
+	var req dto.DeleteAccountRequest
+	_ = c.ShouldBindJSON(&req)
+
+	if err := services.DeleteAccount(c.GetUint("user_id"), c.GetUint("session_id"), req.Password, req.Code, c.ClientIP()); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
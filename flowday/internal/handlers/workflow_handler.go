@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"flowday/internal/dto"
+	"flowday/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetWorkflow(c *gin.Context) {
+	projectID, _ := strconv.Atoi(c.Param("id"))
+
+	workflow, err := services.GetWorkflow(c.GetUint("user_id"), uint(projectID))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, workflow)
+}
+
+func ReplaceWorkflow(c *gin.Context) {
+	projectID, _ := strconv.Atoi(c.Param("id"))
+
+	var req dto.ReplaceWorkflowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	states, transitions := req.Models()
+	workflow, err := services.ReplaceWorkflow(c.GetUint("user_id"), uint(projectID), services.Workflow{
+		States:      states,
+		Transitions: transitions,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, workflow)
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"flowday/internal/dto"
+	"flowday/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetWorkspaces(c *gin.Context) {
+	workspaces, err := services.GetWorkspaces(c.GetUint("user_id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, workspaces)
+}
+
+func CreateWorkspace(c *gin.Context) {
+	var req dto.CreateWorkspaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	workspace, err := services.CreateWorkspace(c.GetUint("user_id"), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, workspace)
+}
+
+func GetWorkspace(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	workspace, err := services.GetWorkspace(c.GetUint("user_id"), uint(id))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, workspace)
+}
+
+func UpdateWorkspace(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var req dto.UpdateWorkspaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	workspace, err := services.UpdateWorkspace(c.GetUint("user_id"), uint(id), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, workspace)
+}
+
+func GetWorkspaceMembers(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	members, err := services.GetWorkspaceMembers(c.GetUint("user_id"), uint(id))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+func AddWorkspaceMember(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var req dto.AddWorkspaceMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	member, err := services.AddWorkspaceMember(c.GetUint("user_id"), uint(id), req.Email, req.Role)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, member)
+}
+
+func UpdateWorkspaceMember(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	memberID, _ := strconv.Atoi(c.Param("user_id"))
+
+	var req dto.UpdateWorkspaceMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	member, err := services.UpdateWorkspaceMemberRole(c.GetUint("user_id"), uint(id), uint(memberID), req.Role)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
+func RemoveWorkspaceMember(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	memberID, _ := strconv.Atoi(c.Param("user_id"))
+
+	if err := services.RemoveWorkspaceMember(c.GetUint("user_id"), uint(id), uint(memberID)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,120 @@
+package mailer
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"flowday/internal/config"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers plain text emails.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// SMTPMailer sends through an SMTP relay, authenticating when a username
+// is configured.
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	return smtp.SendMail(net.JoinHostPort(m.Host, m.Port), auth, m.From, []string{msg.To}, format(m.From, msg))
+}
+
+// LogMailer writes messages to a writer instead of delivering them. It is
+// meant for local development and tests.
+type LogMailer struct {
+	From string
+	Out  io.Writer
+	mu   sync.Mutex
+}
+
+// NewFileMailer appends every message to the file at path.
+func NewFileMailer(from, path string) (*LogMailer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &LogMailer{From: from, Out: f}, nil
+}
+
+func (m *LogMailer) Send(msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := fmt.Fprintf(m.Out, "%s\n", format(m.From, msg))
+	return err
+}
+
+func format(from string, msg Message) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + msg.To + "\r\n")
+	b.WriteString("Subject: " + msg.Subject + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(msg.Body)
+	return []byte(b.String())
+}
+
+var current Mailer = &LogMailer{From: "flowday@localhost", Out: os.Stdout}
+
+// Init picks the mailer used by Send from config. Without configuration
+// messages are printed to stdout.
+func Init(cfg config.Mail) error {
+	switch cfg.Driver {
+	case "smtp":
+		current = &SMTPMailer{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.From,
+		}
+	case "log", "":
+		if cfg.LogFile == "" {
+			current = &LogMailer{From: cfg.From, Out: os.Stdout}
+			return nil
+		}
+		m, err := NewFileMailer(cfg.From, cfg.LogFile)
+		if err != nil {
+			return err
+		}
+		current = m
+	default:
+		return fmt.Errorf("unknown mail driver %q", cfg.Driver)
+	}
+	return nil
+}
+
+// Use replaces the mailer, mainly so tests can capture messages.
+func Use(m Mailer) {
+	current = m
+}
+
+func Send(msg Message) error {
+	return current.Send(msg)
+}
@@ -0,0 +1,48 @@
+package mailer
+
+import (
+	"log"
+	"sync"
+)
+
+// Queue runs mail jobs on a fixed number of workers, so bursts of requests
+// don't turn into bursts of goroutines. Jobs that don't fit into the queue
+// are dropped.
+type Queue struct {
+	jobs    chan func() error
+	pending sync.WaitGroup
+}
+
+func NewQueue(workers, size int) *Queue {
+	q := &Queue{jobs: make(chan func() error, size)}
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	return q
+}
+
+// Enqueue schedules job and reports false when the queue is full.
+func (q *Queue) Enqueue(job func() error) bool {
+	q.pending.Add(1)
+	select {
+	case q.jobs <- job:
+		return true
+	default:
+		q.pending.Done()
+		return false
+	}
+}
+
+// Wait blocks until every job enqueued so far has run.
+func (q *Queue) Wait() {
+	q.pending.Wait()
+}
+
+func (q *Queue) work() {
+	for job := range q.jobs {
+		if err := job(); err != nil {
+			log.Println("mail job failed:", err)
+		}
+		q.pending.Done()
+	}
+}
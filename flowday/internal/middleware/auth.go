@@ -4,14 +4,13 @@ import (
 	"net/http"
 	"strings"
 
+	"flowday/internal/auth"
 	appErrors "flowday/internal/errors"
+	"flowday/internal/tokens"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtSecret = []byte("super-secret-key") // потом в env
-
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
@@ -31,23 +30,82 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		tokenStr := parts[1]
+		if strings.HasPrefix(parts[1], auth.PATPrefix) {
+			pat, err := auth.AuthenticatePersonalAccessToken(parts[1])
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+					"error": appErrors.ErrUnauthorized.Error(),
+				})
+				return
+			}
+
+			c.Set("user_id", pat.UserID)
+			c.Set("scopes", auth.TokenScopes(pat))
+			c.Next()
+			return
+		}
 
-		token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-			return jwtSecret, nil
-		})
+		claims, err := tokens.Verify(parts[1])
+		if err != nil || claims.Purpose != "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": appErrors.ErrUnauthorized.Error(),
+			})
+			return
+		}
 
-		if err != nil || !token.Valid {
+		// Tokens bound to a session die with it, even before they expire.
+		if claims.SessionID != 0 && !auth.SessionActive(claims.SessionID) {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"error": appErrors.ErrUnauthorized.Error(),
 			})
 			return
 		}
 
-		claims := token.Claims.(jwt.MapClaims)
-		userID := uint(claims["user_id"].(float64))
+		c.Set("user_id", claims.UserID)
+		c.Set("session_id", claims.SessionID)
+		c.Next()
+	}
+}
+
+// RequireScope limits a route for personal access tokens. Interactive logins
+// carry no scopes and are not restricted.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scopes, isToken := c.Get("scopes")
+		if isToken && !auth.ScopeAllows(scopes.([]string), scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "token lacks scope " + scope,
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireSession rejects personal access tokens, for routes that must only
+// be reachable from a real login (such as managing tokens themselves).
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, isToken := c.Get("scopes"); isToken {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": appErrors.ErrForbidden.Error(),
+			})
+			return
+		}
+		c.Next()
+	}
+}
 
-		c.Set("user_id", userID)
+// RequireVerifiedEmail blocks unverified accounts when the verification
+// policy is switched on.
+func RequireVerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if auth.EmailVerificationRequired() && !auth.IsEmailVerified(c.GetUint("user_id")) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": appErrors.ErrEmailNotVerified.Error(),
+			})
+			return
+		}
 		c.Next()
 	}
 }
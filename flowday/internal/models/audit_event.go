@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// AuditEvent records a security relevant event, e.g. a login lockout.
+type AuditEvent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Event     string    `gorm:"index" json:"event"`
+	UserID    *uint     `gorm:"index" json:"user_id"`
+	IP        string    `json:"ip"`
+	Detail    string    `json:"detail"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// ChecklistItem is one line of a task's checklist, ordered by Position.
+type ChecklistItem struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	TaskID    uint      `gorm:"index" json:"task_id"`
+	Task      *Task     `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	Text      string    `json:"text"`
+	Checked   bool      `json:"checked"`
+	Position  int       `json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ChecklistProgress counts a task's checklist items and how many of them
+// are checked.
+type ChecklistProgress struct {
+	Checked int64 `json:"checked"`
+	Total   int64 `json:"total"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Comment is a note on a task. Body is the Markdown as written and BodyHTML
+// its sanitized rendering. Replies point at the top-level comment of their
+// thread through ParentID. Author is nil once its account is deleted.
+type Comment struct {
+	ID        uint         `gorm:"primaryKey" json:"id"`
+	TaskID    uint         `gorm:"index" json:"task_id"`
+	Task      *Task        `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	UserID    uint         `gorm:"index" json:"user_id"`
+	Author    *PublicUser  `gorm:"foreignKey:UserID" json:"author"`
+	ParentID  *uint        `gorm:"index" json:"parent_id"`
+	Body      string       `gorm:"type:text" json:"body"`
+	BodyHTML  string       `gorm:"type:text" json:"body_html"`
+	Mentions  []PublicUser `gorm:"many2many:comment_mentions;joinReferences:UserID" json:"mentions"`
+	Replies   []Comment    `gorm:"-" json:"replies,omitempty"`
+	EditedAt  *time.Time   `json:"edited_at"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// CommentRevision keeps a comment's body as it was before an edit.
+type CommentRevision struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CommentID uint      `gorm:"index" json:"comment_id"`
+	Body      string    `gorm:"type:text" json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
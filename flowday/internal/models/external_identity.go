@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// ExternalIdentity links an account at an OIDC provider to a user.
+type ExternalIdentity struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"index" json:"user_id"`
+	Provider  string    `gorm:"uniqueIndex:idx_external_identity" json:"provider"`
+	Subject   string    `gorm:"uniqueIndex:idx_external_identity" json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OIDCLoginState remembers an authorization request until the provider
+// redirects back to us.
+type OIDCLoginState struct {
+	ID           uint   `gorm:"primaryKey"`
+	State        string `gorm:"uniqueIndex"`
+	Provider     string
+	CodeVerifier string
+	Nonce        string
+	ExpiresAt    time.Time
+	CreatedAt    time.Time
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Label tags tasks. A label belongs either to a user, who can put it on any
+// task they can edit and is the only one to see it, or to a project, whose
+// members share it.
+type Label struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`
+	UserID    *uint     `gorm:"index" json:"user_id,omitempty"`
+	ProjectID *uint     `gorm:"index" json:"project_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
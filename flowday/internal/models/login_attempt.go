@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// LoginAttempt tracks failed logins per throttling key ("account:<email>"
+// or "ip:<addr>") for deployments that share state through the database.
+// Version changes on every write so updates can detect concurrent ones.
+type LoginAttempt struct {
+	Key           string `gorm:"primaryKey;column:attempt_key"`
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+	Version       int `gorm:"not null;default:0"`
+}
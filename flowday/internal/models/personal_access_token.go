@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// PersonalAccessToken is a long-lived API token for scripts. Only its hash
+// is stored; Prefix is kept so users can tell their tokens apart.
+type PersonalAccessToken struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"index" json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	TokenHash  string     `gorm:"uniqueIndex" json:"-"`
+	Scopes     string     `json:"-"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
@@ -1,10 +1,34 @@
 package models
 
-import "time"
+import (
+	"time"
 
+	"gorm.io/gorm"
+)
+
+// Project lives in a workspace and is owned through ProjectMember; UserID
+// records who created it. Role is the requesting user's role when the
+// project is listed. Archived projects are hidden from lists, the calendar
+// and stats unless asked for. Deleted projects sit in the trash, with their
+// tasks, until restored or purged.
 type Project struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	Name      string    `json:"name"`
-	UserID    uint      `json:"user_id"`
-	CreatedAt time.Time `json:"created_at"`
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Color       string         `json:"color"`
+	Icon        string         `json:"icon"`
+	WorkspaceID uint           `gorm:"index" json:"workspace_id"`
+	UserID      uint           `json:"user_id"`
+	Role        string         `gorm:"->;-:migration" json:"role,omitempty"`
+	ArchivedAt  *time.Time     `gorm:"index" json:"archived_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+// AfterCreate makes the creator the project's first owner.
+func (p *Project) AfterCreate(tx *gorm.DB) error {
+	if p.UserID == 0 {
+		return nil
+	}
+	return tx.Create(&ProjectMember{ProjectID: p.ID, UserID: p.UserID, Role: RoleOwner}).Error
 }
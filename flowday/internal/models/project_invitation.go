@@ -0,0 +1,38 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Invitation statuses.
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationDeclined = "declined"
+	InvitationRevoked  = "revoked"
+)
+
+// ProjectInvitation offers a project role to an email address, stored as
+// NormalizeEmail gives it. InviteeID is set once an account with that
+// address exists. Only the hash of the mailed token is stored.
+type ProjectInvitation struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	ProjectID   uint       `gorm:"index" json:"project_id"`
+	Project     *Project   `gorm:"constraint:OnDelete:CASCADE" json:"project,omitempty"`
+	Email       string     `gorm:"index" json:"email"`
+	InviteeID   *uint      `gorm:"index" json:"invitee_id"`
+	InviterID   uint       `json:"inviter_id"`
+	Role        string     `json:"role"`
+	TokenHash   string     `gorm:"uniqueIndex" json:"-"`
+	Status      string     `gorm:"index" json:"status"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	RespondedAt *time.Time `json:"responded_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// NormalizeEmail lowercases and trims an address, so invitations match
+// accounts however either was typed.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
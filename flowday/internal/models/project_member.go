@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Project roles, from most to least privileged.
+const (
+	RoleOwner     = "owner"
+	RoleEditor    = "editor"
+	RoleCommenter = "commenter"
+	RoleViewer    = "viewer"
+)
+
+// ProjectMember grants a user a role on a project. Access to a project and
+// its tasks goes through membership only.
+type ProjectMember struct {
+	ID        uint        `gorm:"primaryKey" json:"-"`
+	ProjectID uint        `gorm:"uniqueIndex:idx_project_member" json:"project_id"`
+	Project   *Project    `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	UserID    uint        `gorm:"uniqueIndex:idx_project_member;index" json:"user_id"`
+	Role      string      `json:"role"`
+	User      *PublicUser `json:"user,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// RecoveryCode is a single-use 2FA fallback code, stored hashed.
+type RecoveryCode struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"index"`
+	CodeHash  string `gorm:"index"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Session is a server-side login session. The refresh token is rotated on
+// every use; only hashes of the current and previous value are stored.
+type Session struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	UserID            uint       `gorm:"index" json:"user_id"`
+	TokenHash         string     `gorm:"uniqueIndex" json:"-"`
+	PreviousTokenHash string     `gorm:"index" json:"-"`
+	UserAgent         string     `json:"user_agent"`
+	IP                string     `json:"ip"`
+	ExpiresAt         time.Time  `json:"expires_at"`
+	RevokedAt         *time.Time `json:"revoked_at"`
+	LastUsedAt        time.Time  `json:"last_used_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
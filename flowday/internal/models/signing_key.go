@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// SigningKey is a rotated JWT key shared by every instance through the
+// database. Retired keys keep verifying until RetiresAt. PrivateKey is PEM
+// sealed with the key encryption secret; keys stored before that was
+// required may still be plain PEM.
+type SigningKey struct {
+	ID         uint   `gorm:"primaryKey"`
+	KID        string `gorm:"uniqueIndex"`
+	Algorithm  string
+	PrivateKey string
+	RetiresAt  *time.Time `gorm:"index"`
+	CreatedAt  time.Time
+}
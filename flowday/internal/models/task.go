@@ -1,14 +1,49 @@
 package models
 
-import "time"
+import (
+	"time"
 
+	"gorm.io/gorm"
+)
+
+// Task belongs to a project. An all-day task is due on the calendar day of
+// DueDate (midnight in the user's time zone); otherwise DueDate is an exact
+// time. Status is a state of the project's workflow and StatusCategory that
+// state's category; CompletedAt is stamped when the task enters a done
+// state. Deleted tasks stay in the trash until restored or purged.
+//
+// A task with a ParentID is a subtask; Position orders it among its
+// siblings. Subtasks, Progress, Checklist, Labels and Assignees are filled
+// in when the task is loaded for display; labels and assignees are stored
+// through task_labels and task_assignees.
 type Task struct {
-	ID        uint       `gorm:"primaryKey" json:"id"`
-	Title     string     `json:"title"`
-	Status    string     `gorm:"index" json:"status"`
-	Priority  string     `gorm:"index" json:"priority"`
-	DueDate   *time.Time `gorm:"index" json:"due_date"`
-	ProjectID uint       `gorm:"index" json:"project_id"`
-	Project   *Project   `json:"project,omitempty"`
-	CreatedAt time.Time  `json:"created_at"`
+	ID              uint              `gorm:"primaryKey" json:"id"`
+	Title           string            `json:"title"`
+	Description     string            `gorm:"type:text" json:"description"`
+	Status          string            `gorm:"index" json:"status"`
+	StatusCategory  string            `gorm:"index" json:"status_category"`
+	Priority        string            `gorm:"index" json:"priority"`
+	StartDate       *time.Time        `gorm:"index" json:"start_date"`
+	DueDate         *time.Time        `gorm:"index" json:"due_date"`
+	AllDay          bool              `json:"all_day"`
+	EstimateMinutes *int              `json:"estimate_minutes"`
+	CompletedAt     *time.Time        `json:"completed_at"`
+	ProjectID       uint              `gorm:"index" json:"project_id"`
+	Project         *Project          `gorm:"constraint:OnDelete:CASCADE" json:"project,omitempty"`
+	ParentID        *uint             `gorm:"index" json:"parent_id"`
+	Position        int               `json:"position"`
+	Subtasks        []Task            `gorm:"-" json:"subtasks,omitempty"`
+	Progress        *TaskProgress     `gorm:"-" json:"progress,omitempty"`
+	Checklist       ChecklistProgress `gorm:"-" json:"checklist"`
+	Labels          []Label           `gorm:"many2many:task_labels" json:"labels"`
+	Assignees       []PublicUser      `gorm:"many2many:task_assignees;joinReferences:UserID" json:"assignees"`
+	DeletedAt       gorm.DeletedAt    `gorm:"index" json:"deleted_at,omitempty"`
+	CreatedAt       time.Time         `json:"created_at"`
+}
+
+// TaskProgress counts a task's direct subtasks and how many of them are in
+// a done state.
+type TaskProgress struct {
+	Done  int64 `json:"done"`
+	Total int64 `json:"total"`
 }
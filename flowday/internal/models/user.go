@@ -1,11 +1,45 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type User struct {
-	ID 		uint	 `gorm:"primaryKey"`
-	Email 	string	 `gorm:"uniqueIndex"`
-	Password string
-	CreatedAt time.Time
-	UpdatedAt time.Time
-}
\ No newline at end of file
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	Email           string     `gorm:"uniqueIndex" json:"email"`
+	Password        string     `json:"-"`
+	DisplayName     string     `json:"display_name"`
+	TimeZone        string     `gorm:"default:UTC" json:"time_zone"`
+	Locale          string     `gorm:"default:en" json:"locale"`
+	WeekStart       string     `gorm:"default:monday" json:"week_start"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	TOTPSecret      string     `json:"-"`
+	TOTPEnabledAt   *time.Time `json:"totp_enabled_at"`
+	TOTPLastStep    int64      `json:"-"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// AfterCreate gives every new account its personal workspace.
+func (u *User) AfterCreate(tx *gorm.DB) error {
+	_, err := PersonalWorkspace(tx, u.ID)
+	return err
+}
+
+// PublicUser is what other people get to see of a user: members, assignees,
+// comment authors and mentions. It reads from the users table.
+type PublicUser struct {
+	ID          uint   `json:"id"`
+	Email       string `json:"email"`
+	DisplayName string `json:"display_name"`
+}
+
+func (PublicUser) TableName() string {
+	return "users"
+}
+
+func (u *User) Public() *PublicUser {
+	return &PublicUser{ID: u.ID, Email: u.Email, DisplayName: u.DisplayName}
+}
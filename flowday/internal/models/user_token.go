@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// UserToken is a single-use token mailed to a user, e.g. for a password
+// reset. Only the hash is stored. Email carries the new address of an
+// email change.
+type UserToken struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"index"`
+	Purpose   string `gorm:"index"`
+	TokenHash string `gorm:"uniqueIndex"`
+	Email     string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Status categories group workflow states for stats and completion.
+const (
+	CategoryTodo       = "todo"
+	CategoryInProgress = "in_progress"
+	CategoryDone       = "done"
+)
+
+// WorkflowState is a status a project's tasks can be in. Key is what tasks
+// store in Status. New tasks start in the default state.
+type WorkflowState struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	ProjectID uint      `gorm:"uniqueIndex:idx_workflow_state" json:"-"`
+	Project   *Project  `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	Key       string    `gorm:"uniqueIndex:idx_workflow_state" json:"key"`
+	Name      string    `json:"name"`
+	Category  string    `json:"category"`
+	Position  int       `json:"position"`
+	IsDefault bool      `json:"default"`
+	CreatedAt time.Time `json:"-"`
+}
+
+// WorkflowTransition allows moving a task from one state to another. A
+// project without transitions allows every move.
+type WorkflowTransition struct {
+	ID        uint     `gorm:"primaryKey" json:"-"`
+	ProjectID uint     `gorm:"index" json:"-"`
+	Project   *Project `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	From      string   `gorm:"column:from_key" json:"from"`
+	To        string   `gorm:"column:to_key" json:"to"`
+}
+
+// DefaultWorkflow is used by projects that haven't defined their own.
+func DefaultWorkflow() []WorkflowState {
+	return []WorkflowState{
+		{Key: "todo", Name: "To do", Category: CategoryTodo, Position: 0, IsDefault: true},
+		{Key: "in_progress", Name: "In progress", Category: CategoryInProgress, Position: 1},
+		{Key: "done", Name: "Done", Category: CategoryDone, Position: 2},
+	}
+}
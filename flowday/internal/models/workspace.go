@@ -0,0 +1,64 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Workspace roles.
+const (
+	WorkspaceRoleAdmin  = "admin"
+	WorkspaceRoleMember = "member"
+)
+
+// Workspace groups projects and the people working on them. Every user has
+// a personal workspace for their own lists. Zero quotas mean no limit;
+// DefaultProjectRole is what workspace members get on new projects, or
+// nothing when empty.
+type Workspace struct {
+	ID                 uint      `gorm:"primaryKey" json:"id"`
+	Name               string    `json:"name"`
+	PersonalUserID     *uint     `gorm:"uniqueIndex" json:"-"`
+	Personal           bool      `json:"personal"`
+	MaxProjects        int       `json:"max_projects"`
+	MaxMembers         int       `json:"max_members"`
+	DefaultProjectRole string    `json:"default_project_role"`
+	Role               string    `gorm:"->;-:migration" json:"role,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+type WorkspaceMember struct {
+	ID          uint        `gorm:"primaryKey" json:"-"`
+	WorkspaceID uint        `gorm:"uniqueIndex:idx_workspace_member" json:"workspace_id"`
+	UserID      uint        `gorm:"uniqueIndex:idx_workspace_member;index" json:"user_id"`
+	Role        string      `json:"role"`
+	User        *PublicUser `json:"user,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+// PersonalWorkspace returns the user's personal workspace, creating it if
+// the user has none yet.
+func PersonalWorkspace(tx *gorm.DB, userID uint) (*Workspace, error) {
+	var workspace Workspace
+	err := tx.Where("personal_user_id = ?", userID).First(&workspace).Error
+	if err == nil {
+		return &workspace, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	workspace = Workspace{Name: "Personal", PersonalUserID: &userID, Personal: true}
+	err = tx.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&workspace).Error; err != nil {
+			return err
+		}
+		return tx.Create(&WorkspaceMember{WorkspaceID: workspace.ID, UserID: userID, Role: WorkspaceRoleAdmin}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &workspace, nil
+}
@@ -0,0 +1,27 @@
+package randtoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// New returns a random URL-safe token together with the hash that should be
+// persisted instead of the raw value.
+func New() (raw string, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+
+	raw = base64.RawURLEncoding.EncodeToString(buf)
+	return raw, Hash(raw), nil
+}
+
+// Hash returns the hex encoded SHA-256 of a token. Tokens are high entropy,
+// so a fast hash is enough to make a leaked table useless.
+func Hash(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,364 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"flowday/internal/auth"
+	"flowday/internal/config"
+	"flowday/internal/db"
+	"flowday/internal/mailer"
+	"flowday/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupTestMailer captures outgoing mail in a buffer
+var testMailQueue *mailer.Queue
+
+func setupTestMailer() *bytes.Buffer {
+	var outbox bytes.Buffer
+	mailer.Use(&mailer.LogMailer{From: "test@flowday", Out: &outbox})
+	testMailQueue = mailer.NewQueue(1, 16)
+	auth.UseMailQueue(testMailQueue)
+	return &outbox
+}
+
+// waitForMail blocks until queued account mails have been sent.
+func waitForMail() {
+	testMailQueue.Wait()
+}
+
+var tokenInLink = regexp.MustCompile(`token=([A-Za-z0-9_\-%]+)`)
+
+// lastMailedToken returns the token of the most recent link in the outbox
+func lastMailedToken(outbox *bytes.Buffer) string {
+	matches := tokenInLink.FindAllStringSubmatch(outbox.String(), -1)
+	if len(matches) == 0 {
+		return ""
+	}
+	return matches[len(matches)-1][1]
+}
+
+func postJSON(r *gin.Engine, path string, body interface{}) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	data, _ := json.Marshal(body)
+	req, _ := http.NewRequest("POST", path, bytes.NewBuffer(data))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestPasswordReset(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	setupTestDB()
+	auth.Init(config.DefaultAuth())
+	setupTestTokens()
+	outbox := setupTestMailer()
+
+	r := gin.Default()
+	Setup(r)
+
+	credentials := map[string]string{"email": "reset@example.com", "password": "old-password"}
+	postJSON(r, "/api/v1/auth/register", credentials)
+	refresh := func() string {
+		var login map[string]interface{}
+		json.Unmarshal(postJSON(r, "/api/v1/auth/login", credentials).Body.Bytes(), &login)
+		token, _ := login["refresh_token"].(string)
+		return token
+	}
+	session := refresh()
+	outbox.Reset()
+
+	t.Run("Unknown Email Looks The Same", func(t *testing.T) {
+		w := postJSON(r, "/api/v1/auth/forgot-password", map[string]string{"email": "nobody@example.com"})
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		waitForMail()
+		assert.Empty(t, outbox.String())
+	})
+
+	t.Run("Reset With Mailed Token", func(t *testing.T) {
+		w := postJSON(r, "/api/v1/auth/forgot-password", map[string]string{"email": "reset@example.com"})
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		waitForMail()
+
+		token := lastMailedToken(outbox)
+		assert.NotEmpty(t, token)
+
+		w = postJSON(r, "/api/v1/auth/reset-password", map[string]string{"token": token, "password": "new-password"})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		// single use
+		w = postJSON(r, "/api/v1/auth/reset-password", map[string]string{"token": token, "password": "other-password"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		// old sessions are gone, new password works
+		assert.Equal(t, http.StatusUnauthorized, postJSON(r, "/api/v1/auth/refresh", map[string]string{"refresh_token": session}).Code)
+		credentials["password"] = "new-password"
+		assert.NotEmpty(t, refresh())
+	})
+
+	t.Run("Only Latest Token Works", func(t *testing.T) {
+		postJSON(r, "/api/v1/auth/forgot-password", map[string]string{"email": "reset@example.com"})
+		waitForMail()
+		first := lastMailedToken(outbox)
+		postJSON(r, "/api/v1/auth/forgot-password", map[string]string{"email": "reset@example.com"})
+		waitForMail()
+
+		w := postJSON(r, "/api/v1/auth/reset-password", map[string]string{"token": first, "password": "another-password"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Requests Per Address Are Limited", func(t *testing.T) {
+		outbox.Reset()
+		for i := 0; i < 10; i++ {
+			w := postJSON(r, "/api/v1/auth/forgot-password", map[string]string{"email": "reset@example.com"})
+			assert.Equal(t, http.StatusAccepted, w.Code)
+		}
+		waitForMail()
+
+		// three of the five hourly mails went out above
+		assert.Equal(t, 2, strings.Count(outbox.String(), "Subject: Reset your Flowday password"))
+	})
+}
+
+func TestEmailVerification(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	setupTestDB()
+	setupTestTokens()
+	outbox := setupTestMailer()
+
+	policy := config.DefaultAuth()
+	policy.RequireVerifiedEmail = true
+	auth.Init(policy)
+	defer auth.Init(config.DefaultAuth())
+
+	r := gin.Default()
+	Setup(r)
+
+	credentials := map[string]string{"email": "verify@example.com", "password": "password123"}
+	w := postJSON(r, "/api/v1/auth/register", credentials)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var registered map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &registered)
+	assert.Equal(t, false, registered["email_verified"])
+	firstToken := lastMailedToken(outbox)
+	assert.NotEmpty(t, firstToken)
+
+	var login map[string]interface{}
+	json.Unmarshal(postJSON(r, "/api/v1/auth/login", credentials).Body.Bytes(), &login)
+	authHeader := "Bearer " + login["token"].(string)
+
+	createProject := func() int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/v1/projects", bytes.NewBufferString(`{"name": "Mine"}`))
+		req.Header.Set("Authorization", authHeader)
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("Unverified Cannot Create Projects", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, createProject())
+	})
+
+	t.Run("Resend Replaces Token", func(t *testing.T) {
+		w := postJSON(r, "/api/v1/auth/resend-verification", map[string]string{"email": "verify@example.com"})
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		waitForMail()
+		assert.NotEqual(t, firstToken, lastMailedToken(outbox))
+
+		w = postJSON(r, "/api/v1/auth/verify-email", map[string]string{"token": firstToken})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Resends Per Address Are Limited", func(t *testing.T) {
+		outbox.Reset()
+		for i := 0; i < 10; i++ {
+			w := postJSON(r, "/api/v1/auth/resend-verification", map[string]string{"email": "verify@example.com"})
+			assert.Equal(t, http.StatusAccepted, w.Code)
+		}
+		waitForMail()
+
+		// one of the five hourly mails went out above
+		assert.Equal(t, 4, strings.Count(outbox.String(), "Subject: Confirm your Flowday email address"))
+	})
+
+	t.Run("Verified Can Create Projects", func(t *testing.T) {
+		w := postJSON(r, "/api/v1/auth/verify-email", map[string]string{"token": lastMailedToken(outbox)})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, http.StatusCreated, createProject())
+	})
+}
+
+func TestAccountManagement(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	setupTestDB()
+	setupTestTokens()
+	outbox := setupTestMailer()
+	auth.Init(config.DefaultAuth())
+
+	r := gin.Default()
+	Setup(r)
+
+	credentials := map[string]string{"email": "account@example.com", "password": "password123"}
+	postJSON(r, "/api/v1/auth/register", credentials)
+
+	login := func() map[string]interface{} {
+		var body map[string]interface{}
+		json.Unmarshal(postJSON(r, "/api/v1/auth/login", credentials).Body.Bytes(), &body)
+		return body
+	}
+	current := login()
+	other := login()
+	authHeader := "Bearer " + current["token"].(string)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", authHeader)
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Update Profile", func(t *testing.T) {
+		w := send("PATCH", "/api/v1/me", `{"display_name": "Ada", "time_zone": "Europe/Berlin", "locale": "de-DE", "week_start": "sunday"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var profile map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &profile)
+		assert.Equal(t, "Ada", profile["display_name"])
+		assert.Equal(t, "Europe/Berlin", profile["time_zone"])
+		assert.Equal(t, "de-DE", profile["locale"])
+		assert.Equal(t, "sunday", profile["week_start"])
+
+		assert.Equal(t, http.StatusBadRequest, send("PATCH", "/api/v1/me", `{"time_zone": "Mars/Olympus"}`).Code)
+		assert.Equal(t, http.StatusBadRequest, send("PATCH", "/api/v1/me", `{"locale": "not a locale"}`).Code)
+		assert.Equal(t, http.StatusBadRequest, send("PATCH", "/api/v1/me", `{"week_start": "someday"}`).Code)
+	})
+
+	t.Run("Change Password Signs Out Other Sessions", func(t *testing.T) {
+		w := send("POST", "/api/v1/me/password", `{"current_password": "wrong", "new_password": "new-password"}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+		postJSON(r, "/api/v1/auth/forgot-password", map[string]string{"email": "account@example.com"})
+		waitForMail()
+		resetToken := lastMailedToken(outbox)
+		assert.NotEmpty(t, resetToken)
+
+		w = send("POST", "/api/v1/me/password", `{"current_password": "password123", "new_password": "new-password"}`)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		// a reset link mailed earlier can't undo the change
+		w = postJSON(r, "/api/v1/auth/reset-password", map[string]string{"token": resetToken, "password": "attacker-password"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = postJSON(r, "/api/v1/auth/refresh", map[string]string{"refresh_token": other["refresh_token"].(string)})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		w = postJSON(r, "/api/v1/auth/refresh", map[string]string{"refresh_token": current["refresh_token"].(string)})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		credentials["password"] = "new-password"
+		assert.NotNil(t, login()["token"])
+	})
+
+	t.Run("Change Email After Confirmation", func(t *testing.T) {
+		outbox.Reset()
+		w := send("POST", "/api/v1/me/email", `{"email": "renamed@example.com", "password": "new-password"}`)
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		token := lastMailedToken(outbox)
+		assert.NotEmpty(t, token)
+
+		// nothing changes until the link is used
+		assert.NotNil(t, login()["token"])
+
+		w = postJSON(r, "/api/v1/auth/confirm-email-change", map[string]string{"token": token})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Contains(t, outbox.String(), "To: account@example.com")
+
+		assert.Nil(t, login()["token"])
+		credentials["email"] = "renamed@example.com"
+		assert.NotNil(t, login()["token"])
+	})
+
+	t.Run("Delete Account", func(t *testing.T) {
+		assert.Equal(t, http.StatusCreated, send("POST", "/api/v1/projects", `{"name": "Doomed"}`).Code)
+		heir := models.User{Email: "heir@example.com"}
+		db.DB.Create(&heir)
+		db.DB.Create(&models.User{Email: "bystander@example.com"})
+
+		// a shared project in the trash is handed over and keeps the
+		// personal workspace it lives in
+		w := send("POST", "/api/v1/projects", `{"name": "Trashed Together"}`)
+		var trashed models.Project
+		json.Unmarshal(w.Body.Bytes(), &trashed)
+		db.DB.Create(&models.ProjectMember{ProjectID: trashed.ID, UserID: heir.ID, Role: models.RoleEditor})
+		assert.Equal(t, http.StatusNoContent, send("DELETE", fmt.Sprintf("/api/v1/projects/%d", trashed.ID), "").Code)
+
+		// the last admin of a team workspace has to hand it over first
+		w = send("POST", "/api/v1/workspaces", `{"name": "Team"}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var team models.Workspace
+		json.Unmarshal(w.Body.Bytes(), &team)
+		assert.Equal(t, http.StatusConflict, send("DELETE", "/api/v1/me", `{"password": "new-password"}`).Code)
+		teamMembers := fmt.Sprintf("/api/v1/workspaces/%d/members", team.ID)
+		assert.Equal(t, http.StatusCreated, send("POST", teamMembers, `{"email": "heir@example.com", "role": "admin"}`).Code)
+		assert.Equal(t, http.StatusCreated, send("POST", teamMembers, `{"email": "bystander@example.com", "role": "member"}`).Code)
+
+		// a project others still work in goes to the highest-ranked of them
+		w = send("POST", "/api/v1/projects", fmt.Sprintf(`{"name": "Handed Over", "workspace_id": %d}`, team.ID))
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var shared models.Project
+		json.Unmarshal(w.Body.Bytes(), &shared)
+		membersPath := fmt.Sprintf("/api/v1/projects/%d/members", shared.ID)
+		assert.Equal(t, http.StatusCreated, send("POST", membersPath, `{"email": "bystander@example.com", "role": "viewer"}`).Code)
+		assert.Equal(t, http.StatusCreated, send("POST", membersPath, `{"email": "heir@example.com", "role": "editor"}`).Code)
+
+		// comments there stay, without an author
+		w = send("POST", "/api/v1/tasks", fmt.Sprintf(`{"title": "Ship it", "project_id": %d}`, shared.ID))
+		var task models.Task
+		json.Unmarshal(w.Body.Bytes(), &task)
+		w = send("POST", fmt.Sprintf("/api/v1/tasks/%d/comments", task.ID), `{"body": "On it"}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var comment models.Comment
+		json.Unmarshal(w.Body.Bytes(), &comment)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, send("DELETE", "/api/v1/me", `{"password": "wrong"}`).Code)
+		assert.Equal(t, http.StatusNoContent, send("DELETE", "/api/v1/me", `{"password": "new-password"}`).Code)
+
+		var projects []models.Project
+		db.DB.Find(&projects)
+		if assert.Len(t, projects, 1) {
+			assert.Equal(t, shared.ID, projects[0].ID)
+		}
+		var heirship models.ProjectMember
+		db.DB.Where("project_id = ? AND user_id = ?", shared.ID, heir.ID).First(&heirship)
+		assert.Equal(t, models.RoleOwner, heirship.Role)
+		assert.NoError(t, db.DB.First(&models.Workspace{}, trashed.WorkspaceID).Error)
+		w = httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/v1/tasks/%d/comments", task.ID), nil)
+		req.Header.Set("Authorization", "Bearer "+createTestToken(heir.ID))
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var kept []models.Comment
+		json.Unmarshal(w.Body.Bytes(), &kept)
+		if assert.Len(t, kept, 1) {
+			assert.Equal(t, comment.ID, kept[0].ID)
+			assert.Nil(t, kept[0].Author)
+		}
+		assert.Nil(t, login()["token"])
+		assert.Equal(t, http.StatusUnauthorized, send("GET", "/api/v1/me", "").Code)
+	})
+}
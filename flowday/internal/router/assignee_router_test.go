@@ -0,0 +1,136 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"flowday/internal/models"
+	"flowday/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskAssignees(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	testDB := setupTestDB()
+	setupTestTokens()
+
+	r := gin.Default()
+	Setup(r)
+
+	testDB.Create(&models.User{ID: 1, Email: "lead@example.com"})
+	testDB.Create(&models.User{ID: 2, Email: "dev@example.com", DisplayName: "Dev"})
+	testDB.Create(&models.User{ID: 3, Email: "outsider@example.com"})
+
+	do := func(userID uint, method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+createTestToken(userID))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+	titles := func(userID uint, path string) []string {
+		var tasks []models.Task
+		json.Unmarshal(do(userID, "GET", path, "").Body.Bytes(), &tasks)
+		titles := []string{}
+		for _, task := range tasks {
+			titles = append(titles, task.Title)
+		}
+		return titles
+	}
+
+	var app, site models.Project
+	json.Unmarshal(do(1, "POST", "/api/v1/projects", `{"name": "App"}`).Body.Bytes(), &app)
+	json.Unmarshal(do(1, "POST", "/api/v1/projects", `{"name": "Site"}`).Body.Bytes(), &site)
+	testDB.Create(&models.ProjectMember{ProjectID: app.ID, UserID: 2, Role: models.RoleEditor})
+	testDB.Create(&models.ProjectMember{ProjectID: site.ID, UserID: 2, Role: models.RoleViewer})
+
+	create := func(title string, projectID uint) models.Task {
+		var task models.Task
+		json.Unmarshal(do(1, "POST", "/api/v1/tasks", fmt.Sprintf(`{"title": %q, "project_id": %d}`, title, projectID)).Body.Bytes(), &task)
+		return task
+	}
+	login := create("Login form", app.ID)
+	api := create("API", app.ID)
+	landing := create("Landing page", site.ID)
+	create("Unowned", site.ID)
+
+	assign := func(userID, taskID, assigneeID uint) int {
+		return do(userID, "PUT", fmt.Sprintf("/api/v1/tasks/%d/assignees/%d", taskID, assigneeID), "").Code
+	}
+
+	t.Run("Assign", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, assign(1, login.ID, 2))
+		assert.Equal(t, http.StatusNoContent, assign(1, login.ID, 1))
+		assert.Equal(t, http.StatusNoContent, assign(1, login.ID, 2))
+		assert.Equal(t, http.StatusNoContent, assign(2, api.ID, 2))
+		assert.Equal(t, http.StatusNoContent, assign(1, landing.ID, 2))
+
+		assert.Equal(t, http.StatusUnprocessableEntity, assign(1, api.ID, 3))
+		assert.Equal(t, http.StatusForbidden, assign(2, landing.ID, 1))
+
+		var task models.Task
+		json.Unmarshal(do(2, "GET", fmt.Sprintf("/api/v1/tasks/%d", login.ID), "").Body.Bytes(), &task)
+		assert.Len(t, task.Assignees, 2)
+		assert.Equal(t, "lead@example.com", task.Assignees[0].Email)
+
+		// assignees show who someone is, not their account settings
+		var raw struct {
+			Assignees []map[string]interface{} `json:"assignees"`
+		}
+		json.Unmarshal(do(2, "GET", fmt.Sprintf("/api/v1/tasks/%d", login.ID), "").Body.Bytes(), &raw)
+		if assert.NotEmpty(t, raw.Assignees) {
+			assert.NotContains(t, raw.Assignees[0], "totp_enabled_at")
+			assert.NotContains(t, raw.Assignees[0], "time_zone")
+		}
+	})
+
+	t.Run("Assigned To Me", func(t *testing.T) {
+		assert.Equal(t, []string{"Login form", "API", "Landing page"}, titles(2, "/api/v1/tasks?assignee=me&order=created_at&dir=asc"))
+		assert.Equal(t, []string{"Login form"}, titles(1, "/api/v1/tasks?assignee=me"))
+		assert.Equal(t, []string{"Landing page"}, titles(2, fmt.Sprintf("/api/v1/tasks?assignee=me&project_id=%d", site.ID)))
+		assert.Equal(t, http.StatusBadRequest, do(2, "GET", "/api/v1/tasks", "").Code)
+		assert.Equal(t, http.StatusBadRequest, do(2, "GET", "/api/v1/tasks?assignee=someone", "").Code)
+
+		today := time.Now().UTC().Format("2006-01-02")
+		assert.Len(t, titles(2, "/api/v1/tasks/by-date?assignee=me&date="+today), 3)
+		assert.Len(t, titles(1, fmt.Sprintf("/api/v1/tasks/by-range?assignee=me&from=%s&to=%s", today, today)), 1)
+	})
+
+	t.Run("Stats Per Assignee", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, do(2, "PATCH", fmt.Sprintf("/api/v1/tasks/%d", api.ID), `{"status": "done"}`).Code)
+
+		var stats services.TaskStats
+		json.Unmarshal(do(1, "GET", "/api/v1/tasks/stats", "").Body.Bytes(), &stats)
+		assert.Equal(t, int64(4), stats.Total)
+		assert.Equal(t, int64(1), stats.Unassigned)
+		assert.Len(t, stats.ByAssignee, 2)
+		// Tasks default to being due at midnight today, so open ones are overdue.
+		assert.Equal(t, services.AssigneeStats{UserID: 1, Email: "lead@example.com", Total: 1, Overdue: 1, Today: 1}, stats.ByAssignee[0])
+		assert.Equal(t, services.AssigneeStats{UserID: 2, Email: "dev@example.com", DisplayName: "Dev", Total: 3, Done: 1, Overdue: 2, Today: 3}, stats.ByAssignee[1])
+
+		json.Unmarshal(do(2, "GET", "/api/v1/tasks/stats?assignee=me", "").Body.Bytes(), &stats)
+		assert.Equal(t, int64(3), stats.Total)
+	})
+
+	t.Run("Unassign", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, do(1, "DELETE", fmt.Sprintf("/api/v1/tasks/%d/assignees/1", login.ID), "").Code)
+		assert.Equal(t, []string{}, titles(1, "/api/v1/tasks?assignee=me"))
+
+		// Leaving a project drops its assignments.
+		assert.Equal(t, http.StatusNoContent, do(1, "DELETE", fmt.Sprintf("/api/v1/projects/%d/members/2", site.ID), "").Code)
+		assert.Equal(t, []string{"Login form", "API"}, titles(2, "/api/v1/tasks?assignee=me&order=created_at&dir=asc"))
+
+		var task models.Task
+		json.Unmarshal(do(1, "GET", fmt.Sprintf("/api/v1/tasks/%d", landing.ID), "").Body.Bytes(), &task)
+		assert.Empty(t, task.Assignees)
+	})
+}
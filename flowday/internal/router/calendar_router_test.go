@@ -0,0 +1,117 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"flowday/internal/db"
+	"flowday/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeZones(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	setupTestDB()
+	setupTestTokens()
+
+	r := gin.Default()
+	Setup(r)
+
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("tz database not available")
+	}
+
+	db.DB.Create(&models.User{ID: 1, Email: "nyc@example.com", TimeZone: "America/New_York"})
+	project := models.Project{Name: "Clock", UserID: 1}
+	db.DB.Create(&project)
+
+	// 2026-03-08 is the spring-forward day in New York: 23 hours long
+	due := func(title string, t time.Time) {
+		utc := t.UTC()
+		db.DB.Create(&models.Task{Title: title, ProjectID: project.ID, Status: "todo", DueDate: &utc})
+	}
+	due("before", time.Date(2026, time.March, 7, 23, 30, 0, 0, newYork))
+	due("first", time.Date(2026, time.March, 8, 0, 15, 0, 0, newYork))
+	due("last", time.Date(2026, time.March, 8, 23, 45, 0, 0, newYork))
+	due("after", time.Date(2026, time.March, 9, 0, 15, 0, 0, newYork))
+
+	authHeader := "Bearer " + createTestToken(1)
+	get := func(path string, header map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", authHeader)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+	titles := func(w *httptest.ResponseRecorder) []string {
+		var tasks []models.Task
+		json.Unmarshal(w.Body.Bytes(), &tasks)
+		names := []string{}
+		for _, task := range tasks {
+			names = append(names, task.Title)
+		}
+		return names
+	}
+
+	t.Run("By Date Uses Profile Zone", func(t *testing.T) {
+		w := get("/api/v1/tasks/by-date?date=2026-03-08", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.ElementsMatch(t, []string{"first", "last"}, titles(w))
+	})
+
+	t.Run("Query Overrides Profile", func(t *testing.T) {
+		// 2026-03-08 in Tokyo ends at 10:00 on the 8th in New York
+		w := get("/api/v1/tasks/by-date?date=2026-03-08&tz=Asia/Tokyo", nil)
+		assert.ElementsMatch(t, []string{"before", "first"}, titles(w))
+	})
+
+	t.Run("Header Overrides Profile", func(t *testing.T) {
+		// late evening in New York is already the next day in UTC
+		w := get("/api/v1/tasks/by-date?date=2026-03-08", map[string]string{"X-Timezone": "UTC"})
+		assert.ElementsMatch(t, []string{"before", "first"}, titles(w))
+		w = get("/api/v1/tasks/by-date?date=2026-03-09", map[string]string{"X-Timezone": "UTC"})
+		assert.ElementsMatch(t, []string{"last", "after"}, titles(w))
+	})
+
+	t.Run("Unknown Zone", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, get("/api/v1/tasks/by-date?date=2026-03-08&tz=Nowhere/Special", nil).Code)
+	})
+
+	t.Run("Range Includes Whole Last Day", func(t *testing.T) {
+		w := get("/api/v1/tasks/by-range?from=2026-03-07&to=2026-03-08", nil)
+		assert.ElementsMatch(t, []string{"before", "first", "last"}, titles(w))
+	})
+
+	t.Run("Default Due Date Is Local Midnight", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		body := fmt.Sprintf(`{"title": "today", "project_id": %d}`, project.ID)
+		req, _ := http.NewRequest("POST", "/api/v1/tasks", bytes.NewBufferString(body))
+		req.Header.Set("Authorization", authHeader)
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var task models.Task
+		json.Unmarshal(w.Body.Bytes(), &task)
+		local := task.DueDate.In(newYork)
+		now := time.Now().In(newYork)
+		assert.Equal(t, now.Format("2006-01-02"), local.Format("2006-01-02"))
+		assert.Zero(t, local.Hour())
+
+		var stats map[string]int
+		json.Unmarshal(get("/api/v1/tasks/stats", nil).Body.Bytes(), &stats)
+		assert.Equal(t, 1, stats["today"])
+	})
+}
@@ -0,0 +1,124 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"flowday/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskChecklist(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	testDB := setupTestDB()
+	setupTestTokens()
+
+	r := gin.Default()
+	Setup(r)
+
+	testDB.Create(&models.User{ID: 1, Email: "owner@example.com"})
+	testDB.Create(&models.User{ID: 2, Email: "viewer@example.com"})
+
+	do := func(userID uint, method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+createTestToken(userID))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	var project models.Project
+	json.Unmarshal(do(1, "POST", "/api/v1/projects", `{"name": "Quality"}`).Body.Bytes(), &project)
+	testDB.Create(&models.ProjectMember{ProjectID: project.ID, UserID: 2, Role: models.RoleViewer})
+
+	var task models.Task
+	json.Unmarshal(do(1, "POST", "/api/v1/tasks", fmt.Sprintf(`{"title": "Release", "project_id": %d}`, project.ID)).Body.Bytes(), &task)
+	checklistPath := fmt.Sprintf("/api/v1/tasks/%d/checklist", task.ID)
+
+	add := func(body string) models.ChecklistItem {
+		w := do(1, "POST", checklistPath, body)
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var item models.ChecklistItem
+		json.Unmarshal(w.Body.Bytes(), &item)
+		return item
+	}
+	texts := func() []string {
+		var items []models.ChecklistItem
+		json.Unmarshal(do(2, "GET", checklistPath, "").Body.Bytes(), &items)
+		texts := []string{}
+		for _, item := range items {
+			texts = append(texts, item.Text)
+		}
+		return texts
+	}
+
+	tests := add(`{"text": "Tests pass"}`)
+	docs := add(`{"text": "Docs updated"}`)
+	add(`{"text": "Changelog", "position": 0}`)
+
+	t.Run("Add And Order", func(t *testing.T) {
+		assert.Equal(t, []string{"Changelog", "Tests pass", "Docs updated"}, texts())
+		assert.Equal(t, http.StatusBadRequest, do(1, "POST", checklistPath, `{"text": ""}`).Code)
+		assert.Equal(t, http.StatusForbidden, do(2, "POST", checklistPath, `{"text": "Nope"}`).Code)
+	})
+
+	t.Run("Toggle And Reorder", func(t *testing.T) {
+		w := do(1, "PATCH", fmt.Sprintf("%s/%d", checklistPath, tests.ID), `{"checked": true}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var item models.ChecklistItem
+		json.Unmarshal(w.Body.Bytes(), &item)
+		assert.True(t, item.Checked)
+		assert.Equal(t, "Tests pass", item.Text)
+
+		w = do(1, "PATCH", fmt.Sprintf("%s/%d", checklistPath, docs.ID), `{"position": 0}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []string{"Docs updated", "Changelog", "Tests pass"}, texts())
+	})
+
+	t.Run("Progress On Tasks", func(t *testing.T) {
+		var got models.Task
+		json.Unmarshal(do(2, "GET", fmt.Sprintf("/api/v1/tasks/%d", task.ID), "").Body.Bytes(), &got)
+		assert.Equal(t, models.ChecklistProgress{Checked: 1, Total: 3}, got.Checklist)
+
+		var byDate []models.Task
+		today := time.Now().UTC().Format("2006-01-02")
+		json.Unmarshal(do(2, "GET", "/api/v1/tasks/by-date?date="+today, "").Body.Bytes(), &byDate)
+		assert.Len(t, byDate, 1)
+		assert.Equal(t, int64(3), byDate[0].Checklist.Total)
+
+		var list []map[string]interface{}
+		json.Unmarshal(do(2, "GET", fmt.Sprintf("/api/v1/tasks?project_id=%d", project.ID), "").Body.Bytes(), &list)
+		assert.Equal(t, map[string]interface{}{"checked": float64(1), "total": float64(3)}, list[0]["checklist"])
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, do(1, "DELETE", fmt.Sprintf("%s/%d", checklistPath, docs.ID), "").Code)
+		assert.Equal(t, []string{"Changelog", "Tests pass"}, texts())
+		assert.Equal(t, http.StatusNotFound, do(1, "DELETE", fmt.Sprintf("%s/%d", checklistPath, docs.ID), "").Code)
+
+		var items []models.ChecklistItem
+		json.Unmarshal(do(2, "GET", checklistPath, "").Body.Bytes(), &items)
+		assert.Equal(t, 0, items[0].Position)
+		assert.Equal(t, 1, items[1].Position)
+	})
+
+	t.Run("Purged With The Task", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, do(1, "DELETE", fmt.Sprintf("/api/v1/tasks/%d", task.ID), "").Code)
+		assert.Equal(t, http.StatusNoContent, do(1, "DELETE", fmt.Sprintf("/api/v1/tasks/%d/purge", task.ID), "").Code)
+
+		var count int64
+		testDB.Model(&models.ChecklistItem{}).Count(&count)
+		assert.Zero(t, count)
+	})
+}
@@ -0,0 +1,164 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"flowday/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskComments(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	testDB := setupTestDB()
+	setupTestTokens()
+
+	r := gin.Default()
+	Setup(r)
+
+	testDB.Create(&models.User{ID: 1, Email: "owner@example.com"})
+	testDB.Create(&models.User{ID: 2, Email: "ann@example.com", DisplayName: "Ann Lee"})
+	testDB.Create(&models.User{ID: 3, Email: "viewer@example.com"})
+	testDB.Create(&models.User{ID: 4, Email: "stranger@example.com"})
+
+	do := func(userID uint, method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+createTestToken(userID))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	var project models.Project
+	json.Unmarshal(do(1, "POST", "/api/v1/projects", `{"name": "Docs"}`).Body.Bytes(), &project)
+	testDB.Create(&models.ProjectMember{ProjectID: project.ID, UserID: 2, Role: models.RoleCommenter})
+	testDB.Create(&models.ProjectMember{ProjectID: project.ID, UserID: 3, Role: models.RoleViewer})
+
+	var task models.Task
+	json.Unmarshal(do(1, "POST", "/api/v1/tasks", fmt.Sprintf(`{"title": "Write guide", "project_id": %d}`, project.ID)).Body.Bytes(), &task)
+	commentsPath := fmt.Sprintf("/api/v1/tasks/%d/comments", task.ID)
+
+	post := func(userID uint, body string) models.Comment {
+		w := do(userID, "POST", commentsPath, body)
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var comment models.Comment
+		json.Unmarshal(w.Body.Bytes(), &comment)
+		return comment
+	}
+
+	first := post(2, `{"body": "Draft is **ready**, @owner please review. cc @AnnLee @viewer@example.com @stranger <script>alert(1)</script>"}`)
+
+	t.Run("Markdown And Mentions", func(t *testing.T) {
+		assert.Equal(t, "ann@example.com", first.Author.Email)
+		assert.Contains(t, first.BodyHTML, "<strong>ready</strong>")
+		assert.NotContains(t, first.BodyHTML, "<script>")
+		assert.Contains(t, first.Body, "<script>")
+
+		var mentioned []string
+		for _, user := range first.Mentions {
+			mentioned = append(mentioned, user.Email)
+		}
+		assert.Equal(t, []string{"owner@example.com", "ann@example.com", "viewer@example.com"}, mentioned)
+
+		plain := post(1, `{"body": "Mail docs@example.com when done"}`)
+		assert.Empty(t, plain.Mentions)
+
+		// authors and mentions show who someone is, not their account settings
+		var raw []struct {
+			Author   map[string]interface{}   `json:"author"`
+			Mentions []map[string]interface{} `json:"mentions"`
+		}
+		json.Unmarshal(do(3, "GET", commentsPath, "").Body.Bytes(), &raw)
+		if assert.NotEmpty(t, raw) && assert.NotEmpty(t, raw[0].Mentions) {
+			assert.NotContains(t, raw[0].Author, "time_zone")
+			assert.NotContains(t, raw[0].Mentions[0], "totp_enabled_at")
+		}
+	})
+
+	t.Run("Permissions", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, do(3, "POST", commentsPath, `{"body": "Looks good"}`).Code)
+		assert.Equal(t, http.StatusNotFound, do(4, "GET", commentsPath, "").Code)
+		assert.Equal(t, http.StatusOK, do(3, "GET", commentsPath, "").Code)
+		assert.Equal(t, http.StatusBadRequest, do(2, "POST", commentsPath, `{"body": ""}`).Code)
+	})
+
+	t.Run("Threads", func(t *testing.T) {
+		reply := post(1, fmt.Sprintf(`{"body": "On it", "parent_id": %d}`, first.ID))
+		nested := post(2, fmt.Sprintf(`{"body": "Thanks", "parent_id": %d}`, reply.ID))
+		assert.Equal(t, first.ID, *nested.ParentID)
+
+		assert.Equal(t, http.StatusBadRequest, do(2, "POST", commentsPath, `{"body": "x", "parent_id": 999}`).Code)
+
+		var threads []models.Comment
+		json.Unmarshal(do(3, "GET", commentsPath, "").Body.Bytes(), &threads)
+		assert.Len(t, threads, 2)
+		assert.Len(t, threads[0].Replies, 2)
+		assert.Equal(t, "Thanks", threads[0].Replies[1].Body)
+
+		json.Unmarshal(do(3, "GET", commentsPath+"?limit=1&offset=1", "").Body.Bytes(), &threads)
+		assert.Len(t, threads, 1)
+		assert.Equal(t, "Mail docs@example.com when done", threads[0].Body)
+	})
+
+	commentPath := fmt.Sprintf("%s/%d", commentsPath, first.ID)
+
+	t.Run("Edit Keeps Revisions", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, do(1, "PATCH", commentPath, `{"body": "Hijacked"}`).Code)
+
+		w := do(2, "PATCH", commentPath, `{"body": "Draft is _ready_"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var edited models.Comment
+		json.Unmarshal(w.Body.Bytes(), &edited)
+		assert.Contains(t, edited.BodyHTML, "<em>ready</em>")
+		assert.NotNil(t, edited.EditedAt)
+		assert.Empty(t, edited.Mentions)
+
+		var revisions []models.CommentRevision
+		json.Unmarshal(do(2, "GET", commentPath+"/revisions", "").Body.Bytes(), &revisions)
+		assert.Len(t, revisions, 1)
+		assert.Equal(t, first.Body, revisions[0].Body)
+
+		// whoever can read the comment can read its history
+		w = do(3, "GET", commentPath+"/revisions", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		json.Unmarshal(w.Body.Bytes(), &revisions)
+		assert.Len(t, revisions, 1)
+		assert.Equal(t, http.StatusNotFound, do(4, "GET", commentPath+"/revisions", "").Code)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, do(3, "DELETE", commentPath, "").Code)
+
+		// Owners can delete anyone's comment; replies go with the thread.
+		assert.Equal(t, http.StatusNoContent, do(1, "DELETE", commentPath, "").Code)
+
+		var threads []models.Comment
+		json.Unmarshal(do(2, "GET", commentsPath, "").Body.Bytes(), &threads)
+		assert.Len(t, threads, 1)
+
+		var count int64
+		testDB.Model(&models.CommentRevision{}).Count(&count)
+		assert.Zero(t, count)
+		testDB.Table("comment_mentions").Count(&count)
+		assert.Zero(t, count)
+	})
+
+	t.Run("Purged With The Task", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, do(1, "DELETE", fmt.Sprintf("/api/v1/tasks/%d", task.ID), "").Code)
+		assert.Equal(t, http.StatusNoContent, do(1, "DELETE", fmt.Sprintf("/api/v1/tasks/%d/purge", task.ID), "").Code)
+
+		var count int64
+		testDB.Model(&models.Comment{}).Count(&count)
+		assert.Zero(t, count)
+	})
+}
@@ -0,0 +1,187 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"flowday/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProjectInvitations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	testDB := setupTestDB()
+	setupTestTokens()
+	outbox := setupTestMailer()
+
+	r := gin.Default()
+	Setup(r)
+
+	verified := time.Now()
+	owner, friend := uint(1), uint(2)
+	testDB.Create(&models.User{ID: owner, Email: "owner@example.com", EmailVerifiedAt: &verified})
+	testDB.Create(&models.User{ID: friend, Email: "friend@example.com", EmailVerifiedAt: &verified})
+
+	do := func(authHeader, method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", authHeader)
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+	ownerHeader := "Bearer " + createTestToken(owner)
+	friendHeader := "Bearer " + createTestToken(friend)
+
+	var project models.Project
+	json.Unmarshal(do(ownerHeader, "POST", "/api/v1/projects", `{"name": "Party"}`).Body.Bytes(), &project)
+	invitationsPath := fmt.Sprintf("/api/v1/projects/%d/invitations", project.ID)
+	taskBody := fmt.Sprintf(`{"title": "Cake", "project_id": %d}`, project.ID)
+
+	invite := func(email, role string) models.ProjectInvitation {
+		w := do(ownerHeader, "POST", invitationsPath, fmt.Sprintf(`{"email": %q, "role": %q}`, email, role))
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var invitation models.ProjectInvitation
+		json.Unmarshal(w.Body.Bytes(), &invitation)
+		return invitation
+	}
+
+	t.Run("Existing User Accepts From Their List", func(t *testing.T) {
+		outbox.Reset()
+		invite("friend@example.com", "editor")
+		assert.Contains(t, outbox.String(), "To: friend@example.com")
+
+		assert.Equal(t, http.StatusNotFound, do(friendHeader, "POST", invitationsPath, `{"email": "x@example.com", "role": "viewer"}`).Code)
+
+		var invitations []models.ProjectInvitation
+		json.Unmarshal(do(friendHeader, "GET", "/api/v1/invitations", "").Body.Bytes(), &invitations)
+		assert.Len(t, invitations, 1)
+		assert.Equal(t, "Party", invitations[0].Project.Name)
+
+		path := fmt.Sprintf("/api/v1/invitations/%d/accept", invitations[0].ID)
+		assert.Equal(t, http.StatusOK, do(friendHeader, "POST", path, "").Code)
+		assert.Equal(t, http.StatusConflict, do(friendHeader, "POST", path, "").Code)
+		assert.Equal(t, http.StatusCreated, do(friendHeader, "POST", "/api/v1/tasks", taskBody).Code)
+
+		// editors cannot invite
+		assert.Equal(t, http.StatusForbidden, do(friendHeader, "POST", invitationsPath, `{"email": "x@example.com", "role": "viewer"}`).Code)
+
+		assert.Equal(t, http.StatusConflict, do(ownerHeader, "POST", invitationsPath, `{"email": "friend@example.com", "role": "viewer"}`).Code)
+	})
+
+	t.Run("Newcomer Gets It On Registering", func(t *testing.T) {
+		outbox.Reset()
+		invite("NewComer@Example.com", "viewer")
+		token := lastMailedToken(outbox)
+
+		var pending []models.ProjectInvitation
+		json.Unmarshal(do(ownerHeader, "GET", invitationsPath, "").Body.Bytes(), &pending)
+		assert.Len(t, pending, 1)
+		assert.Nil(t, pending[0].InviteeID)
+
+		credentials := map[string]string{"email": "newcomer@example.com", "password": "password123"}
+		assert.Equal(t, http.StatusCreated, postJSON(r, "/api/v1/auth/register", credentials).Code)
+		var login map[string]interface{}
+		json.Unmarshal(postJSON(r, "/api/v1/auth/login", credentials).Body.Bytes(), &login)
+		newcomerHeader := "Bearer " + login["token"].(string)
+
+		var invitations []models.ProjectInvitation
+		json.Unmarshal(do(newcomerHeader, "GET", "/api/v1/invitations", "").Body.Bytes(), &invitations)
+		assert.Len(t, invitations, 1)
+
+		// anyone can register an address, so the list needs a verified one
+		path := fmt.Sprintf("/api/v1/invitations/%d/accept", invitations[0].ID)
+		assert.Equal(t, http.StatusForbidden, do(newcomerHeader, "POST", path, "").Code)
+
+		// nor does the link work before verifying, or for someone else
+		accept := fmt.Sprintf(`{"token": %q}`, token)
+		assert.Equal(t, http.StatusForbidden, do(newcomerHeader, "POST", "/api/v1/invitations/accept", accept).Code)
+		assert.Equal(t, http.StatusForbidden, do(friendHeader, "POST", "/api/v1/invitations/accept", accept).Code)
+
+		testDB.Model(&models.User{}).Where("id = ?", invitations[0].InviteeID).Update("email_verified_at", verified)
+		w := do(newcomerHeader, "POST", "/api/v1/invitations/accept", accept)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, http.StatusOK, do(newcomerHeader, "GET", fmt.Sprintf("/api/v1/tasks?project_id=%d", project.ID), "").Code)
+		assert.Equal(t, http.StatusForbidden, do(newcomerHeader, "POST", "/api/v1/tasks", taskBody).Code)
+	})
+
+	signUp := func(email string) string {
+		credentials := map[string]string{"email": email, "password": "password123"}
+		assert.Equal(t, http.StatusCreated, postJSON(r, "/api/v1/auth/register", credentials).Code)
+		var login map[string]interface{}
+		json.Unmarshal(postJSON(r, "/api/v1/auth/login", credentials).Body.Bytes(), &login)
+		return "Bearer " + login["token"].(string)
+	}
+	listInvitations := func(authHeader string) []models.ProjectInvitation {
+		var invitations []models.ProjectInvitation
+		json.Unmarshal(do(authHeader, "GET", "/api/v1/invitations", "").Body.Bytes(), &invitations)
+		return invitations
+	}
+
+	t.Run("Verified Owner Takes Over The Address", func(t *testing.T) {
+		invite("taken@example.com", "viewer")
+		squatterHeader := signUp("Taken@Example.com")
+		assert.Len(t, listInvitations(squatterHeader), 1)
+
+		outbox.Reset()
+		ownerOfAddress := signUp("taken@example.com")
+		assert.Empty(t, listInvitations(ownerOfAddress))
+
+		w := postJSON(r, "/api/v1/auth/verify-email", map[string]string{"token": lastMailedToken(outbox)})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Empty(t, listInvitations(squatterHeader))
+		assert.Len(t, listInvitations(ownerOfAddress), 1)
+	})
+
+	t.Run("Changing Address Lets Go", func(t *testing.T) {
+		moverHeader := signUp("mover@example.com")
+		invitation := invite("mover@example.com", "viewer")
+		assert.Len(t, listInvitations(moverHeader), 1)
+
+		outbox.Reset()
+		w := do(moverHeader, "POST", "/api/v1/me/email", `{"email": "moved@example.com", "password": "password123"}`)
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		w = postJSON(r, "/api/v1/auth/confirm-email-change", map[string]string{"token": lastMailedToken(outbox)})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		assert.Empty(t, listInvitations(moverHeader))
+		path := fmt.Sprintf("/api/v1/invitations/%d/accept", invitation.ID)
+		assert.Equal(t, http.StatusNotFound, do(moverHeader, "POST", path, "").Code)
+	})
+
+	t.Run("Decline, Revoke And Replace", func(t *testing.T) {
+		testDB.Create(&models.User{ID: 10, Email: "shy@example.com", EmailVerifiedAt: &verified})
+		shyHeader := "Bearer " + createTestToken(10)
+
+		declined := invite("shy@example.com", "viewer")
+		assert.Equal(t, http.StatusNoContent, do(shyHeader, "POST", fmt.Sprintf("/api/v1/invitations/%d/decline", declined.ID), "").Code)
+		assert.Equal(t, http.StatusConflict, do(shyHeader, "POST", fmt.Sprintf("/api/v1/invitations/%d/accept", declined.ID), "").Code)
+
+		outbox.Reset()
+		revoked := invite("shy@example.com", "viewer")
+		token := lastMailedToken(outbox)
+		assert.Equal(t, http.StatusNoContent, do(ownerHeader, "DELETE", fmt.Sprintf("%s/%d", invitationsPath, revoked.ID), "").Code)
+		assert.Equal(t, http.StatusConflict, do(shyHeader, "POST", "/api/v1/invitations/accept", fmt.Sprintf(`{"token": %q}`, token)).Code)
+
+		outbox.Reset()
+		invite("shy@example.com", "viewer")
+		first := lastMailedToken(outbox)
+		invite("shy@example.com", "commenter")
+		assert.Equal(t, http.StatusConflict, do(shyHeader, "POST", "/api/v1/invitations/accept", fmt.Sprintf(`{"token": %q}`, first)).Code)
+
+		var invitations []models.ProjectInvitation
+		json.Unmarshal(do(shyHeader, "GET", "/api/v1/invitations", "").Body.Bytes(), &invitations)
+		assert.Len(t, invitations, 1)
+		assert.Equal(t, "commenter", invitations[0].Role)
+
+		assert.Equal(t, http.StatusBadRequest, do(shyHeader, "POST", "/api/v1/invitations/accept", `{"token": "bogus"}`).Code)
+	})
+}
@@ -0,0 +1,140 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"flowday/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabels(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	testDB := setupTestDB()
+	setupTestTokens()
+
+	r := gin.Default()
+	Setup(r)
+
+	testDB.Create(&models.User{ID: 1, Email: "owner@example.com"})
+	testDB.Create(&models.User{ID: 2, Email: "editor@example.com"})
+
+	do := func(userID uint, method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+createTestToken(userID))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+	createLabel := func(userID uint, body string) models.Label {
+		w := do(userID, "POST", "/api/v1/labels", body)
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var label models.Label
+		json.Unmarshal(w.Body.Bytes(), &label)
+		return label
+	}
+	titles := func(userID uint, path string) []string {
+		var tasks []models.Task
+		json.Unmarshal(do(userID, "GET", path, "").Body.Bytes(), &tasks)
+		titles := []string{}
+		for _, task := range tasks {
+			titles = append(titles, task.Title)
+		}
+		return titles
+	}
+
+	var project, other models.Project
+	json.Unmarshal(do(1, "POST", "/api/v1/projects", `{"name": "App"}`).Body.Bytes(), &project)
+	json.Unmarshal(do(1, "POST", "/api/v1/projects", `{"name": "Site"}`).Body.Bytes(), &other)
+	testDB.Create(&models.ProjectMember{ProjectID: project.ID, UserID: 2, Role: models.RoleEditor})
+
+	var crash, polish models.Task
+	json.Unmarshal(do(1, "POST", "/api/v1/tasks", fmt.Sprintf(`{"title": "Crash on start", "project_id": %d}`, project.ID)).Body.Bytes(), &crash)
+	json.Unmarshal(do(1, "POST", "/api/v1/tasks", fmt.Sprintf(`{"title": "Polish", "project_id": %d}`, project.ID)).Body.Bytes(), &polish)
+
+	bug := createLabel(1, fmt.Sprintf(`{"name": "bug", "color": "#ff0000", "project_id": %d}`, project.ID))
+	urgent := createLabel(1, `{"name": "urgent"}`)
+	mine := createLabel(2, `{"name": "mine"}`)
+	siteLabel := createLabel(1, fmt.Sprintf(`{"name": "seo", "project_id": %d}`, other.ID))
+
+	t.Run("Create", func(t *testing.T) {
+		assert.Equal(t, project.ID, *bug.ProjectID)
+		assert.Equal(t, uint(1), *urgent.UserID)
+
+		assert.Equal(t, http.StatusConflict, do(1, "POST", "/api/v1/labels", fmt.Sprintf(`{"name": "Bug", "project_id": %d}`, project.ID)).Code)
+		assert.Equal(t, http.StatusBadRequest, do(1, "POST", "/api/v1/labels", `{"name": "x", "color": "red"}`).Code)
+
+		// The editor sees the project's labels and their own.
+		var labels []models.Label
+		json.Unmarshal(do(2, "GET", "/api/v1/labels", "").Body.Bytes(), &labels)
+		assert.Len(t, labels, 2)
+	})
+
+	t.Run("Attach", func(t *testing.T) {
+		tasksLabel := fmt.Sprintf("/api/v1/tasks/%d/labels/", crash.ID)
+		assert.Equal(t, http.StatusNoContent, do(1, "PUT", tasksLabel+fmt.Sprint(bug.ID), "").Code)
+		assert.Equal(t, http.StatusNoContent, do(1, "PUT", tasksLabel+fmt.Sprint(bug.ID), "").Code)
+		assert.Equal(t, http.StatusNoContent, do(1, "PUT", tasksLabel+fmt.Sprint(urgent.ID), "").Code)
+		assert.Equal(t, http.StatusNoContent, do(2, "PUT", tasksLabel+fmt.Sprint(mine.ID), "").Code)
+		assert.Equal(t, http.StatusNoContent, do(1, "PUT", fmt.Sprintf("/api/v1/tasks/%d/labels/%d", polish.ID, bug.ID), "").Code)
+
+		assert.Equal(t, http.StatusBadRequest, do(1, "PUT", tasksLabel+fmt.Sprint(siteLabel.ID), "").Code)
+		assert.Equal(t, http.StatusNotFound, do(2, "PUT", tasksLabel+fmt.Sprint(urgent.ID), "").Code)
+
+		// Personal labels only show to their owner.
+		var task models.Task
+		json.Unmarshal(do(1, "GET", fmt.Sprintf("/api/v1/tasks/%d", crash.ID), "").Body.Bytes(), &task)
+		assert.Len(t, task.Labels, 2)
+		json.Unmarshal(do(2, "GET", fmt.Sprintf("/api/v1/tasks/%d", crash.ID), "").Body.Bytes(), &task)
+		assert.Len(t, task.Labels, 2)
+		assert.Equal(t, "bug", task.Labels[0].Name)
+		assert.Equal(t, "mine", task.Labels[1].Name)
+	})
+
+	t.Run("Filter", func(t *testing.T) {
+		list := fmt.Sprintf("/api/v1/tasks?project_id=%d&order=created_at&dir=asc", project.ID)
+		assert.Equal(t, []string{"Crash on start", "Polish"}, titles(1, list+fmt.Sprintf("&label=%d", bug.ID)))
+		assert.Equal(t, []string{"Crash on start"}, titles(1, list+fmt.Sprintf("&label=%d,%d", bug.ID, urgent.ID)))
+		assert.Empty(t, titles(2, list+fmt.Sprintf("&label=%d", urgent.ID)))
+		assert.Equal(t, http.StatusBadRequest, do(1, "GET", list+"&label=bug", "").Code)
+
+		today := time.Now().UTC().Format("2006-01-02")
+		assert.Equal(t, []string{"Crash on start"}, titles(1, fmt.Sprintf("/api/v1/tasks/by-date?date=%s&label=%d", today, urgent.ID)))
+		assert.Equal(t, []string{"Crash on start"}, titles(2, fmt.Sprintf("/api/v1/tasks/by-range?from=%s&to=%s&label=%d", today, today, mine.ID)))
+	})
+
+	t.Run("Update And Delete", func(t *testing.T) {
+		w := do(2, "PATCH", fmt.Sprintf("/api/v1/labels/%d", bug.ID), `{"name": "defect"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, http.StatusNotFound, do(2, "PATCH", fmt.Sprintf("/api/v1/labels/%d", urgent.ID), `{"name": "x"}`).Code)
+
+		assert.Equal(t, http.StatusBadRequest, do(2, "PATCH", fmt.Sprintf("/api/v1/labels/%d", bug.ID), `{"color": "red"}`).Code)
+		w = do(2, "PATCH", fmt.Sprintf("/api/v1/labels/%d", bug.ID), `{"color": null}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var cleared models.Label
+		json.Unmarshal(w.Body.Bytes(), &cleared)
+		assert.Equal(t, "defect", cleared.Name)
+		assert.Equal(t, "", cleared.Color)
+
+		assert.Equal(t, http.StatusNoContent, do(1, "DELETE", fmt.Sprintf("/api/v1/tasks/%d/labels/%d", polish.ID, bug.ID), "").Code)
+		assert.Equal(t, http.StatusNoContent, do(1, "DELETE", fmt.Sprintf("/api/v1/labels/%d", urgent.ID), "").Code)
+
+		var task models.Task
+		json.Unmarshal(do(1, "GET", fmt.Sprintf("/api/v1/tasks/%d", crash.ID), "").Body.Bytes(), &task)
+		assert.Len(t, task.Labels, 1)
+		assert.Equal(t, "defect", task.Labels[0].Name)
+
+		json.Unmarshal(do(1, "GET", fmt.Sprintf("/api/v1/tasks/%d", polish.ID), "").Body.Bytes(), &task)
+		assert.Empty(t, task.Labels)
+	})
+}
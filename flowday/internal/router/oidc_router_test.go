@@ -0,0 +1,262 @@
+package router
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"flowday/internal/auth"
+	"flowday/internal/config"
+	"flowday/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockOIDC is a minimal OpenID provider: discovery, authorize, token, jwks
+type mockOIDC struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu            sync.Mutex
+	codes         map[string]url.Values
+	subject       string
+	email         string
+	emailVerified bool
+}
+
+func newMockOIDC(t *testing.T) *mockOIDC {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	m := &mockOIDC{key: key, codes: map[string]url.Values{}}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.URL,
+			"authorization_endpoint": m.URL + "/authorize",
+			"token_endpoint":         m.URL + "/token",
+			"jwks_uri":               m.URL + "/jwks",
+		})
+	})
+
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		m.mu.Lock()
+		code := "code-" + q.Get("state")
+		m.codes[code] = q
+		m.mu.Unlock()
+
+		redirect, _ := url.Parse(q.Get("redirect_uri"))
+		back := redirect.Query()
+		back.Set("code", code)
+		back.Set("state", q.Get("state"))
+		redirect.RawQuery = back.Encode()
+		http.Redirect(w, r, redirect.String(), http.StatusFound)
+	})
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		id, secret, _ := r.BasicAuth()
+
+		m.mu.Lock()
+		authorize, ok := m.codes[r.Form.Get("code")]
+		delete(m.codes, r.Form.Get("code"))
+		m.mu.Unlock()
+
+		sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+		if !ok || id != "flowday" || secret != "s3cret" ||
+			base64.RawURLEncoding.EncodeToString(sum[:]) != authorize.Get("code_challenge") {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":            m.URL,
+			"aud":            "flowday",
+			"sub":            m.subject,
+			"email":          m.email,
+			"email_verified": m.emailVerified,
+			"nonce":          authorize.Get("nonce"),
+			"exp":            time.Now().Add(time.Minute).Unix(),
+		})
+		token.Header["kid"] = "mock"
+		idToken, _ := token.SignedString(m.key)
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idToken, "access_token": "ignored"})
+	})
+
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "mock", "use": "sig", "alg": "RS256",
+			"n": base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+		}}})
+	})
+
+	m.Server = httptest.NewServer(mux)
+	return m
+}
+
+func TestOIDCLogin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	testDB := setupTestDB()
+	setupTestTokens()
+	setupTestMailer()
+
+	provider := newMockOIDC(t)
+	defer provider.Close()
+
+	cfg := config.DefaultAuth()
+	cfg.OIDCProviders = []config.OIDCProvider{{
+		Name:         "mock",
+		Issuer:       provider.URL,
+		ClientID:     "flowday",
+		ClientSecret: "s3cret",
+		Scopes:       []string{"openid", "email"},
+		RedirectURL:  "http://flowday.test/api/v1/auth/oidc/mock/callback",
+	}}
+	auth.Init(cfg)
+	defer auth.Init(config.DefaultAuth())
+
+	r := gin.Default()
+	Setup(r)
+
+	// signIn runs the browser side of the flow and returns the callback response
+	signIn := func(tamper func(callback *http.Request)) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/v1/auth/oidc/mock/login", nil)
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusFound, w.Code)
+		cookies := w.Result().Cookies()
+
+		noFollow := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+		resp, err := noFollow.Get(w.Header().Get("Location"))
+		require.NoError(t, err)
+		resp.Body.Close()
+		back, _ := url.Parse(resp.Header.Get("Location"))
+
+		w = httptest.NewRecorder()
+		callback, _ := http.NewRequest("GET", back.RequestURI(), nil)
+		for _, c := range cookies {
+			callback.AddCookie(c)
+		}
+		if tamper != nil {
+			tamper(callback)
+		}
+		r.ServeHTTP(w, callback)
+		return w
+	}
+
+	t.Run("Creates Account", func(t *testing.T) {
+		provider.subject, provider.email, provider.emailVerified = "sub-1", "sso@example.com", true
+
+		w := signIn(nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var body map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		assert.NotEmpty(t, body["token"])
+		assert.NotEmpty(t, body["refresh_token"])
+
+		var user models.User
+		assert.NoError(t, testDB.Where("email = ?", "sso@example.com").First(&user).Error)
+		assert.NotNil(t, user.EmailVerifiedAt)
+
+		// the same identity signs in to the same account
+		assert.Equal(t, http.StatusOK, signIn(nil).Code)
+		var count int64
+		testDB.Model(&models.ExternalIdentity{}).Where("user_id = ?", user.ID).Count(&count)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("Links Verified Email", func(t *testing.T) {
+		postJSON(r, "/api/v1/auth/register", map[string]string{"email": "both@example.com", "password": "password123"})
+		provider.subject, provider.email, provider.emailVerified = "sub-2", "both@example.com", true
+
+		// whoever registered the address never proved they own it
+		assert.Equal(t, http.StatusUnauthorized, signIn(nil).Code)
+		var count int64
+		testDB.Model(&models.ExternalIdentity{}).Where("subject = ?", "sub-2").Count(&count)
+		assert.Zero(t, count)
+
+		testDB.Model(&models.User{}).Where("email = ?", "both@example.com").Update("email_verified_at", time.Now())
+		assert.Equal(t, http.StatusOK, signIn(nil).Code)
+
+		var identity models.ExternalIdentity
+		testDB.Where("subject = ?", "sub-2").First(&identity)
+		var user models.User
+		testDB.Where("email = ?", "both@example.com").First(&user)
+		assert.Equal(t, user.ID, identity.UserID)
+	})
+
+	t.Run("Refuses Unverified Email Of Existing Account", func(t *testing.T) {
+		provider.subject, provider.email, provider.emailVerified = "sub-3", "both@example.com", false
+		assert.Equal(t, http.StatusUnauthorized, signIn(nil).Code)
+	})
+
+	t.Run("Asks For Second Factor", func(t *testing.T) {
+		provider.subject, provider.email, provider.emailVerified = "sub-1", "sso@example.com", true
+		testDB.Model(&models.User{}).Where("email = ?", "sso@example.com").Update("totp_enabled_at", time.Now())
+		defer testDB.Model(&models.User{}).Where("email = ?", "sso@example.com").Update("totp_enabled_at", nil)
+
+		w := signIn(nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var body map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		assert.Equal(t, true, body["mfa_required"])
+		assert.NotEmpty(t, body["challenge_token"])
+		assert.Nil(t, body["token"])
+	})
+
+	t.Run("Deleting SSO Account Needs Fresh Login", func(t *testing.T) {
+		provider.subject, provider.email, provider.emailVerified = "sub-4", "leaving@example.com", true
+		w := signIn(nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var body map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &body)
+
+		deleteAccount := func() int {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("DELETE", "/api/v1/me", bytes.NewBufferString(`{}`))
+			req.Header.Set("Authorization", "Bearer "+body["token"].(string))
+			req.Header.Set("Content-Type", "application/json")
+			r.ServeHTTP(w, req)
+			return w.Code
+		}
+
+		var user models.User
+		testDB.Where("email = ?", "leaving@example.com").First(&user)
+		testDB.Model(&models.Session{}).Where("user_id = ?", user.ID).Update("created_at", time.Now().Add(-time.Hour))
+		assert.Equal(t, http.StatusUnprocessableEntity, deleteAccount())
+
+		testDB.Model(&models.Session{}).Where("user_id = ?", user.ID).Update("created_at", time.Now())
+		assert.Equal(t, http.StatusNoContent, deleteAccount())
+	})
+
+	t.Run("Requires State Cookie", func(t *testing.T) {
+		provider.subject, provider.email, provider.emailVerified = "sub-1", "sso@example.com", true
+		w := signIn(func(callback *http.Request) { callback.Header.Del("Cookie") })
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Unknown Provider", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/v1/auth/oidc/nope/login", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
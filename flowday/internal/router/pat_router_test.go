@@ -0,0 +1,87 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"flowday/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPersonalAccessTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	testDB := setupTestDB()
+	setupTestTokens()
+
+	r := gin.Default()
+	Setup(r)
+
+	userID := uint(1)
+	testDB.Create(&models.User{ID: userID, Email: "ci@example.com"})
+	jwtHeader := "Bearer " + createTestProjectToken(userID)
+
+	do := func(method, path, authHeader string, body []byte) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Authorization", authHeader)
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/api/v1/me/tokens", jwtHeader, []byte(`{"name": "ci", "scopes": ["projects:read", "tasks:write"]}`))
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var created map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	raw := created["token"].(string)
+	patHeader := "Bearer " + raw
+
+	t.Run("Stored Hashed", func(t *testing.T) {
+		var count int64
+		testDB.Model(&models.PersonalAccessToken{}).Where("token_hash = ?", raw).Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+
+	t.Run("Unknown Scope", func(t *testing.T) {
+		w := do("POST", "/api/v1/me/tokens", jwtHeader, []byte(`{"name": "bad", "scopes": ["everything"]}`))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Scopes Enforced", func(t *testing.T) {
+		p := models.Project{Name: "CI Project", UserID: userID}
+		testDB.Create(&p)
+
+		assert.Equal(t, http.StatusOK, do("GET", "/api/v1/projects", patHeader, nil).Code)
+		assert.Equal(t, http.StatusForbidden, do("POST", "/api/v1/projects", patHeader, []byte(`{"name": "x"}`)).Code)
+		assert.Equal(t, http.StatusForbidden, do("DELETE", fmt.Sprintf("/api/v1/projects/%d", p.ID), patHeader, nil).Code)
+
+		// tasks:write implies tasks:read
+		assert.Equal(t, http.StatusOK, do("GET", fmt.Sprintf("/api/v1/tasks?project_id=%d", p.ID), patHeader, nil).Code)
+	})
+
+	t.Run("Cannot Manage Tokens", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, do("GET", "/api/v1/me/tokens", patHeader, nil).Code)
+	})
+
+	t.Run("List and Revoke", func(t *testing.T) {
+		w := do("GET", "/api/v1/me/tokens", jwtHeader, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var listed []map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &listed)
+		assert.Len(t, listed, 1)
+		assert.Nil(t, listed[0]["token"])
+
+		path := fmt.Sprintf("/api/v1/me/tokens/%v", created["id"])
+		assert.Equal(t, http.StatusNoContent, do("DELETE", path, jwtHeader, nil).Code)
+		assert.Equal(t, http.StatusUnauthorized, do("GET", "/api/v1/projects", patHeader, nil).Code)
+	})
+}
@@ -0,0 +1,151 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"flowday/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProjectMembers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	testDB := setupTestDB()
+	setupTestTokens()
+
+	r := gin.Default()
+	Setup(r)
+
+	owner, editor, viewer, stranger := uint(1), uint(2), uint(3), uint(4)
+	for id, email := range map[uint]string{
+		owner: "owner@example.com", editor: "editor@example.com",
+		viewer: "viewer@example.com", stranger: "stranger@example.com",
+	} {
+		testDB.Create(&models.User{ID: id, Email: email})
+	}
+
+	do := func(userID uint, method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+createTestToken(userID))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(owner, "POST", "/api/v1/workspaces", `{"name": "Team"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var team models.Workspace
+	json.Unmarshal(w.Body.Bytes(), &team)
+	teamMembers := fmt.Sprintf("/api/v1/workspaces/%d/members", team.ID)
+	assert.Equal(t, http.StatusCreated, do(owner, "POST", teamMembers, `{"email": "editor@example.com", "role": "member"}`).Code)
+	assert.Equal(t, http.StatusCreated, do(owner, "POST", teamMembers, `{"email": "viewer@example.com", "role": "member"}`).Code)
+
+	w = do(owner, "POST", "/api/v1/projects", fmt.Sprintf(`{"name": "Shared", "workspace_id": %d}`, team.ID))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var project models.Project
+	json.Unmarshal(w.Body.Bytes(), &project)
+	membersPath := fmt.Sprintf("/api/v1/projects/%d/members", project.ID)
+	taskBody := fmt.Sprintf(`{"title": "Together", "project_id": %d}`, project.ID)
+
+	t.Run("Owner Adds Members", func(t *testing.T) {
+		assert.Equal(t, http.StatusCreated, do(owner, "POST", membersPath, `{"email": "Editor@Example.com", "role": "editor"}`).Code)
+		assert.Equal(t, http.StatusCreated, do(owner, "POST", membersPath, `{"email": "viewer@example.com", "role": "viewer"}`).Code)
+
+		assert.Equal(t, http.StatusConflict, do(owner, "POST", membersPath, `{"email": "viewer@example.com", "role": "editor"}`).Code)
+		assert.Equal(t, http.StatusBadRequest, do(owner, "POST", membersPath, `{"email": "editor@example.com", "role": "boss"}`).Code)
+		assert.Equal(t, http.StatusForbidden, do(editor, "POST", membersPath, `{"email": "viewer@example.com", "role": "viewer"}`).Code)
+
+		var members []models.ProjectMember
+		json.Unmarshal(do(viewer, "GET", membersPath, "").Body.Bytes(), &members)
+		assert.Len(t, members, 3)
+
+		// other members only see who someone is, not their account settings
+		var raw []struct {
+			User map[string]interface{} `json:"user"`
+		}
+		json.Unmarshal(do(viewer, "GET", membersPath, "").Body.Bytes(), &raw)
+		if assert.NotEmpty(t, raw) {
+			assert.Equal(t, "owner@example.com", raw[0].User["email"])
+			assert.NotContains(t, raw[0].User, "totp_enabled_at")
+			assert.NotContains(t, raw[0].User, "time_zone")
+		}
+	})
+
+	t.Run("Everyone Else Is Invited", func(t *testing.T) {
+		// the answer doesn't tell whether an account uses the address
+		nobody := do(owner, "POST", membersPath, `{"email": "nobody@example.com", "role": "editor"}`)
+		outsider := do(owner, "POST", membersPath, `{"email": "stranger@example.com", "role": "editor"}`)
+		assert.Equal(t, http.StatusBadRequest, nobody.Code)
+		assert.Equal(t, nobody.Code, outsider.Code)
+		assert.Equal(t, nobody.Body.String(), outsider.Body.String())
+
+		// personal projects are never shared directly
+		var personal models.Project
+		json.Unmarshal(do(owner, "POST", "/api/v1/projects", `{"name": "Mine"}`).Body.Bytes(), &personal)
+		w := do(owner, "POST", fmt.Sprintf("/api/v1/projects/%d/members", personal.ID), `{"email": "editor@example.com", "role": "editor"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Members See The Project With Their Role", func(t *testing.T) {
+		var projects []models.Project
+		json.Unmarshal(do(viewer, "GET", "/api/v1/projects", "").Body.Bytes(), &projects)
+		assert.Len(t, projects, 1)
+		assert.Equal(t, models.RoleViewer, projects[0].Role)
+
+		json.Unmarshal(do(stranger, "GET", "/api/v1/projects", "").Body.Bytes(), &projects)
+		assert.Empty(t, projects)
+	})
+
+	t.Run("Roles Gate Tasks", func(t *testing.T) {
+		w := do(editor, "POST", "/api/v1/tasks", taskBody)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var task models.Task
+		json.Unmarshal(w.Body.Bytes(), &task)
+		taskPath := fmt.Sprintf("/api/v1/tasks/%d", task.ID)
+
+		assert.Equal(t, http.StatusForbidden, do(viewer, "POST", "/api/v1/tasks", taskBody).Code)
+		assert.Equal(t, http.StatusForbidden, do(viewer, "PATCH", taskPath, `{"status": "done"}`).Code)
+		assert.Equal(t, http.StatusNotFound, do(stranger, "PATCH", taskPath, `{"status": "done"}`).Code)
+		assert.Equal(t, http.StatusNoContent, do(owner, "PATCH", taskPath, `{"status": "done"}`).Code)
+
+		tasksPath := fmt.Sprintf("/api/v1/tasks?project_id=%d", project.ID)
+		assert.Equal(t, http.StatusOK, do(viewer, "GET", tasksPath, "").Code)
+		assert.Equal(t, http.StatusNotFound, do(stranger, "GET", tasksPath, "").Code)
+
+		var stats map[string]int
+		json.Unmarshal(do(viewer, "GET", "/api/v1/tasks/stats", "").Body.Bytes(), &stats)
+		assert.Equal(t, 1, stats["done"])
+	})
+
+	t.Run("Change Roles", func(t *testing.T) {
+		viewerPath := fmt.Sprintf("%s/%d", membersPath, viewer)
+		w := do(owner, "PATCH", viewerPath, `{"role": "editor"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, http.StatusCreated, do(viewer, "POST", "/api/v1/tasks", taskBody).Code)
+
+		// the last owner cannot step down or leave
+		ownerPath := fmt.Sprintf("%s/%d", membersPath, owner)
+		assert.Equal(t, http.StatusConflict, do(owner, "PATCH", ownerPath, `{"role": "editor"}`).Code)
+		assert.Equal(t, http.StatusConflict, do(owner, "DELETE", ownerPath, "").Code)
+	})
+
+	t.Run("Remove And Leave", func(t *testing.T) {
+		editorPath := fmt.Sprintf("%s/%d", membersPath, editor)
+		viewerPath := fmt.Sprintf("%s/%d", membersPath, viewer)
+
+		assert.Equal(t, http.StatusForbidden, do(viewer, "DELETE", editorPath, "").Code)
+		assert.Equal(t, http.StatusNoContent, do(viewer, "DELETE", viewerPath, "").Code)
+		assert.Equal(t, http.StatusNoContent, do(owner, "DELETE", editorPath, "").Code)
+
+		assert.Equal(t, http.StatusNotFound, do(editor, "GET", membersPath, "").Code)
+		assert.Equal(t, http.StatusNotFound, do(editor, "DELETE", fmt.Sprintf("/api/v1/projects/%d", project.ID), "").Code)
+	})
+}
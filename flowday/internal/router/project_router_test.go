@@ -9,11 +9,13 @@ import (
 	"testing"
 	"time"
 
+	"flowday/internal/config"
 	"flowday/internal/db"
 	"flowday/internal/models"
+	"flowday/internal/services"
+	"flowday/internal/tokens"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -21,28 +23,38 @@ import (
 
 // setupTestDB initializes an in-memory SQLite database for testing
 func setupTestDB() *gorm.DB {
-	database, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	database, err := gorm.Open(sqlite.Open(":memory:?_foreign_keys=on"), &gorm.Config{})
 	if err != nil {
 		panic("Failed to connect to test database")
 	}
 
-	// Migrate schemas
-	database.AutoMigrate(&models.User{}, &models.Project{}, &models.Task{})
-
-	// Override the global DB variable
+	// Override the global DB variable and migrate schemas
 	db.DB = database
+	db.Migrate()
 
 	return database
 }
 
+// setupTestTokens configures the token service with a throwaway HS256 key
+func setupTestTokens() {
+	err := tokens.Init(config.JWT{
+		Active: config.JWTKey{
+			ID:        "test",
+			Algorithm: tokens.HS256,
+			Secret:    "test-secret-key-at-least-32-bytes-long",
+		},
+		Issuer:    "flowday",
+		Audience:  "flowday-api",
+		AccessTTL: time.Hour,
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
 // createTestProjectToken duplicates logic from router_test but creates a fresh one here to avoid circular dependencies if any
 func createTestProjectToken(userID uint) string {
-	var jwtSecret = []byte("super-secret-key")
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": float64(userID),
-		"exp":     time.Now().Add(time.Hour * 1).Unix(),
-	})
-	tokenString, _ := token.SignedString(jwtSecret)
+	tokenString, _ := tokens.Issue(tokens.Claims{UserID: userID})
 	return tokenString
 }
 
@@ -51,16 +63,17 @@ func TestProjectRoutes(t *testing.T) {
 
 	// Initialize DB
 	testDB := setupTestDB()
+	setupTestTokens()
 
 	// Setup Router
 	r := gin.Default()
 	Setup(r)
 
-	// Create a test user directly in DB (to respect foreign keys if enforced, and for realism)
-	// Though our auth middleware just trusts the token claim, the project service uses the user_id.
-	// SQLite :memory: is blank, so we just run.
-
+	// Create the test users directly in DB: foreign keys are enforced.
+	// Our auth middleware just trusts the token claim, the project service uses the user_id.
 	userID := uint(1)
+	testDB.Create(&models.User{ID: userID, Email: "user1@example.com"})
+	testDB.Create(&models.User{ID: 2, Email: "user2@example.com"})
 	token := createTestProjectToken(userID)
 	authHeader := "Bearer " + token
 
@@ -148,3 +161,208 @@ func TestProjectRoutes(t *testing.T) {
 		}
 	})
 }
+
+func TestProjectDetailsAndArchive(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	testDB := setupTestDB()
+	setupTestTokens()
+
+	r := gin.Default()
+	Setup(r)
+
+	owner, viewer := uint(1), uint(2)
+	testDB.Create(&models.User{ID: owner, Email: "owner@example.com"})
+	testDB.Create(&models.User{ID: viewer, Email: "viewer@example.com"})
+
+	do := func(userID uint, method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+createTestProjectToken(userID))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(owner, "POST", "/api/v1/projects", `{"name": "Launch", "description": "Q3 launch", "color": "#ff8800", "icon": "rocket"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var project models.Project
+	json.Unmarshal(w.Body.Bytes(), &project)
+	assert.Equal(t, "Q3 launch", project.Description)
+	projectPath := fmt.Sprintf("/api/v1/projects/%d", project.ID)
+
+	testDB.Create(&models.ProjectMember{ProjectID: project.ID, UserID: viewer, Role: models.RoleViewer})
+
+	today := time.Now().UTC()
+	testDB.Create(&models.Task{Title: "Announce", ProjectID: project.ID, Status: "todo", DueDate: &today})
+
+	t.Run("Validate Create", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, do(owner, "POST", "/api/v1/projects", `{"name": "Bad", "color": "orange"}`).Code)
+	})
+
+	t.Run("Get Project", func(t *testing.T) {
+		w := do(viewer, "GET", projectPath, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var got models.Project
+		json.Unmarshal(w.Body.Bytes(), &got)
+		assert.Equal(t, "#ff8800", got.Color)
+		assert.Equal(t, "rocket", got.Icon)
+		assert.Equal(t, models.RoleViewer, got.Role)
+
+		assert.Equal(t, http.StatusNotFound, do(3, "GET", projectPath, "").Code)
+	})
+
+	t.Run("Update Project", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, do(viewer, "PATCH", projectPath, `{"name": "Mine"}`).Code)
+
+		w := do(owner, "PATCH", projectPath, `{"name": "Relaunch", "color": "#00aa00"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var got models.Project
+		json.Unmarshal(w.Body.Bytes(), &got)
+		assert.Equal(t, "Relaunch", got.Name)
+		assert.Equal(t, "#00aa00", got.Color)
+		assert.Equal(t, "Q3 launch", got.Description)
+
+		assert.Equal(t, http.StatusBadRequest, do(owner, "PATCH", projectPath, `{"color": "green"}`).Code)
+
+		w = do(owner, "PATCH", projectPath, `{"color": null}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		json.Unmarshal(w.Body.Bytes(), &got)
+		assert.Equal(t, "", got.Color)
+		assert.Equal(t, "Relaunch", got.Name)
+	})
+
+	t.Run("Archive Hides Project", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, do(viewer, "POST", projectPath+"/archive", "").Code)
+
+		w := do(owner, "POST", projectPath+"/archive", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		var got models.Project
+		json.Unmarshal(w.Body.Bytes(), &got)
+		assert.NotNil(t, got.ArchivedAt)
+
+		var projects []models.Project
+		json.Unmarshal(do(viewer, "GET", "/api/v1/projects", "").Body.Bytes(), &projects)
+		assert.Empty(t, projects)
+		json.Unmarshal(do(viewer, "GET", "/api/v1/projects?include_archived=true", "").Body.Bytes(), &projects)
+		assert.Len(t, projects, 1)
+
+		var stats map[string]int
+		json.Unmarshal(do(viewer, "GET", "/api/v1/tasks/stats", "").Body.Bytes(), &stats)
+		assert.Zero(t, stats["total"])
+		json.Unmarshal(do(viewer, "GET", "/api/v1/tasks/stats?include_archived=true", "").Body.Bytes(), &stats)
+		assert.Equal(t, 1, stats["total"])
+
+		date := today.Format("2006-01-02")
+		var tasks []models.Task
+		json.Unmarshal(do(viewer, "GET", "/api/v1/tasks/by-date?tz=UTC&date="+date, "").Body.Bytes(), &tasks)
+		assert.Empty(t, tasks)
+		json.Unmarshal(do(viewer, "GET", "/api/v1/tasks/by-date?tz=UTC&include_archived=true&date="+date, "").Body.Bytes(), &tasks)
+		assert.Len(t, tasks, 1)
+
+		// still reachable directly
+		assert.Equal(t, http.StatusOK, do(viewer, "GET", projectPath, "").Code)
+	})
+
+	t.Run("Unarchive", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, do(owner, "POST", projectPath+"/unarchive", "").Code)
+
+		var projects []models.Project
+		json.Unmarshal(do(viewer, "GET", "/api/v1/projects", "").Body.Bytes(), &projects)
+		assert.Len(t, projects, 1)
+	})
+}
+
+func TestProjectTrash(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	testDB := setupTestDB()
+	setupTestTokens()
+
+	r := gin.Default()
+	Setup(r)
+
+	owner, editor := uint(1), uint(2)
+	testDB.Create(&models.User{ID: owner, Email: "owner@example.com"})
+	testDB.Create(&models.User{ID: editor, Email: "editor@example.com"})
+
+	do := func(userID uint, method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+createTestProjectToken(userID))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+	createProject := func(name string) models.Project {
+		var project models.Project
+		json.Unmarshal(do(owner, "POST", "/api/v1/projects", fmt.Sprintf(`{"name": %q}`, name)).Body.Bytes(), &project)
+		testDB.Create(&models.ProjectMember{ProjectID: project.ID, UserID: editor, Role: models.RoleEditor})
+		testDB.Create(&models.Task{Title: name + " task", ProjectID: project.ID, Status: "todo"})
+		return project
+	}
+	countTasks := func(userID uint) int {
+		var stats map[string]int
+		json.Unmarshal(do(userID, "GET", "/api/v1/tasks/stats", "").Body.Bytes(), &stats)
+		return stats["total"]
+	}
+
+	project := createProject("Oops")
+	projectPath := fmt.Sprintf("/api/v1/projects/%d", project.ID)
+
+	t.Run("Delete Moves To Trash", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, do(editor, "DELETE", projectPath, "").Code)
+		assert.Equal(t, http.StatusNoContent, do(owner, "DELETE", projectPath, "").Code)
+
+		assert.Equal(t, http.StatusNotFound, do(owner, "GET", projectPath, "").Code)
+		assert.Equal(t, http.StatusNotFound, do(editor, "GET", fmt.Sprintf("/api/v1/tasks?project_id=%d", project.ID), "").Code)
+		assert.Zero(t, countTasks(editor))
+
+		var trash []models.Project
+		json.Unmarshal(do(owner, "GET", "/api/v1/projects/trash", "").Body.Bytes(), &trash)
+		assert.Len(t, trash, 1)
+		json.Unmarshal(do(editor, "GET", "/api/v1/projects/trash", "").Body.Bytes(), &trash)
+		assert.Empty(t, trash)
+
+		var tasks int64
+		testDB.Model(&models.Task{}).Where("project_id = ?", project.ID).Count(&tasks)
+		assert.Equal(t, int64(1), tasks)
+	})
+
+	t.Run("Restore Brings Tasks Back", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, do(editor, "POST", projectPath+"/restore", "").Code)
+		assert.Equal(t, http.StatusOK, do(owner, "POST", projectPath+"/restore", "").Code)
+		assert.Equal(t, http.StatusNotFound, do(owner, "POST", projectPath+"/restore", "").Code)
+
+		assert.Equal(t, http.StatusOK, do(editor, "GET", projectPath, "").Code)
+		assert.Equal(t, 1, countTasks(editor))
+	})
+
+	t.Run("Purge After Retention", func(t *testing.T) {
+		doomed := createProject("Doomed")
+		recent := createProject("Recent")
+		do(owner, "DELETE", fmt.Sprintf("/api/v1/projects/%d", doomed.ID), "")
+		do(owner, "DELETE", fmt.Sprintf("/api/v1/projects/%d", recent.ID), "")
+		testDB.Unscoped().Model(&models.Project{}).Where("id = ?", doomed.ID).
+			Update("deleted_at", time.Now().Add(-31*24*time.Hour))
+
+		purged, err := services.PurgeTrash(time.Now().Add(-30 * 24 * time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, purged)
+
+		var count int64
+		testDB.Unscoped().Model(&models.Project{}).Where("id = ?", doomed.ID).Count(&count)
+		assert.Zero(t, count)
+		testDB.Model(&models.Task{}).Where("project_id = ?", doomed.ID).Count(&count)
+		assert.Zero(t, count)
+		testDB.Model(&models.ProjectMember{}).Where("project_id = ?", doomed.ID).Count(&count)
+		assert.Zero(t, count)
+
+		var trash []models.Project
+		json.Unmarshal(do(owner, "GET", "/api/v1/projects/trash", "").Body.Bytes(), &trash)
+		assert.Len(t, trash, 1)
+		assert.Equal(t, "Recent", trash[0].Name)
+	})
+}
@@ -2,13 +2,28 @@ package router
 
 import (
 	"flowday/internal/auth"
+	"flowday/internal/config"
 	"flowday/internal/handlers"
 	"flowday/internal/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
+// New builds the engine with every route. Forwarding headers are only
+// believed when they come from one of the configured proxies.
+func New(cfg config.HTTP) (*gin.Engine, error) {
+	r := gin.Default()
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, err
+	}
+
+	Setup(r)
+	return r, nil
+}
+
 func Setup(r *gin.Engine) {
+	r.GET("/.well-known/jwks.json", handlers.GetJWKS)
+
 	v1 := r.Group("/api/v1")
 
 	// ---------- AUTH ----------
@@ -16,46 +31,164 @@ func Setup(r *gin.Engine) {
 	{
 		authGroup.POST("/register", auth.RegisterHandler)
 		authGroup.POST("/login", auth.LoginHandler)
+		authGroup.POST("/login/2fa", auth.MFALoginHandler)
+		authGroup.POST("/refresh", auth.RefreshHandler)
+		authGroup.POST("/logout", auth.LogoutHandler)
+		authGroup.POST("/forgot-password", auth.ForgotPasswordHandler)
+		authGroup.POST("/reset-password", auth.ResetPasswordHandler)
+		authGroup.POST("/verify-email", auth.VerifyEmailHandler)
+		authGroup.POST("/resend-verification", auth.ResendVerificationHandler)
+		authGroup.POST("/confirm-email-change", auth.ConfirmEmailChangeHandler)
+		authGroup.GET("/oidc/:provider/login", auth.OIDCLoginHandler)
+		authGroup.GET("/oidc/:provider/callback", auth.OIDCCallbackHandler)
 	}
 
 	// ---------- PROTECTED ----------
 	protected := v1.Group("/")
 	protected.Use(middleware.AuthMiddleware())
 	{
-		protected.GET("/me", func(c *gin.Context) {
-			c.JSON(200, gin.H{
-				"user_id": c.GetUint("user_id"),
-			})
-		})
+		protected.GET("/me", handlers.GetProfile)
+
+		accountGroup := protected.Group("/me")
+		accountGroup.Use(middleware.RequireSession())
+		{
+			accountGroup.PATCH("", handlers.UpdateProfile)
+			accountGroup.DELETE("", handlers.DeleteAccount)
+			accountGroup.POST("/password", auth.ChangePasswordHandler)
+			accountGroup.POST("/email", auth.ChangeEmailHandler)
+		}
+
+		// personal access tokens can't manage themselves
+		tokensGroup := protected.Group("/me/tokens")
+		tokensGroup.Use(middleware.RequireSession())
+		{
+			tokensGroup.GET("", auth.ListTokensHandler)
+			tokensGroup.POST("", auth.CreateTokenHandler)
+			tokensGroup.DELETE("/:id", auth.RevokeTokenHandler)
+		}
+
+		twoFactorGroup := protected.Group("/me/2fa")
+		twoFactorGroup.Use(middleware.RequireSession())
+		{
+			twoFactorGroup.POST("/enroll", auth.EnrollTOTPHandler)
+			twoFactorGroup.POST("/confirm", auth.ConfirmTOTPHandler)
+			twoFactorGroup.POST("/disable", auth.DisableTOTPHandler)
+			twoFactorGroup.POST("/recovery-codes", auth.RegenerateRecoveryCodesHandler)
+		}
 	}
 
+	projectsRead := middleware.RequireScope(auth.ScopeProjectsRead)
+	projectsWrite := middleware.RequireScope(auth.ScopeProjectsWrite)
+	verifiedEmail := middleware.RequireVerifiedEmail()
+	projectsAdmin := middleware.RequireScope(auth.ScopeProjectsAdmin)
+	tasksRead := middleware.RequireScope(auth.ScopeTasksRead)
+	tasksWrite := middleware.RequireScope(auth.ScopeTasksWrite)
+
+	// ---------- WORKSPACES ----------
+	workspacesGroup := v1.Group("/workspaces")
+	workspacesGroup.Use(middleware.AuthMiddleware())
+	{
+		workspacesGroup.GET("", projectsRead, handlers.GetWorkspaces)
+		workspacesGroup.POST("", projectsAdmin, verifiedEmail, handlers.CreateWorkspace)
+		workspacesGroup.GET("/:id", projectsRead, handlers.GetWorkspace)
+		workspacesGroup.PATCH("/:id", projectsAdmin, handlers.UpdateWorkspace)
 
+		workspacesGroup.GET("/:id/members", projectsRead, handlers.GetWorkspaceMembers)
+		workspacesGroup.POST("/:id/members", projectsAdmin, handlers.AddWorkspaceMember)
+		workspacesGroup.PATCH("/:id/members/:user_id", projectsAdmin, handlers.UpdateWorkspaceMember)
+		workspacesGroup.DELETE("/:id/members/:user_id", projectsAdmin, handlers.RemoveWorkspaceMember)
+	}
 
 	// ---------- PROJECTS ----------
 	projectsGroup := v1.Group("/projects")
 	projectsGroup.Use(middleware.AuthMiddleware())
 	{
-		projectsGroup.GET("", handlers.GetProjects)
-		projectsGroup.POST("", handlers.CreateProject)
-		projectsGroup.DELETE("/:id", handlers.DeleteProject)
+		projectsGroup.GET("", projectsRead, handlers.GetProjects) // ?workspace_id=&include_archived=
+		projectsGroup.POST("", projectsWrite, verifiedEmail, handlers.CreateProject)
+		// The collection answers with a trailing slash too, rather than redirecting.
+		projectsGroup.GET("/", projectsRead, handlers.GetProjects)
+		projectsGroup.POST("/", projectsWrite, verifiedEmail, handlers.CreateProject)
+		projectsGroup.GET("/trash", projectsRead, handlers.GetTrash)
+		projectsGroup.GET("/:id", projectsRead, handlers.GetProject)
+		projectsGroup.PATCH("/:id", projectsWrite, handlers.UpdateProject)
+		projectsGroup.DELETE("/:id", projectsAdmin, handlers.DeleteProject)
+		projectsGroup.POST("/:id/archive", projectsAdmin, handlers.ArchiveProject)
+		projectsGroup.POST("/:id/unarchive", projectsAdmin, handlers.UnarchiveProject)
+		projectsGroup.POST("/:id/restore", projectsAdmin, handlers.RestoreProject)
+
+		projectsGroup.GET("/:id/workflow", projectsRead, handlers.GetWorkflow)
+		projectsGroup.PUT("/:id/workflow", projectsAdmin, handlers.ReplaceWorkflow)
+
+		projectsGroup.GET("/:id/members", projectsRead, handlers.GetProjectMembers)
+		projectsGroup.POST("/:id/members", projectsAdmin, handlers.AddProjectMember)
+		projectsGroup.PATCH("/:id/members/:user_id", projectsAdmin, handlers.UpdateProjectMember)
+		projectsGroup.DELETE("/:id/members/:user_id", projectsAdmin, handlers.RemoveProjectMember)
+
+		projectsGroup.GET("/:id/invitations", projectsAdmin, handlers.GetProjectInvitations)
+		projectsGroup.POST("/:id/invitations", projectsAdmin, handlers.InviteToProject)
+		projectsGroup.DELETE("/:id/invitations/:invitation_id", projectsAdmin, handlers.RevokeInvitation)
+	}
+
+	// ---------- INVITATIONS ----------
+	invitationsGroup := v1.Group("/invitations")
+	invitationsGroup.Use(middleware.AuthMiddleware(), middleware.RequireSession())
+	{
+		invitationsGroup.GET("", handlers.GetMyInvitations)
+		invitationsGroup.POST("/accept", handlers.AcceptInvitationToken)
+		invitationsGroup.POST("/:id/accept", handlers.AcceptInvitation)
+		invitationsGroup.POST("/:id/decline", handlers.DeclineInvitation)
+	}
+
+	// ---------- LABELS ----------
+	labelsGroup := v1.Group("/labels")
+	labelsGroup.Use(middleware.AuthMiddleware())
+	{
+		labelsGroup.GET("", tasksRead, handlers.GetLabels) // ?project_id=
+		labelsGroup.POST("", tasksWrite, handlers.CreateLabel)
+		labelsGroup.PATCH("/:id", tasksWrite, handlers.UpdateLabel)
+		labelsGroup.DELETE("/:id", tasksWrite, handlers.DeleteLabel)
 	}
 
 	// ---------- TASKS ----------
 	tasksGroup := v1.Group("/tasks")
 	tasksGroup.Use(middleware.AuthMiddleware())
 	{
-		tasksGroup.GET("", handlers.GetTasks)                 // ?project_id=
-		tasksGroup.POST("", handlers.CreateTask)
-		tasksGroup.PATCH("/:id", handlers.UpdateTask)
-		tasksGroup.DELETE("/:id", handlers.DeleteTask)
+		tasksGroup.GET("", tasksRead, handlers.GetTasks) // ?project_id=&view=flat|top|tree&label=&assignee=me
+		tasksGroup.POST("", tasksWrite, handlers.CreateTask)
+		tasksGroup.GET("/:id", tasksRead, handlers.GetTask)
+		tasksGroup.PATCH("/:id", tasksWrite, handlers.UpdateTask)
+		tasksGroup.DELETE("/:id", tasksWrite, handlers.DeleteTask)
+		tasksGroup.GET("/:id/subtasks", tasksRead, handlers.GetSubtasks)
+		tasksGroup.POST("/:id/move", tasksWrite, handlers.MoveTask)
+
+		tasksGroup.GET("/:id/checklist", tasksRead, handlers.GetChecklist)
+		tasksGroup.POST("/:id/checklist", tasksWrite, handlers.AddChecklistItem)
+		tasksGroup.PATCH("/:id/checklist/:item_id", tasksWrite, handlers.UpdateChecklistItem)
+		tasksGroup.DELETE("/:id/checklist/:item_id", tasksWrite, handlers.DeleteChecklistItem)
+
+		tasksGroup.PUT("/:id/labels/:label_id", tasksWrite, handlers.AddTaskLabel)
+		tasksGroup.DELETE("/:id/labels/:label_id", tasksWrite, handlers.RemoveTaskLabel)
+
+		tasksGroup.PUT("/:id/assignees/:user_id", tasksWrite, handlers.AssignTask)
+		tasksGroup.DELETE("/:id/assignees/:user_id", tasksWrite, handlers.UnassignTask)
+
+		tasksGroup.GET("/:id/comments", tasksRead, handlers.GetComments) // ?limit=&offset=
+		tasksGroup.POST("/:id/comments", tasksWrite, handlers.CreateComment)
+		tasksGroup.PATCH("/:id/comments/:comment_id", tasksWrite, handlers.UpdateComment)
+		tasksGroup.DELETE("/:id/comments/:comment_id", tasksWrite, handlers.DeleteComment)
+		tasksGroup.GET("/:id/comments/:comment_id/revisions", tasksRead, handlers.GetCommentRevisions)
+
+		tasksGroup.GET("/trash", tasksRead, handlers.GetTrashedTasks)
+		tasksGroup.POST("/:id/restore", tasksWrite, handlers.RestoreTask)
+		tasksGroup.DELETE("/:id/purge", tasksWrite, handlers.PurgeTask)
 
 		// ✅ calendar API
-		tasksGroup.GET("/by-date", handlers.GetTasksByDate)   // ?date=YYYY-MM-DD
+		tasksGroup.GET("/by-date", tasksRead, handlers.GetTasksByDate) // ?date=YYYY-MM-DD&label=&assignee=me
 
 		// ✅ range API
-		tasksGroup.GET("/by-range", handlers.GetTasksByRange) // ?from=YYYY-MM-DD&to=YYYY-MM-DD
+		tasksGroup.GET("/by-range", tasksRead, handlers.GetTasksByRange) // ?from=YYYY-MM-DD&to=YYYY-MM-DD&label=&assignee=me
 
 		// ✅ stats API
-		tasksGroup.GET("/stats", handlers.GetTaskStats)
+		tasksGroup.GET("/stats", tasksRead, handlers.GetTaskStats)
 	}
 }
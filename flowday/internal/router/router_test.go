@@ -8,6 +8,10 @@ import (
 	"testing"
 	"time"
 
+	"flowday/internal/db"
+	"flowday/internal/models"
+	"flowday/internal/tokens"
+
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
@@ -15,15 +19,7 @@ import (
 
 // Helper to create a valid token for testing
 func createTestToken(userID uint) string {
-	// Must match the key in internal/middleware/auth.go
-	var jwtSecret = []byte("super-secret-key")
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": float64(userID), // JSON numbers are floats
-		"exp":     time.Now().Add(time.Hour * 1).Unix(),
-	})
-
-	tokenString, _ := token.SignedString(jwtSecret)
+	tokenString, _ := tokens.Issue(tokens.Claims{UserID: userID})
 	return tokenString
 }
 
@@ -32,6 +28,7 @@ func TestSetup(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	setupTestDB()
+	setupTestTokens()
 
 	r := gin.Default()
 	Setup(r)
@@ -66,6 +63,57 @@ func TestSetup(t *testing.T) {
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NoError(t, err)
 		assert.NotEmpty(t, response["token"])
+		assert.NotEmpty(t, response["refresh_token"])
+	})
+
+	t.Run("Public Route - Refresh and Logout", func(t *testing.T) {
+		login := func() map[string]interface{} {
+			w := httptest.NewRecorder()
+			body := []byte(`{"email": "test@example.com", "password": "password123"}`)
+			req, _ := http.NewRequest("POST", "/api/v1/auth/login", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			r.ServeHTTP(w, req)
+
+			var response map[string]interface{}
+			json.Unmarshal(w.Body.Bytes(), &response)
+			return response
+		}
+		post := func(path, refreshToken string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			body, _ := json.Marshal(map[string]string{"refresh_token": refreshToken})
+			req, _ := http.NewRequest("POST", path, bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			r.ServeHTTP(w, req)
+			return w
+		}
+
+		first := login()["refresh_token"].(string)
+
+		w := post("/api/v1/auth/refresh", first)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var rotated map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &rotated)
+		assert.NotEmpty(t, rotated["token"])
+		second := rotated["refresh_token"].(string)
+		assert.NotEqual(t, first, second)
+
+		// Replaying a rotated token kills the session.
+		assert.Equal(t, http.StatusUnauthorized, post("/api/v1/auth/refresh", first).Code)
+		assert.Equal(t, http.StatusUnauthorized, post("/api/v1/auth/refresh", second).Code)
+
+		third := login()["refresh_token"].(string)
+		assert.Equal(t, http.StatusNoContent, post("/api/v1/auth/logout", third).Code)
+		assert.Equal(t, http.StatusUnauthorized, post("/api/v1/auth/refresh", third).Code)
+	})
+
+	t.Run("Public Route - JWKS", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/.well-known/jwks.json", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		// The test key is an HMAC secret, which must never be published.
+		assert.JSONEq(t, `{"keys": []}`, w.Body.String())
 	})
 
 	t.Run("Protected Route - Unauthorized", func(t *testing.T) {
@@ -77,6 +125,8 @@ func TestSetup(t *testing.T) {
 	})
 
 	t.Run("Protected Route - Authorized", func(t *testing.T) {
+		db.DB.Create(&models.User{ID: 123, Email: "me@example.com"})
+
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("GET", "/api/v1/me", nil)
 
@@ -92,6 +142,61 @@ func TestSetup(t *testing.T) {
 		assert.NoError(t, err)
 
 		// Unmarshal reads numbers as float64
-		assert.Equal(t, float64(123), response["user_id"])
+		assert.Equal(t, float64(123), response["id"])
+		assert.Equal(t, "me@example.com", response["email"])
+		assert.Nil(t, response["password"])
+	})
+
+	t.Run("Protected Route - Rejects Foreign Tokens", func(t *testing.T) {
+		forged := map[string]string{}
+
+		// Unsigned token
+		none := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{
+			"user_id": 123, "iss": "flowday", "aud": "flowday-api",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+		forged["none"], _ = none.SignedString(jwt.UnsafeAllowNoneSignatureType)
+
+		// Right key, wrong audience
+		wrongAud := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"user_id": 123, "iss": "flowday", "aud": "someone-else",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+		wrongAud.Header["kid"] = "test"
+		forged["audience"], _ = wrongAud.SignedString([]byte("test-secret-key-at-least-32-bytes-long"))
+
+		// Right key, no expiry
+		noExp := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"user_id": 123, "iss": "flowday", "aud": "flowday-api",
+		})
+		noExp.Header["kid"] = "test"
+		forged["exp"], _ = noExp.SignedString([]byte("test-secret-key-at-least-32-bytes-long"))
+
+		for name, token := range forged {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/api/v1/me", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusUnauthorized, w.Code, name)
+		}
+	})
+
+	t.Run("Protected Route - Login Token", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		body := []byte(`{"email": "test@example.com", "password": "password123"}`)
+		req, _ := http.NewRequest("POST", "/api/v1/auth/login", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+
+		var login map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &login)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/api/v1/me", nil)
+		req.Header.Set("Authorization", "Bearer "+login["token"].(string))
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
 	})
 }
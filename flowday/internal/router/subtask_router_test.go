@@ -0,0 +1,177 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"flowday/internal/config"
+	"flowday/internal/models"
+	"flowday/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubtasks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	testDB := setupTestDB()
+	setupTestTokens()
+
+	projects := config.DefaultProjects()
+	projects.MaxTaskDepth = 3
+	services.Init(projects)
+	defer services.Init(config.DefaultProjects())
+
+	r := gin.Default()
+	Setup(r)
+
+	testDB.Create(&models.User{ID: 1, Email: "planner@example.com"})
+	authHeader := "Bearer " + createTestToken(1)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", authHeader)
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+	var project models.Project
+	json.Unmarshal(do("POST", "/api/v1/projects", `{"name": "Launch"}`).Body.Bytes(), &project)
+
+	create := func(title string, parentID uint) models.Task {
+		body := fmt.Sprintf(`{"title": %q, "project_id": %d}`, title, project.ID)
+		if parentID != 0 {
+			body = fmt.Sprintf(`{"title": %q, "project_id": %d, "parent_id": %d}`, title, project.ID, parentID)
+		}
+		w := do("POST", "/api/v1/tasks", body)
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var task models.Task
+		json.Unmarshal(w.Body.Bytes(), &task)
+		return task
+	}
+	list := func(view string) []models.Task {
+		var tasks []models.Task
+		json.Unmarshal(do("GET", fmt.Sprintf("/api/v1/tasks?project_id=%d&view=%s", project.ID, view), "").Body.Bytes(), &tasks)
+		return tasks
+	}
+
+	feature := create("Feature", 0)
+	design := create("Design", feature.ID)
+	build := create("Build", feature.ID)
+	backend := create("Backend", build.ID)
+	other := create("Other", 0)
+
+	t.Run("Positions", func(t *testing.T) {
+		assert.Equal(t, 0, design.Position)
+		assert.Equal(t, 1, build.Position)
+		assert.Equal(t, 1, other.Position)
+		assert.Equal(t, build.ID, *backend.ParentID)
+	})
+
+	t.Run("Depth Limit", func(t *testing.T) {
+		w := do("POST", "/api/v1/tasks", fmt.Sprintf(`{"title": "Too deep", "project_id": %d, "parent_id": %d}`, project.ID, backend.ID))
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+		// Build and Backend are two levels, which don't fit under Design.
+		w = do("POST", fmt.Sprintf("/api/v1/tasks/%d/move", build.ID), fmt.Sprintf(`{"parent_id": %d}`, design.ID))
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("No Cycles", func(t *testing.T) {
+		w := do("POST", fmt.Sprintf("/api/v1/tasks/%d/move", feature.ID), fmt.Sprintf(`{"parent_id": %d}`, backend.ID))
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("Views", func(t *testing.T) {
+		assert.Len(t, list("flat"), 5)
+
+		top := list("top")
+		assert.Len(t, top, 2)
+
+		tree := list("tree")
+		assert.Len(t, tree, 2)
+		for _, task := range tree {
+			if task.ID != feature.ID {
+				continue
+			}
+			assert.Len(t, task.Subtasks, 2)
+			assert.Equal(t, "Design", task.Subtasks[0].Title)
+			assert.Len(t, task.Subtasks[1].Subtasks, 1)
+			assert.Equal(t, int64(2), task.Progress.Total)
+		}
+
+		assert.Equal(t, http.StatusBadRequest, do("GET", fmt.Sprintf("/api/v1/tasks?project_id=%d&view=graph", project.ID), "").Code)
+	})
+
+	t.Run("Reorder", func(t *testing.T) {
+		w := do("POST", fmt.Sprintf("/api/v1/tasks/%d/move", build.ID), `{"position": 0}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var subtasks []models.Task
+		json.Unmarshal(do("GET", fmt.Sprintf("/api/v1/tasks/%d/subtasks", feature.ID), "").Body.Bytes(), &subtasks)
+		assert.Equal(t, []string{"Build", "Design"}, []string{subtasks[0].Title, subtasks[1].Title})
+		assert.Equal(t, int64(1), subtasks[0].Progress.Total)
+	})
+
+	t.Run("Reparent", func(t *testing.T) {
+		w := do("POST", fmt.Sprintf("/api/v1/tasks/%d/move", design.ID), fmt.Sprintf(`{"parent_id": %d}`, other.ID))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = do("POST", fmt.Sprintf("/api/v1/tasks/%d/move", design.ID), `{"parent_id": null}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, list("top"), 3)
+
+		w = do("POST", fmt.Sprintf("/api/v1/tasks/%d/move", design.ID), fmt.Sprintf(`{"parent_id": %d, "position": 5}`, feature.ID))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var moved models.Task
+		json.Unmarshal(w.Body.Bytes(), &moved)
+		assert.Equal(t, 1, moved.Position)
+	})
+
+	t.Run("Completing A Parent", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, do("PATCH", fmt.Sprintf("/api/v1/tasks/%d", build.ID), `{"status": "done"}`).Code)
+
+		var task models.Task
+		json.Unmarshal(do("GET", fmt.Sprintf("/api/v1/tasks/%d", backend.ID), "").Body.Bytes(), &task)
+		assert.Equal(t, "done", task.Status)
+		assert.NotNil(t, task.CompletedAt)
+
+		json.Unmarshal(do("GET", fmt.Sprintf("/api/v1/tasks/%d", feature.ID), "").Body.Bytes(), &task)
+		assert.Equal(t, int64(1), task.Progress.Done)
+		assert.Equal(t, int64(2), task.Progress.Total)
+		assert.Len(t, task.Subtasks, 2)
+	})
+
+	t.Run("Deleting A Parent", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, do("DELETE", fmt.Sprintf("/api/v1/tasks/%d", feature.ID), "").Code)
+		assert.Len(t, list("flat"), 1)
+
+		var trash []models.Task
+		json.Unmarshal(do("GET", "/api/v1/tasks/trash", "").Body.Bytes(), &trash)
+		assert.Len(t, trash, 4)
+
+		// A subtask restored on its own comes back at the top.
+		w := do("POST", fmt.Sprintf("/api/v1/tasks/%d/restore", design.ID), "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		var restored models.Task
+		json.Unmarshal(w.Body.Bytes(), &restored)
+		assert.Nil(t, restored.ParentID)
+
+		assert.Equal(t, http.StatusOK, do("POST", fmt.Sprintf("/api/v1/tasks/%d/restore", feature.ID), "").Code)
+		assert.Len(t, list("flat"), 5)
+
+		assert.Equal(t, http.StatusNoContent, do("DELETE", fmt.Sprintf("/api/v1/tasks/%d", build.ID), "").Code)
+		assert.Equal(t, http.StatusNoContent, do("DELETE", fmt.Sprintf("/api/v1/tasks/%d/purge", build.ID), "").Code)
+		var count int64
+		testDB.Unscoped().Model(&models.Task{}).Where("id = ?", backend.ID).Count(&count)
+		assert.Zero(t, count)
+	})
+}
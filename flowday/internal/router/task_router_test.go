@@ -0,0 +1,307 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"flowday/internal/models"
+	"flowday/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskTrash(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	testDB := setupTestDB()
+	setupTestTokens()
+
+	r := gin.Default()
+	Setup(r)
+
+	userID := uint(1)
+	testDB.Create(&models.User{ID: userID, Email: "trash@example.com"})
+	authHeader := "Bearer " + createTestToken(userID)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", authHeader)
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	var project models.Project
+	json.Unmarshal(do("POST", "/api/v1/projects", `{"name": "Chores"}`).Body.Bytes(), &project)
+	createTask := func(title string) models.Task {
+		var task models.Task
+		w := do("POST", "/api/v1/tasks?tz=UTC", fmt.Sprintf(`{"title": %q, "project_id": %d}`, title, project.ID))
+		json.Unmarshal(w.Body.Bytes(), &task)
+		return task
+	}
+	titles := func(path string) []string {
+		var tasks []models.Task
+		json.Unmarshal(do("GET", path, "").Body.Bytes(), &tasks)
+		names := []string{}
+		for _, task := range tasks {
+			names = append(names, task.Title)
+		}
+		return names
+	}
+	total := func() int {
+		var stats map[string]int
+		json.Unmarshal(do("GET", "/api/v1/tasks/stats?tz=UTC", "").Body.Bytes(), &stats)
+		return stats["total"]
+	}
+
+	keep := createTask("Laundry")
+	oops := createTask("Dishes")
+	today := time.Now().UTC().Format("2006-01-02")
+	listPath := fmt.Sprintf("/api/v1/tasks?project_id=%d", project.ID)
+	datePath := "/api/v1/tasks/by-date?tz=UTC&date=" + today
+	rangePath := fmt.Sprintf("/api/v1/tasks/by-range?tz=UTC&from=%s&to=%s", today, today)
+
+	t.Run("Delete Moves To Trash", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, do("DELETE", fmt.Sprintf("/api/v1/tasks/%d", oops.ID), "").Code)
+
+		assert.Equal(t, []string{"Laundry"}, titles(listPath))
+		assert.Equal(t, []string{"Laundry"}, titles(datePath))
+		assert.Equal(t, []string{"Laundry"}, titles(rangePath))
+		assert.Equal(t, 1, total())
+		assert.Equal(t, []string{"Dishes"}, titles("/api/v1/tasks/trash"))
+
+		// trashed tasks can't be edited
+		assert.Equal(t, http.StatusNotFound, do("PATCH", fmt.Sprintf("/api/v1/tasks/%d", oops.ID), `{"status": "done"}`).Code)
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, do("POST", fmt.Sprintf("/api/v1/tasks/%d/restore", keep.ID), "").Code)
+		assert.Equal(t, http.StatusOK, do("POST", fmt.Sprintf("/api/v1/tasks/%d/restore", oops.ID), "").Code)
+
+		assert.ElementsMatch(t, []string{"Laundry", "Dishes"}, titles(listPath))
+		assert.Equal(t, 2, total())
+		assert.Empty(t, titles("/api/v1/tasks/trash"))
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		purgePath := fmt.Sprintf("/api/v1/tasks/%d/purge", oops.ID)
+		assert.Equal(t, http.StatusNotFound, do("DELETE", purgePath, "").Code)
+
+		do("DELETE", fmt.Sprintf("/api/v1/tasks/%d", oops.ID), "")
+		assert.Equal(t, http.StatusNoContent, do("DELETE", purgePath, "").Code)
+
+		var count int64
+		testDB.Unscoped().Model(&models.Task{}).Where("id = ?", oops.ID).Count(&count)
+		assert.Zero(t, count)
+		assert.Empty(t, titles("/api/v1/tasks/trash"))
+	})
+
+	t.Run("Purge After Retention", func(t *testing.T) {
+		old := createTask("Old")
+		do("DELETE", fmt.Sprintf("/api/v1/tasks/%d", old.ID), "")
+		testDB.Unscoped().Model(&models.Task{}).Where("id = ?", old.ID).
+			Update("deleted_at", time.Now().Add(-31*24*time.Hour))
+
+		purged, err := services.PurgeTrash(time.Now().Add(-30 * 24 * time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, purged)
+		assert.Empty(t, titles("/api/v1/tasks/trash"))
+	})
+}
+
+func TestTaskDetail(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	testDB := setupTestDB()
+	setupTestTokens()
+
+	r := gin.Default()
+	Setup(r)
+
+	owner, viewer, stranger := uint(1), uint(2), uint(3)
+	testDB.Create(&models.User{ID: owner, Email: "owner@example.com"})
+	testDB.Create(&models.User{ID: viewer, Email: "viewer@example.com"})
+
+	do := func(userID uint, method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+createTestToken(userID))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	var project models.Project
+	json.Unmarshal(do(owner, "POST", "/api/v1/projects", `{"name": "Garden"}`).Body.Bytes(), &project)
+	testDB.Create(&models.ProjectMember{ProjectID: project.ID, UserID: viewer, Role: models.RoleViewer})
+
+	var task models.Task
+	json.Unmarshal(do(owner, "POST", "/api/v1/tasks", fmt.Sprintf(`{"title": "Weed", "project_id": %d}`, project.ID)).Body.Bytes(), &task)
+	taskPath := fmt.Sprintf("/api/v1/tasks/%d", task.ID)
+
+	t.Run("Get Task", func(t *testing.T) {
+		w := do(viewer, "GET", taskPath, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var got models.Task
+		json.Unmarshal(w.Body.Bytes(), &got)
+		assert.Equal(t, "Weed", got.Title)
+		assert.Equal(t, "Garden", got.Project.Name)
+	})
+
+	t.Run("Not Found Versus Forbidden", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, do(stranger, "GET", taskPath, "").Code)
+		assert.Equal(t, http.StatusNotFound, do(owner, "GET", "/api/v1/tasks/9999", "").Code)
+		assert.Equal(t, http.StatusNotFound, do(owner, "GET", "/api/v1/tasks/abc", "").Code)
+
+		assert.Equal(t, http.StatusForbidden, do(viewer, "PATCH", taskPath, `{"status": "done"}`).Code)
+		assert.Equal(t, http.StatusForbidden, do(viewer, "DELETE", taskPath, "").Code)
+		assert.Equal(t, http.StatusNotFound, do(stranger, "PATCH", taskPath, `{"status": "done"}`).Code)
+		assert.Equal(t, http.StatusNotFound, do(stranger, "DELETE", taskPath, "").Code)
+
+		assert.Equal(t, http.StatusNotFound, do(owner, "PATCH", "/api/v1/tasks/9999", `{"status": "done"}`).Code)
+		assert.Equal(t, http.StatusNotFound, do(owner, "DELETE", "/api/v1/tasks/9999", "").Code)
+	})
+
+	t.Run("Update Then Delete", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, do(owner, "PATCH", taskPath, `{}`).Code)
+		assert.Equal(t, http.StatusNoContent, do(owner, "PATCH", taskPath, `{"status": "done"}`).Code)
+
+		var got models.Task
+		json.Unmarshal(do(owner, "GET", taskPath, "").Body.Bytes(), &got)
+		assert.Equal(t, "done", got.Status)
+
+		assert.Equal(t, http.StatusNoContent, do(owner, "DELETE", taskPath, "").Code)
+		assert.Equal(t, http.StatusNotFound, do(owner, "GET", taskPath, "").Code)
+		assert.Equal(t, http.StatusNotFound, do(owner, "PATCH", taskPath, `{"status": "todo"}`).Code)
+	})
+}
+
+func TestTaskFieldsMergePatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	testDB := setupTestDB()
+	setupTestTokens()
+
+	r := gin.Default()
+	Setup(r)
+
+	testDB.Create(&models.User{ID: 1, Email: "planner@example.com"})
+	authHeader := "Bearer " + createTestToken(1)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", authHeader)
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+	get := func(path string) models.Task {
+		var task models.Task
+		json.Unmarshal(do("GET", path, "").Body.Bytes(), &task)
+		return task
+	}
+
+	var project models.Project
+	json.Unmarshal(do("POST", "/api/v1/projects", `{"name": "Plan"}`).Body.Bytes(), &project)
+
+	t.Run("Create With Details", func(t *testing.T) {
+		w := do("POST", "/api/v1/tasks", fmt.Sprintf(`{
+			"title": "Write spec", "project_id": %d, "description": "## Scope\n- API",
+			"start_date": "2026-03-02T09:00:00+01:00", "due_date": "2026-03-06T17:30:00+01:00",
+			"estimate_minutes": 90
+		}`, project.ID))
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var task models.Task
+		json.Unmarshal(w.Body.Bytes(), &task)
+		assert.Equal(t, "## Scope\n- API", task.Description)
+		assert.False(t, task.AllDay)
+		assert.Equal(t, 90, *task.EstimateMinutes)
+		assert.Equal(t, time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC), task.StartDate.UTC())
+
+		var dueToday models.Task
+		json.Unmarshal(do("POST", "/api/v1/tasks", fmt.Sprintf(`{"title": "Quick", "project_id": %d}`, project.ID)).Body.Bytes(), &dueToday)
+		assert.True(t, dueToday.AllDay)
+
+		assert.Equal(t, http.StatusBadRequest, do("POST", "/api/v1/tasks", fmt.Sprintf(`{"title": "x", "project_id": %d, "estimate_minutes": -5}`, project.ID)).Code)
+	})
+
+	t.Run("Start Cannot Follow Due", func(t *testing.T) {
+		create := func(dates string) *httptest.ResponseRecorder {
+			return do("POST", "/api/v1/tasks", fmt.Sprintf(`{"title": "Trip", "project_id": %d, %s}`, project.ID, dates))
+		}
+		assert.Equal(t, http.StatusBadRequest, create(`"start_date": "2026-03-07T09:00:00Z", "due_date": "2026-03-06T17:00:00Z"`).Code)
+
+		// an all-day task is due until the end of its day
+		w := create(`"start_date": "2026-03-06T15:00:00Z", "due_date": "2026-03-06T00:00:00Z", "all_day": true`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var trip models.Task
+		json.Unmarshal(w.Body.Bytes(), &trip)
+		tripPath := fmt.Sprintf("/api/v1/tasks/%d", trip.ID)
+
+		// one date is checked against the other as stored
+		assert.Equal(t, http.StatusBadRequest, do("PATCH", tripPath, `{"start_date": "2026-03-08T09:00:00Z"}`).Code)
+		assert.Equal(t, http.StatusBadRequest, do("PATCH", tripPath, `{"due_date": "2026-03-05T00:00:00Z"}`).Code)
+		assert.Equal(t, http.StatusBadRequest, do("PATCH", tripPath, `{"all_day": false}`).Code)
+		assert.Equal(t, http.StatusNoContent, do("PATCH", tripPath, `{"start_date": "2026-03-08T09:00:00Z", "due_date": "2026-03-09T00:00:00Z"}`).Code)
+		assert.Equal(t, http.StatusNoContent, do("PATCH", tripPath, `{"due_date": null}`).Code)
+	})
+
+	var task models.Task
+	json.Unmarshal(do("POST", "/api/v1/tasks", fmt.Sprintf(`{"title": "Draft", "project_id": %d, "estimate_minutes": 30, "start_date": "2026-03-02T09:00:00Z"}`, project.ID)).Body.Bytes(), &task)
+	taskPath := fmt.Sprintf("/api/v1/tasks/%d", task.ID)
+
+	t.Run("Missing Members Stay", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, do("PATCH", taskPath, `{"title": "Final draft", "all_day": false}`).Code)
+
+		got := get(taskPath)
+		assert.Equal(t, "Final draft", got.Title)
+		assert.False(t, got.AllDay)
+		assert.Equal(t, 30, *got.EstimateMinutes)
+		assert.NotNil(t, got.StartDate)
+		assert.NotNil(t, got.DueDate)
+	})
+
+	t.Run("Null Clears", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, do("PATCH", taskPath, `{"estimate_minutes": null, "start_date": null, "due_date": null}`).Code)
+
+		got := get(taskPath)
+		assert.Nil(t, got.EstimateMinutes)
+		assert.Nil(t, got.StartDate)
+		assert.Nil(t, got.DueDate)
+		assert.Equal(t, "Final draft", got.Title)
+	})
+
+	t.Run("Required Fields Cannot Be Cleared", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, do("PATCH", taskPath, `{"title": null}`).Code)
+		assert.Equal(t, http.StatusBadRequest, do("PATCH", taskPath, `{"title": ""}`).Code)
+		assert.Equal(t, http.StatusBadRequest, do("PATCH", taskPath, `{"status": null}`).Code)
+		assert.Equal(t, http.StatusBadRequest, do("PATCH", taskPath, `{"estimate_minutes": -1}`).Code)
+		assert.Equal(t, http.StatusBadRequest, do("PATCH", taskPath, `{"estimate_minutes": "soon"}`).Code)
+	})
+
+	t.Run("Completion Is Stamped", func(t *testing.T) {
+		do("PATCH", taskPath, `{"status": "done"}`)
+		completed := get(taskPath).CompletedAt
+		assert.NotNil(t, completed)
+
+		// marking done again keeps the original time
+		do("PATCH", taskPath, `{"status": "done"}`)
+		assert.Equal(t, completed.Unix(), get(taskPath).CompletedAt.Unix())
+
+		do("PATCH", taskPath, `{"status": "todo"}`)
+		assert.Nil(t, get(taskPath).CompletedAt)
+
+		do("PATCH", taskPath, `{"status": "done", "completed_at": "2026-03-01T12:00:00Z"}`)
+		assert.Equal(t, time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), get(taskPath).CompletedAt.UTC())
+	})
+}
@@ -0,0 +1,108 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"flowday/internal/audit"
+	"flowday/internal/auth"
+	"flowday/internal/config"
+	"flowday/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginLockout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	testDB := setupTestDB()
+	setupTestTokens()
+	setupTestMailer()
+	defer auth.Init(config.DefaultAuth())
+
+	r := gin.Default()
+	Setup(r)
+
+	credentials := map[string]string{"email": "locked@example.com", "password": "password123"}
+	postJSON(r, "/api/v1/auth/register", credentials)
+	wrong := map[string]string{"email": "locked@example.com", "password": "not-the-password"}
+
+	for _, store := range []string{"memory", "db"} {
+		t.Run(store, func(t *testing.T) {
+			cfg := config.DefaultAuth()
+			cfg.LoginFreeAttempts = 3
+			cfg.LoginAttemptStore = store
+			auth.Init(cfg)
+			testDB.Where("1 = 1").Delete(&models.AuditEvent{})
+
+			for i := 0; i < 4; i++ {
+				assert.Equal(t, http.StatusUnauthorized, postJSON(r, "/api/v1/auth/login", wrong).Code)
+			}
+
+			// locked now, even with the right password
+			w := postJSON(r, "/api/v1/auth/login", credentials)
+			assert.Equal(t, http.StatusTooManyRequests, w.Code)
+			assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+			var events []models.AuditEvent
+			testDB.Where("event = ?", audit.EventLoginLockout).Find(&events)
+			assert.Len(t, events, 1)
+			if assert.NotEmpty(t, events) {
+				assert.NotNil(t, events[0].UserID)
+			}
+		})
+	}
+}
+
+func TestForwardedForIsOnlyTrustedFromProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	setupTestDB()
+	setupTestTokens()
+	setupTestMailer()
+	defer auth.Init(config.DefaultAuth())
+
+	// every login uses another account, so only the IP limit can trip
+	login := func(r *gin.Engine, i int, remoteAddr, forwardedFor string) int {
+		body, _ := json.Marshal(map[string]string{
+			"email":    fmt.Sprintf("nobody%d@example.com", i),
+			"password": "not-the-password",
+		})
+		req, _ := http.NewRequest("POST", "/api/v1/auth/login", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	cfg := config.DefaultAuth()
+	cfg.LoginFreeAttempts = 1
+
+	t.Run("Forged Header Is Ignored", func(t *testing.T) {
+		auth.Init(cfg)
+		r, err := New(config.HTTP{})
+		assert.NoError(t, err)
+
+		for i := 0; i < 11; i++ {
+			assert.Equal(t, http.StatusUnauthorized, login(r, i, "198.51.100.7:4000", fmt.Sprintf("203.0.113.%d", i)))
+		}
+		assert.Equal(t, http.StatusTooManyRequests, login(r, 11, "198.51.100.7:4000", "203.0.113.99"))
+	})
+
+	t.Run("Configured Proxy Is Believed", func(t *testing.T) {
+		auth.Init(cfg)
+		r, err := New(config.HTTP{TrustedProxies: []string{"10.0.0.0/8"}})
+		assert.NoError(t, err)
+
+		for i := 0; i < 12; i++ {
+			assert.Equal(t, http.StatusUnauthorized, login(r, i, "10.0.0.2:4000", fmt.Sprintf("203.0.113.%d", i)))
+		}
+	})
+}
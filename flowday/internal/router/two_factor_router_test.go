@@ -0,0 +1,178 @@
+package router
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"flowday/internal/auth"
+	"flowday/internal/config"
+	"flowday/internal/models"
+	"flowday/internal/randtoken"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// totpCode computes the RFC 6238 code an authenticator app would show at t
+func totpCode(secret string, t time.Time) string {
+	key, _ := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(t.Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1000000)
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	testDB := setupTestDB()
+	setupTestTokens()
+	setupTestMailer()
+
+	policy := config.DefaultAuth()
+	policy.KeyEncryptionSecret = "test-key-encryption-secret"
+	auth.Init(policy)
+	defer auth.Init(config.DefaultAuth())
+
+	r := gin.Default()
+	Setup(r)
+
+	credentials := map[string]string{"email": "mfa@example.com", "password": "password123"}
+	postJSON(r, "/api/v1/auth/register", credentials)
+
+	login := func() map[string]interface{} {
+		var body map[string]interface{}
+		json.Unmarshal(postJSON(r, "/api/v1/auth/login", credentials).Body.Bytes(), &body)
+		return body
+	}
+	authed := func(path, token string, body interface{}) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		data, _ := json.Marshal(body)
+		req, _ := http.NewRequest("POST", path, bytes.NewBuffer(data))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	access := login()["token"].(string)
+
+	w := authed("/api/v1/me/2fa/enroll", access, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var enrollment map[string]string
+	json.Unmarshal(w.Body.Bytes(), &enrollment)
+	secret := enrollment["secret"]
+	assert.Contains(t, enrollment["otpauth_uri"], "otpauth://totp/")
+
+	t.Run("Secret Is Sealed", func(t *testing.T) {
+		var user models.User
+		testDB.Where("email = ?", "mfa@example.com").First(&user)
+		assert.Contains(t, user.TOTPSecret, "sealed:")
+		assert.NotContains(t, user.TOTPSecret, secret)
+	})
+
+	t.Run("Confirm Requires Valid Code", func(t *testing.T) {
+		w := authed("/api/v1/me/2fa/confirm", access, map[string]string{"code": "000000"})
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	w = authed("/api/v1/me/2fa/confirm", access, map[string]string{"code": totpCode(secret, time.Now())})
+	require.Equal(t, http.StatusOK, w.Code)
+	var confirmed map[string][]string
+	json.Unmarshal(w.Body.Bytes(), &confirmed)
+	recovery := confirmed["recovery_codes"]
+	assert.Len(t, recovery, 10)
+
+	t.Run("Password Alone Gives A Challenge", func(t *testing.T) {
+		body := login()
+		assert.Equal(t, true, body["mfa_required"])
+		assert.Nil(t, body["token"])
+
+		// the challenge is not an access token
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/v1/me", nil)
+		req.Header.Set("Authorization", "Bearer "+body["challenge_token"].(string))
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("TOTP Completes Login Once", func(t *testing.T) {
+		challenge := login()["challenge_token"].(string)
+		code := totpCode(secret, time.Now().Add(30*time.Second))
+
+		w := postJSON(r, "/api/v1/auth/login/2fa", map[string]string{"challenge_token": challenge, "code": code})
+		assert.Equal(t, http.StatusOK, w.Code)
+		var tokens map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &tokens)
+		assert.NotEmpty(t, tokens["token"])
+
+		w = postJSON(r, "/api/v1/auth/login/2fa", map[string]string{"challenge_token": challenge, "code": code})
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "codes can't be replayed")
+	})
+
+	t.Run("Recovery Code Is Single Use", func(t *testing.T) {
+		challenge := login()["challenge_token"].(string)
+
+		w := postJSON(r, "/api/v1/auth/login/2fa", map[string]string{"challenge_token": challenge, "code": recovery[0]})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = postJSON(r, "/api/v1/auth/login/2fa", map[string]string{"challenge_token": challenge, "code": recovery[0]})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Challenge Is Single Use", func(t *testing.T) {
+		challenge := login()["challenge_token"].(string)
+
+		w := postJSON(r, "/api/v1/auth/login/2fa", map[string]string{"challenge_token": challenge, "code": recovery[1]})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = postJSON(r, "/api/v1/auth/login/2fa", map[string]string{"challenge_token": challenge, "code": recovery[2]})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		// the spent challenge didn't use up the code
+		w = postJSON(r, "/api/v1/auth/login/2fa", map[string]string{"challenge_token": login()["challenge_token"].(string), "code": recovery[2]})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Recovery Codes Are Keyed", func(t *testing.T) {
+		var hashes []string
+		testDB.Model(&models.RecoveryCode{}).Pluck("code_hash", &hashes)
+		require.Len(t, hashes, 10)
+		assert.NotContains(t, hashes, randtoken.Hash(recovery[3]))
+	})
+
+	t.Run("Disable", func(t *testing.T) {
+		w := authed("/api/v1/me/2fa/disable", access, map[string]string{"password": "wrong-password"})
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+		// wrong passwords share the login limits
+		throttled := policy
+		throttled.LoginFreeAttempts = 1
+		auth.Init(throttled)
+		authed("/api/v1/me/2fa/disable", access, map[string]string{"password": "wrong-password"})
+		authed("/api/v1/me/2fa/disable", access, map[string]string{"password": "wrong-password"})
+		w = authed("/api/v1/me/2fa/disable", access, map[string]string{"password": "password123"})
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		auth.Init(policy)
+
+		w = authed("/api/v1/me/2fa/disable", access, map[string]string{"password": "password123"})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.NotEmpty(t, login()["token"])
+	})
+}
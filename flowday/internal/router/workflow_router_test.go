@@ -0,0 +1,191 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"flowday/internal/models"
+	"flowday/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProjectWorkflow(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	testDB := setupTestDB()
+	setupTestTokens()
+
+	r := gin.Default()
+	Setup(r)
+
+	testDB.Create(&models.User{ID: 1, Email: "owner@example.com"})
+	testDB.Create(&models.User{ID: 2, Email: "editor@example.com"})
+
+	do := func(userID uint, method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+createTestToken(userID))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+	getTask := func(id uint) models.Task {
+		var task models.Task
+		json.Unmarshal(do(1, "GET", fmt.Sprintf("/api/v1/tasks/%d", id), "").Body.Bytes(), &task)
+		return task
+	}
+	createTask := func(projectID uint, body string) *httptest.ResponseRecorder {
+		return do(1, "POST", "/api/v1/tasks", fmt.Sprintf(`{"title": "Task", "project_id": %d%s}`, projectID, body))
+	}
+
+	var project models.Project
+	json.Unmarshal(do(1, "POST", "/api/v1/projects", `{"name": "Release"}`).Body.Bytes(), &project)
+	testDB.Create(&models.ProjectMember{ProjectID: project.ID, UserID: 2, Role: models.RoleEditor})
+	workflowPath := fmt.Sprintf("/api/v1/projects/%d/workflow", project.ID)
+
+	var legacy models.Task
+	json.Unmarshal(createTask(project.ID, "").Body.Bytes(), &legacy)
+
+	t.Run("Default Workflow", func(t *testing.T) {
+		w := do(2, "GET", workflowPath, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var workflow services.Workflow
+		json.Unmarshal(w.Body.Bytes(), &workflow)
+		assert.Len(t, workflow.States, 3)
+		assert.Equal(t, "todo", workflow.States[0].Key)
+		assert.True(t, workflow.States[0].IsDefault)
+		assert.Empty(t, workflow.Transitions)
+
+		assert.Equal(t, "todo", legacy.Status)
+		assert.Equal(t, models.CategoryTodo, legacy.StatusCategory)
+		assert.Equal(t, http.StatusUnprocessableEntity, createTask(project.ID, `, "status": "blocked"`).Code)
+	})
+
+	t.Run("Invalid Workflows", func(t *testing.T) {
+		for _, body := range []string{
+			`{"states": [{"key": "a", "name": "A", "category": "todo"}]}`,
+			`{"states": [{"key": "a", "name": "A", "category": "later", "default": true}]}`,
+			`{"states": [{"key": "a", "name": "A", "category": "todo", "default": true}, {"key": "a", "name": "B", "category": "done"}]}`,
+			`{"states": [{"key": "a", "name": "A", "category": "todo", "default": true}], "transitions": [{"from": "a", "to": "b"}]}`,
+		} {
+			assert.Equal(t, http.StatusUnprocessableEntity, do(1, "PUT", workflowPath, body).Code, body)
+		}
+
+		// todo is still used by a task.
+		assert.Equal(t, http.StatusUnprocessableEntity, do(1, "PUT", workflowPath,
+			`{"states": [{"key": "open", "name": "Open", "category": "todo", "default": true}]}`).Code)
+	})
+
+	custom := `{
+		"states": [
+			{"key": "todo", "name": "Backlog", "category": "todo", "default": true},
+			{"key": "review", "name": "In review", "category": "in_progress"},
+			{"key": "shipped", "name": "Shipped", "category": "done"}
+		],
+		"transitions": [
+			{"from": "todo", "to": "review"},
+			{"from": "review", "to": "shipped"},
+			{"from": "review", "to": "todo"}
+		]
+	}`
+
+	t.Run("Only Owners Replace", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, do(2, "PUT", workflowPath, custom).Code)
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		w := do(1, "PUT", workflowPath, custom)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var workflow services.Workflow
+		json.Unmarshal(do(2, "GET", workflowPath, "").Body.Bytes(), &workflow)
+		assert.Len(t, workflow.States, 3)
+		assert.Equal(t, "review", workflow.States[1].Key)
+		assert.Len(t, workflow.Transitions, 3)
+	})
+
+	taskPath := fmt.Sprintf("/api/v1/tasks/%d", legacy.ID)
+
+	t.Run("Transitions", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnprocessableEntity, do(2, "PATCH", taskPath, `{"status": "shipped"}`).Code)
+		assert.Equal(t, http.StatusUnprocessableEntity, do(2, "PATCH", taskPath, `{"status": "done"}`).Code)
+
+		assert.Equal(t, http.StatusNoContent, do(2, "PATCH", taskPath, `{"status": "review"}`).Code)
+		task := getTask(legacy.ID)
+		assert.Equal(t, models.CategoryInProgress, task.StatusCategory)
+		assert.Nil(t, task.CompletedAt)
+
+		assert.Equal(t, http.StatusNoContent, do(2, "PATCH", taskPath, `{"status": "shipped"}`).Code)
+		task = getTask(legacy.ID)
+		assert.Equal(t, models.CategoryDone, task.StatusCategory)
+		assert.NotNil(t, task.CompletedAt)
+
+		// Other fields can still change while the task is shipped.
+		assert.Equal(t, http.StatusNoContent, do(2, "PATCH", taskPath, `{"title": "Shipped task"}`).Code)
+	})
+
+	t.Run("Stats Use Categories", func(t *testing.T) {
+		var stats services.TaskStats
+		json.Unmarshal(do(1, "GET", "/api/v1/tasks/stats", "").Body.Bytes(), &stats)
+		assert.Equal(t, int64(1), stats.Total)
+		assert.Equal(t, int64(1), stats.Done)
+	})
+
+	t.Run("Subtasks Follow Transitions", func(t *testing.T) {
+		var parent, subtask models.Task
+		json.Unmarshal(createTask(project.ID, `, "status": "review"`).Body.Bytes(), &parent)
+		json.Unmarshal(createTask(project.ID, fmt.Sprintf(`, "parent_id": %d`, parent.ID)).Body.Bytes(), &subtask)
+		parentPath := fmt.Sprintf("/api/v1/tasks/%d", parent.ID)
+
+		// the subtask is still in todo, which cannot go straight to shipped
+		assert.Equal(t, http.StatusUnprocessableEntity, do(2, "PATCH", parentPath, `{"status": "shipped"}`).Code)
+		assert.Equal(t, "review", getTask(parent.ID).Status)
+		assert.Equal(t, "todo", getTask(subtask.ID).Status)
+
+		assert.Equal(t, http.StatusNoContent, do(2, "PATCH", fmt.Sprintf("/api/v1/tasks/%d", subtask.ID), `{"status": "review"}`).Code)
+		assert.Equal(t, http.StatusNoContent, do(2, "PATCH", parentPath, `{"status": "shipped"}`).Code)
+		assert.Equal(t, "shipped", getTask(subtask.ID).Status)
+	})
+
+	t.Run("Recategorize", func(t *testing.T) {
+		w := do(1, "PUT", workflowPath, `{"states": [
+			{"key": "todo", "name": "Backlog", "category": "todo", "default": true},
+			{"key": "review", "name": "In review", "category": "in_progress"},
+			{"key": "shipped", "name": "Shipped", "category": "in_progress"}
+		]}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		task := getTask(legacy.ID)
+		assert.Equal(t, models.CategoryInProgress, task.StatusCategory)
+		assert.Nil(t, task.CompletedAt, "no longer done, so no longer completed")
+
+		// Without transitions every move is allowed.
+		assert.Equal(t, http.StatusNoContent, do(2, "PATCH", taskPath, `{"status": "todo"}`).Code)
+
+		w = do(1, "PUT", workflowPath, `{"states": [
+			{"key": "todo", "name": "Backlog", "category": "done", "default": true},
+			{"key": "review", "name": "In review", "category": "in_progress"},
+			{"key": "shipped", "name": "Shipped", "category": "in_progress"}
+		]}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		task = getTask(legacy.ID)
+		assert.Equal(t, models.CategoryDone, task.StatusCategory)
+		assert.NotNil(t, task.CompletedAt, "done now, so completed now")
+	})
+
+	t.Run("Create In State", func(t *testing.T) {
+		w := createTask(project.ID, `, "status": "review"`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var task models.Task
+		json.Unmarshal(w.Body.Bytes(), &task)
+		assert.Equal(t, "review", task.Status)
+		assert.Equal(t, models.CategoryInProgress, task.StatusCategory)
+	})
+}
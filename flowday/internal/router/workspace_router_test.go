@@ -0,0 +1,152 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"flowday/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkspaces(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	testDB := setupTestDB()
+	setupTestTokens()
+
+	r := gin.Default()
+	Setup(r)
+
+	admin, member, outsider := uint(1), uint(2), uint(3)
+	for id, email := range map[uint]string{
+		admin: "admin@example.com", member: "member@example.com", outsider: "outsider@example.com",
+	} {
+		testDB.Create(&models.User{ID: id, Email: email})
+	}
+
+	do := func(userID uint, method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+createTestToken(userID))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+	createProject := func(userID, workspaceID uint, name string) (*httptest.ResponseRecorder, models.Project) {
+		w := do(userID, "POST", "/api/v1/projects", fmt.Sprintf(`{"name": %q, "workspace_id": %d}`, name, workspaceID))
+		var project models.Project
+		json.Unmarshal(w.Body.Bytes(), &project)
+		return w, project
+	}
+
+	w := do(admin, "POST", "/api/v1/workspaces", `{"name": "Acme", "default_project_role": "viewer"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var team models.Workspace
+	json.Unmarshal(w.Body.Bytes(), &team)
+	teamPath := fmt.Sprintf("/api/v1/workspaces/%d", team.ID)
+
+	_, personalProject := createProject(admin, 0, "Groceries")
+
+	t.Run("Personal Workspace Comes First", func(t *testing.T) {
+		var workspaces []models.Workspace
+		json.Unmarshal(do(admin, "GET", "/api/v1/workspaces", "").Body.Bytes(), &workspaces)
+		assert.Len(t, workspaces, 2)
+		assert.True(t, workspaces[0].Personal)
+		assert.Equal(t, workspaces[0].ID, personalProject.WorkspaceID)
+		assert.Equal(t, models.WorkspaceRoleAdmin, workspaces[1].Role)
+	})
+
+	t.Run("Listing Creates Nothing", func(t *testing.T) {
+		var before, after int64
+		testDB.Model(&models.Workspace{}).Count(&before)
+		assert.Equal(t, http.StatusOK, do(outsider, "GET", "/api/v1/workspaces", "").Code)
+		testDB.Model(&models.Workspace{}).Count(&after)
+		assert.Equal(t, before, after)
+	})
+
+	t.Run("Admins Manage Members", func(t *testing.T) {
+		w := do(admin, "POST", teamPath+"/members", `{"email": "member@example.com", "role": "member"}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		assert.Equal(t, http.StatusForbidden, do(member, "PATCH", teamPath, `{"name": "Mine now"}`).Code)
+		assert.Equal(t, http.StatusForbidden, do(member, "POST", teamPath+"/members", `{"email": "outsider@example.com", "role": "member"}`).Code)
+		assert.Equal(t, http.StatusNotFound, do(outsider, "GET", teamPath, "").Code)
+
+		var members []models.WorkspaceMember
+		json.Unmarshal(do(member, "GET", teamPath+"/members", "").Body.Bytes(), &members)
+		assert.Len(t, members, 2)
+		for _, m := range members {
+			assert.Equal(t, m.UserID, m.User.ID)
+		}
+		assert.NotContains(t, do(member, "GET", teamPath+"/members", "").Body.String(), "totp_enabled_at")
+	})
+
+	t.Run("Default Role On New Projects", func(t *testing.T) {
+		w, project := createProject(admin, team.ID, "Roadmap")
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, team.ID, project.WorkspaceID)
+
+		tasksPath := fmt.Sprintf("/api/v1/tasks?project_id=%d", project.ID)
+		assert.Equal(t, http.StatusOK, do(member, "GET", tasksPath, "").Code)
+		taskBody := fmt.Sprintf(`{"title": "Ship", "project_id": %d}`, project.ID)
+		assert.Equal(t, http.StatusForbidden, do(member, "POST", "/api/v1/tasks", taskBody).Code)
+
+		// only workspace members can be added to team projects
+		membersPath := fmt.Sprintf("/api/v1/projects/%d/members", project.ID)
+		assert.Equal(t, http.StatusBadRequest, do(admin, "POST", membersPath, `{"email": "outsider@example.com", "role": "editor"}`).Code)
+	})
+
+	t.Run("Projects Scoped By Workspace", func(t *testing.T) {
+		var projects []models.Project
+		json.Unmarshal(do(admin, "GET", fmt.Sprintf("/api/v1/projects?workspace_id=%d", team.ID), "").Body.Bytes(), &projects)
+		assert.Len(t, projects, 1)
+		assert.Equal(t, "Roadmap", projects[0].Name)
+
+		json.Unmarshal(do(admin, "GET", "/api/v1/projects", "").Body.Bytes(), &projects)
+		assert.Len(t, projects, 2)
+
+		assert.Equal(t, http.StatusNotFound, do(outsider, "GET", fmt.Sprintf("/api/v1/projects?workspace_id=%d", team.ID), "").Code)
+		w, _ := createProject(outsider, team.ID, "Sneaky")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Quotas", func(t *testing.T) {
+		w := do(admin, "PATCH", teamPath, `{"max_projects": 2, "max_members": 2}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w, _ = createProject(member, team.ID, "Second")
+		assert.Equal(t, http.StatusCreated, w.Code)
+		w, _ = createProject(member, team.ID, "Third")
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = do(admin, "POST", teamPath+"/members", `{"email": "outsider@example.com", "role": "member"}`)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("Leaving", func(t *testing.T) {
+		adminPath := fmt.Sprintf("%s/members/%d", teamPath, admin)
+		assert.Equal(t, http.StatusConflict, do(admin, "DELETE", adminPath, "").Code)
+
+		// the member owns "Second" alone, so they must hand it over first
+		memberPath := fmt.Sprintf("%s/members/%d", teamPath, member)
+		assert.Equal(t, http.StatusConflict, do(member, "DELETE", memberPath, "").Code)
+
+		var projects []models.Project
+		json.Unmarshal(do(member, "GET", fmt.Sprintf("/api/v1/projects?workspace_id=%d", team.ID), "").Body.Bytes(), &projects)
+		for _, p := range projects {
+			if p.Name == "Second" {
+				assert.Equal(t, http.StatusNoContent, do(member, "DELETE", fmt.Sprintf("/api/v1/projects/%d", p.ID), "").Code)
+			}
+		}
+
+		assert.Equal(t, http.StatusNoContent, do(member, "DELETE", memberPath, "").Code)
+		json.Unmarshal(do(member, "GET", "/api/v1/projects", "").Body.Bytes(), &projects)
+		assert.Empty(t, projects)
+	})
+}
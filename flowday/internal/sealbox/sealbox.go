@@ -0,0 +1,102 @@
+// Package sealbox protects secrets the database has to keep. Values are
+// sealed with AES-GCM under a key derived from the configured key
+// encryption secret, and values that are looked up by hash get an HMAC
+// under a second key derived from it.
+package sealbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// Prefix marks a sealed value.
+const Prefix = "sealed:"
+
+var (
+	ErrNoSecret  = errors.New("no key encryption secret is configured")
+	ErrNotSealed = errors.New("value is not sealed")
+)
+
+type Box struct {
+	secret string
+}
+
+func New(secret string) Box {
+	return Box{secret: secret}
+}
+
+// Configured tells whether the box has a secret to seal with.
+func (b Box) Configured() bool {
+	return b.secret != ""
+}
+
+// Seal encrypts plain and returns it with Prefix.
+func (b Box) Seal(plain []byte) (string, error) {
+	aead, err := b.aead()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, plain, nil)
+	return Prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open reverses Seal.
+func (b Box) Open(stored string) ([]byte, error) {
+	encoded, ok := strings.CutPrefix(stored, Prefix)
+	if !ok {
+		return nil, ErrNotSealed
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := b.aead()
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed value is too short")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
+
+// MAC returns the hex encoded HMAC-SHA256 of value. Unlike a plain hash it
+// can't be brute forced from a leaked table without the secret.
+func (b Box) MAC(value string) (string, error) {
+	if b.secret == "" {
+		return "", ErrNoSecret
+	}
+
+	key := sha256.Sum256([]byte("mac:" + b.secret))
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func (b Box) aead() (cipher.AEAD, error) {
+	if b.secret == "" {
+		return nil, ErrNoSecret
+	}
+
+	sum := sha256.Sum256([]byte(b.secret))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
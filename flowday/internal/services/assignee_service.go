@@ -0,0 +1,73 @@
+package services
+
+import (
+	"errors"
+
+	"flowday/internal/db"
+	appErrors "flowday/internal/errors"
+	"flowday/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// AssignTask adds a member of the task's project to its assignees.
+func AssignTask(userID, taskID, assigneeID uint) error {
+	task, err := authorizeTask(userID, taskID, models.RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	var member models.ProjectMember
+	err = db.DB.
+		Where("project_id = ? AND user_id = ?", task.ProjectID, assigneeID).
+		First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return appErrors.ErrNotProjectMember
+	}
+	if err != nil {
+		return err
+	}
+
+	return db.DB.Model(task).Omit("Assignees.*").Association("Assignees").Append(&models.PublicUser{ID: member.UserID})
+}
+
+func UnassignTask(userID, taskID, assigneeID uint) error {
+	task, err := authorizeTask(userID, taskID, models.RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	return db.DB.Model(task).Association("Assignees").Delete(&models.PublicUser{ID: assigneeID})
+}
+
+// unassign takes a user off every task of the given projects, trashed
+// tasks included; projects is a list of ids or a subquery selecting them.
+func unassign(tx *gorm.DB, userID uint, projects interface{}) error {
+	tasks := tx.Unscoped().Model(&models.Task{}).Select("id").Where("project_id IN (?)", projects)
+	return tx.Exec("DELETE FROM task_assignees WHERE user_id = ? AND task_id IN (?)", userID, tasks).Error
+}
+
+// fillAssignees sets the assignees of tasks.
+func fillAssignees(byID map[uint]*models.Task, ids []uint) error {
+	var rows []struct {
+		TaskID uint
+		models.PublicUser
+	}
+	err := db.DB.Table("task_assignees").
+		Select("task_assignees.task_id, users.id, users.email, users.display_name").
+		Joins("JOIN users ON users.id = task_assignees.user_id").
+		Where("task_assignees.task_id IN ?", ids).
+		Order("users.id").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	for _, task := range byID {
+		task.Assignees = []models.PublicUser{}
+	}
+	for _, row := range rows {
+		byID[row.TaskID].Assignees = append(byID[row.TaskID].Assignees, row.PublicUser)
+	}
+	return nil
+}
@@ -7,25 +7,22 @@ import (
 	"flowday/internal/models"
 )
 
-func GetTasksByDate(userID uint, date time.Time) ([]models.Task, error) {
-	start := time.Date(
-		date.Year(),
-		date.Month(),
-		date.Day(),
-		0, 0, 0, 0,
-		date.Location(),
-	)
-	end := start.Add(24 * time.Hour)
+// GetTasksByDate returns the tasks due on the calendar day of date, taken in
+// date's location.
+func GetTasksByDate(userID uint, date time.Time, filter TaskFilter) ([]models.Task, error) {
+	start, end := dayBounds(date)
 
 	var tasks []models.Task
-	err := db.DB.
-		Joins("JOIN projects ON projects.id = tasks.project_id").
+	err := filterTasks(db.DB, userID, filter).
 		Where(
-			"projects.user_id = ? AND tasks.due_date IS NOT NULL AND tasks.due_date >= ? AND tasks.due_date < ?",
-			userID, start, end,
+			"tasks.project_id IN (?) AND tasks.due_date IS NOT NULL AND tasks.due_date >= ? AND tasks.due_date < ?",
+			memberProjects(userID, filter), start, end,
 		).
 		Preload("Project").
 		Find(&tasks).Error
+	if err != nil {
+		return nil, err
+	}
 
-	return tasks, err
+	return tasks, decorateTasks(userID, tasks)
 }
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"errors"
+
+	"flowday/internal/db"
+	"flowday/internal/dto"
+	appErrors "flowday/internal/errors"
+	"flowday/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// GetChecklist returns a task's checklist in order.
+func GetChecklist(userID, taskID uint) ([]models.ChecklistItem, error) {
+	task, err := authorizeTask(userID, taskID, models.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []models.ChecklistItem{}
+	err = db.DB.Where("task_id = ?", task.ID).Order("position, id").Find(&items).Error
+	return items, err
+}
+
+func AddChecklistItem(userID, taskID uint, req dto.AddChecklistItemRequest) (*models.ChecklistItem, error) {
+	task, err := authorizeTask(userID, taskID, models.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	item := models.ChecklistItem{TaskID: task.ID, Text: req.Text}
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.ChecklistItem{}).Where("task_id = ?", task.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		item.Position = int(count)
+		if err := tx.Create(&item).Error; err != nil {
+			return err
+		}
+		if req.Position != nil {
+			return placeChecklistItem(tx, &item, *req.Position)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func UpdateChecklistItem(userID, taskID, itemID uint, req dto.UpdateChecklistItemRequest) (*models.ChecklistItem, error) {
+	item, err := authorizeChecklistItem(userID, taskID, itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{}
+		if req.Text != nil {
+			updates["text"] = *req.Text
+		}
+		if req.Checked != nil {
+			updates["checked"] = *req.Checked
+		}
+		if len(updates) > 0 {
+			if err := tx.Model(item).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if req.Position != nil {
+			return placeChecklistItem(tx, item, *req.Position)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func DeleteChecklistItem(userID, taskID, itemID uint) error {
+	item, err := authorizeChecklistItem(userID, taskID, itemID)
+	if err != nil {
+		return err
+	}
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(item).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.ChecklistItem{}).
+			Where("task_id = ? AND position > ?", item.TaskID, item.Position).
+			Update("position", gorm.Expr("position - 1")).Error
+	})
+}
+
+// authorizeChecklistItem loads an item of a task the user can edit.
+func authorizeChecklistItem(userID, taskID, itemID uint) (*models.ChecklistItem, error) {
+	task, err := authorizeTask(userID, taskID, models.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	var item models.ChecklistItem
+	err = db.DB.Where("id = ? AND task_id = ?", itemID, task.ID).First(&item).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, appErrors.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// placeChecklistItem moves an item to position, or to the end when position
+// is past it, and renumbers the rest of the checklist.
+func placeChecklistItem(tx *gorm.DB, item *models.ChecklistItem, position int) error {
+	var ids []uint
+	err := tx.Model(&models.ChecklistItem{}).
+		Where("task_id = ? AND id <> ?", item.TaskID, item.ID).
+		Order("position, id").
+		Pluck("id", &ids).Error
+	if err != nil {
+		return err
+	}
+
+	if position > len(ids) {
+		position = len(ids)
+	}
+	ids = append(ids[:position], append([]uint{item.ID}, ids[position:]...)...)
+
+	for i, id := range ids {
+		if err := tx.Model(&models.ChecklistItem{}).Where("id = ?", id).Update("position", i).Error; err != nil {
+			return err
+		}
+	}
+	item.Position = position
+	return nil
+}
+
+// fillChecklists sets the checklist progress of tasks.
+func fillChecklists(byID map[uint]*models.Task, ids []uint) error {
+	var rows []struct {
+		TaskID  uint
+		Checked int64
+		Total   int64
+	}
+	err := db.DB.Model(&models.ChecklistItem{}).
+		Select("task_id, COUNT(*) AS total, SUM(CASE WHEN checked THEN 1 ELSE 0 END) AS checked").
+		Where("task_id IN ?", ids).
+		Group("task_id").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		byID[row.TaskID].Checklist = models.ChecklistProgress{Checked: row.Checked, Total: row.Total}
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"flowday/internal/db"
+	"flowday/internal/dto"
+	appErrors "flowday/internal/errors"
+	"flowday/internal/markdown"
+	"flowday/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// mentionPattern matches @name and @someone@example.com, but not the domain
+// part of a plain email address.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@.])@([\w.+-]*\w(?:@[\w-]+(?:\.[\w-]+)+)?)`)
+
+// GetComments lists a task's threads oldest first, each with its replies.
+// Pagination applies to the threads.
+func GetComments(userID, taskID uint, limit, offset int) ([]models.Comment, error) {
+	task, err := authorizeTask(userID, taskID, models.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	threads := []models.Comment{}
+	err = db.DB.
+		Where("task_id = ? AND parent_id IS NULL", task.ID).
+		Preload("Author", publicUserColumns).
+		Preload("Mentions", publicUserColumns).
+		Order("created_at, id").
+		Limit(limit).
+		Offset(offset).
+		Find(&threads).Error
+	if err != nil || len(threads) == 0 {
+		return threads, err
+	}
+
+	ids := make([]uint, len(threads))
+	for i, thread := range threads {
+		ids[i] = thread.ID
+	}
+
+	var replies []models.Comment
+	err = db.DB.
+		Where("parent_id IN ?", ids).
+		Preload("Author", publicUserColumns).
+		Preload("Mentions", publicUserColumns).
+		Order("created_at, id").
+		Find(&replies).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byThread := map[uint][]models.Comment{}
+	for _, reply := range replies {
+		byThread[*reply.ParentID] = append(byThread[*reply.ParentID], reply)
+	}
+	for i := range threads {
+		threads[i].Replies = byThread[threads[i].ID]
+	}
+	return threads, nil
+}
+
+// CreateComment posts a comment as the user, who must be at least a
+// commenter on the task's project. A reply to a reply joins the same
+// thread.
+func CreateComment(userID, taskID uint, req dto.CreateCommentRequest) (*models.Comment, error) {
+	task, err := authorizeTask(userID, taskID, models.RoleCommenter)
+	if err != nil {
+		return nil, err
+	}
+
+	comment := models.Comment{TaskID: task.ID, UserID: userID}
+	if req.ParentID != nil {
+		var parent models.Comment
+		err := db.DB.Where("id = ? AND task_id = ?", *req.ParentID, task.ID).First(&parent).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: parent comment not found", appErrors.ErrInvalidInput)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		thread := parent.ID
+		if parent.ParentID != nil {
+			thread = *parent.ParentID
+		}
+		comment.ParentID = &thread
+	}
+
+	if err := setCommentBody(&comment, task.ProjectID, req.Body); err != nil {
+		return nil, err
+	}
+	if err := db.DB.Omit("Mentions.*").Create(&comment).Error; err != nil {
+		return nil, err
+	}
+	return loadComment(comment.ID)
+}
+
+// UpdateComment lets authors edit their comments. The previous body is
+// kept as a revision.
+func UpdateComment(userID, taskID, commentID uint, req dto.UpdateCommentRequest) (*models.Comment, error) {
+	task, comment, err := authorizeComment(userID, taskID, commentID, models.RoleCommenter)
+	if err != nil {
+		return nil, err
+	}
+	if comment.UserID != userID {
+		return nil, appErrors.ErrForbidden
+	}
+	if req.Body == comment.Body {
+		return loadComment(comment.ID)
+	}
+
+	revision := models.CommentRevision{CommentID: comment.ID, Body: comment.Body}
+	if err := setCommentBody(comment, task.ProjectID, req.Body); err != nil {
+		return nil, err
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&revision).Error; err != nil {
+			return err
+		}
+
+		err := tx.Model(comment).Updates(map[string]interface{}{
+			"body":      comment.Body,
+			"body_html": comment.BodyHTML,
+			"edited_at": time.Now().UTC(),
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(comment).Omit("Mentions.*").Association("Mentions").Replace(comment.Mentions)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loadComment(comment.ID)
+}
+
+// DeleteComment removes a comment, and its replies when it starts a
+// thread. Authors can delete their own comments and project owners any.
+func DeleteComment(userID, taskID, commentID uint) error {
+	task, comment, err := authorizeComment(userID, taskID, commentID, models.RoleCommenter)
+	if err != nil {
+		return err
+	}
+	if comment.UserID != userID {
+		if _, err := authorizeProject(userID, task.ProjectID, models.RoleOwner); err != nil {
+			return err
+		}
+	}
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		return deleteComments(tx, tx.Model(&models.Comment{}).
+			Select("id").
+			Where("id = ? OR parent_id = ?", comment.ID, comment.ID))
+	})
+}
+
+// GetCommentRevisions lists the earlier bodies of a comment, oldest first,
+// to anyone who can read the comment.
+func GetCommentRevisions(userID, taskID, commentID uint) ([]models.CommentRevision, error) {
+	if _, _, err := authorizeComment(userID, taskID, commentID, models.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	revisions := []models.CommentRevision{}
+	err := db.DB.Where("comment_id = ?", commentID).Order("created_at, id").Find(&revisions).Error
+	return revisions, err
+}
+
+// authorizeComment loads a comment of a task the user has at least the
+// given role on.
+func authorizeComment(userID, taskID, commentID uint, role string) (*models.Task, *models.Comment, error) {
+	task, err := authorizeTask(userID, taskID, role)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var comment models.Comment
+	err = db.DB.Where("id = ? AND task_id = ?", commentID, task.ID).First(&comment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, appErrors.ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return task, &comment, nil
+}
+
+func loadComment(commentID uint) (*models.Comment, error) {
+	var comment models.Comment
+	err := db.DB.Preload("Author", publicUserColumns).Preload("Mentions", publicUserColumns).First(&comment, commentID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// setCommentBody renders the body and resolves its mentions.
+func setCommentBody(comment *models.Comment, projectID uint, body string) error {
+	html, err := markdown.Render(body)
+	if err != nil {
+		return err
+	}
+	mentions, err := resolveMentions(projectID, body)
+	if err != nil {
+		return err
+	}
+
+	comment.Body = body
+	comment.BodyHTML = html
+	comment.Mentions = mentions
+	return nil
+}
+
+// resolveMentions finds the project members mentioned in a body. A member
+// is mentioned by email address, by the part of it before the @, or by
+// display name without spaces, ignoring case. Anything else stays text.
+func resolveMentions(projectID uint, body string) ([]models.PublicUser, error) {
+	names := map[string]bool{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		names[strings.ToLower(match[1])] = true
+	}
+	mentions := []models.PublicUser{}
+	if len(names) == 0 {
+		return mentions, nil
+	}
+
+	var members []models.PublicUser
+	err := publicUserColumns(db.DB).
+		Where("id IN (?)", db.DB.Model(&models.ProjectMember{}).Select("user_id").Where("project_id = ?", projectID)).
+		Order("id").
+		Find(&members).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, member := range members {
+		email := strings.ToLower(member.Email)
+		local, _, _ := strings.Cut(email, "@")
+		display := strings.ToLower(strings.ReplaceAll(member.DisplayName, " ", ""))
+		if names[email] || names[local] || (display != "" && names[display]) {
+			mentions = append(mentions, member)
+		}
+	}
+	return mentions, nil
+}
+
+// deleteComments removes comments with their revisions and mentions;
+// comments is a list of ids or a subquery selecting them.
+func deleteComments(tx *gorm.DB, comments interface{}) error {
+	var ids []uint
+	if err := tx.Model(&models.Comment{}).Where("id IN (?)", comments).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := tx.Where("comment_id IN ?", ids).Delete(&models.CommentRevision{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Exec("DELETE FROM comment_mentions WHERE comment_id IN ?", ids).Error; err != nil {
+		return err
+	}
+	return tx.Where("id IN ?", ids).Delete(&models.Comment{}).Error
+}
@@ -0,0 +1,10 @@
+package services
+
+import "flowday/internal/config"
+
+var settings = config.DefaultProjects()
+
+// Init applies collaboration settings; until called the defaults are used.
+func Init(cfg config.Projects) {
+	settings = cfg
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"flowday/internal/db"
+	appErrors "flowday/internal/errors"
+	"flowday/internal/mailer"
+	"flowday/internal/models"
+	"flowday/internal/randtoken"
+
+	"gorm.io/gorm"
+)
+
+// InviteToProject invites an email address to the project and mails it a
+// link. A newer invitation to the same address replaces the older one.
+// Owners of team projects also need to be workspace admins, since accepting
+// adds the invitee to the workspace.
+func InviteToProject(userID, projectID uint, email, role string) (*models.ProjectInvitation, error) {
+	email = models.NormalizeEmail(email)
+	if _, err := authorizeProject(userID, projectID, models.RoleOwner); err != nil {
+		return nil, err
+	}
+
+	var project models.Project
+	if err := db.DB.First(&project, projectID).Error; err != nil {
+		return nil, appErrors.ErrNotFound
+	}
+	var workspace models.Workspace
+	if err := db.DB.First(&workspace, project.WorkspaceID).Error; err == nil && !workspace.Personal {
+		if _, err := authorizeWorkspace(userID, workspace.ID, true); err != nil {
+			return nil, appErrors.ErrForbidden
+		}
+	}
+
+	invitation := models.ProjectInvitation{
+		ProjectID: projectID,
+		Email:     email,
+		InviterID: userID,
+		Role:      role,
+		Status:    models.InvitationPending,
+		ExpiresAt: time.Now().Add(settings.InvitationTTL),
+	}
+
+	var invitee models.User
+	if err := db.DB.Where("lower(email) = ?", email).First(&invitee).Error; err == nil {
+		var members int64
+		if err := db.DB.Model(&models.ProjectMember{}).
+			Where("project_id = ? AND user_id = ?", projectID, invitee.ID).
+			Count(&members).Error; err != nil {
+			return nil, err
+		}
+		if members > 0 {
+			return nil, appErrors.ErrAlreadyMember
+		}
+		invitation.InviteeID = &invitee.ID
+	}
+
+	raw, hash, err := randtoken.New()
+	if err != nil {
+		return nil, err
+	}
+	invitation.TokenHash = hash
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.ProjectInvitation{}).
+			Where("project_id = ? AND email = ? AND status = ?", projectID, email, models.InvitationPending).
+			Update("status", models.InvitationRevoked).Error; err != nil {
+			return err
+		}
+		return tx.Create(&invitation).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	link := settings.AppURL + "/invitations?token=" + url.QueryEscape(raw)
+	if err := mailer.Send(mailer.Message{
+		To:      email,
+		Subject: fmt.Sprintf("You're invited to %s on Flowday", project.Name),
+		Body: fmt.Sprintf(
+			"You have been invited to join the project %q as %s.\n\n"+
+				"Open this link within %s to accept:\n%s\n",
+			project.Name, role, settings.InvitationTTL, link,
+		),
+	}); err != nil {
+		log.Println("invitation mail failed:", err)
+	}
+
+	return &invitation, nil
+}
+
+// GetProjectInvitations lists the project's open invitations for its owners.
+func GetProjectInvitations(userID, projectID uint) ([]models.ProjectInvitation, error) {
+	if _, err := authorizeProject(userID, projectID, models.RoleOwner); err != nil {
+		return nil, err
+	}
+
+	var invitations []models.ProjectInvitation
+	err := db.DB.
+		Where("project_id = ? AND status = ? AND expires_at > ?", projectID, models.InvitationPending, time.Now()).
+		Order("created_at").
+		Find(&invitations).Error
+	return invitations, err
+}
+
+func RevokeInvitation(userID, projectID, invitationID uint) error {
+	if _, err := authorizeProject(userID, projectID, models.RoleOwner); err != nil {
+		return err
+	}
+
+	result := db.DB.Model(&models.ProjectInvitation{}).
+		Where("id = ? AND project_id = ? AND status = ?", invitationID, projectID, models.InvitationPending).
+		Update("status", models.InvitationRevoked)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return appErrors.ErrNotFound
+	}
+	return nil
+}
+
+// GetMyInvitations lists the open invitations addressed to the user.
+func GetMyInvitations(userID uint) ([]models.ProjectInvitation, error) {
+	var invitations []models.ProjectInvitation
+	err := db.DB.
+		Where("invitee_id = ? AND status = ? AND expires_at > ?", userID, models.InvitationPending, time.Now()).
+		Preload("Project").
+		Order("created_at").
+		Find(&invitations).Error
+	return invitations, err
+}
+
+// AcceptInvitation accepts an invitation from the user's list. The address
+// it was sent to has to be verified, since anyone can register with it.
+func AcceptInvitation(userID, invitationID uint) (*models.ProjectMember, error) {
+	var user models.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		return nil, appErrors.ErrNotFound
+	}
+	if user.EmailVerifiedAt == nil {
+		return nil, appErrors.ErrEmailNotVerified
+	}
+
+	var invitation models.ProjectInvitation
+	if err := db.DB.Where("id = ? AND invitee_id = ?", invitationID, userID).First(&invitation).Error; err != nil {
+		return nil, appErrors.ErrNotFound
+	}
+	// The binding may predate a change of address.
+	if models.NormalizeEmail(user.Email) != invitation.Email {
+		return nil, fmt.Errorf("%w: the invitation was sent to another address", appErrors.ErrForbidden)
+	}
+
+	return acceptInvitation(&invitation, userID)
+}
+
+// AcceptInvitationToken accepts the invitation behind a mailed link. Links
+// get forwarded, so the signed-in user has to have verified the address it
+// was sent to.
+func AcceptInvitationToken(userID uint, rawToken string) (*models.ProjectMember, error) {
+	var invitation models.ProjectInvitation
+	if err := db.DB.Where("token_hash = ?", randtoken.Hash(rawToken)).First(&invitation).Error; err != nil {
+		return nil, appErrors.ErrInvalidToken
+	}
+
+	var user models.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		return nil, appErrors.ErrNotFound
+	}
+	if models.NormalizeEmail(user.Email) != invitation.Email {
+		return nil, fmt.Errorf("%w: the invitation was sent to another address", appErrors.ErrForbidden)
+	}
+	if user.EmailVerifiedAt == nil {
+		return nil, appErrors.ErrEmailNotVerified
+	}
+
+	return acceptInvitation(&invitation, userID)
+}
+
+func DeclineInvitation(userID, invitationID uint) error {
+	result := db.DB.Model(&models.ProjectInvitation{}).
+		Where("id = ? AND invitee_id = ? AND status = ?", invitationID, userID, models.InvitationPending).
+		Updates(map[string]interface{}{
+			"status":       models.InvitationDeclined,
+			"responded_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return appErrors.ErrNotFound
+	}
+	return nil
+}
+
+// acceptInvitation makes the user a project member, and a member of the
+// project's team workspace if they aren't yet.
+func acceptInvitation(invitation *models.ProjectInvitation, userID uint) (*models.ProjectMember, error) {
+	if invitation.Status != models.InvitationPending || time.Now().After(invitation.ExpiresAt) {
+		return nil, appErrors.ErrInvitationClosed
+	}
+
+	member := models.ProjectMember{ProjectID: invitation.ProjectID, UserID: userID, Role: invitation.Role}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var project models.Project
+		if err := tx.First(&project, invitation.ProjectID).Error; err != nil {
+			return appErrors.ErrNotFound
+		}
+
+		workspace, err := lockWorkspace(tx, project.WorkspaceID)
+		if err != nil && !errors.Is(err, appErrors.ErrNotFound) {
+			return err
+		}
+		if workspace != nil && !workspace.Personal {
+			if err := joinWorkspace(tx, workspace, userID); err != nil {
+				return err
+			}
+		}
+
+		var existing int64
+		if err := tx.Model(&models.ProjectMember{}).
+			Where("project_id = ? AND user_id = ?", invitation.ProjectID, userID).
+			Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return appErrors.ErrAlreadyMember
+		}
+		if err := tx.Create(&member).Error; err != nil {
+			return err
+		}
+
+		// Guard against the invitation being answered concurrently.
+		result := tx.Model(&models.ProjectInvitation{}).
+			Where("id = ? AND status = ?", invitation.ID, models.InvitationPending).
+			Updates(map[string]interface{}{
+				"status":       models.InvitationAccepted,
+				"invitee_id":   userID,
+				"responded_at": time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return appErrors.ErrInvitationClosed
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &member, nil
+}
+
+// joinWorkspace adds the user to the workspace as a plain member, within
+// its member quota. Existing members are left alone. The caller holds the
+// workspace's lock (see lockWorkspace).
+func joinWorkspace(tx *gorm.DB, workspace *models.Workspace, userID uint) error {
+	var member models.WorkspaceMember
+	err := tx.Where("workspace_id = ? AND user_id = ?", workspace.ID, userID).First(&member).Error
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	if workspace.MaxMembers > 0 {
+		var members int64
+		if err := tx.Model(&models.WorkspaceMember{}).Where("workspace_id = ?", workspace.ID).Count(&members).Error; err != nil {
+			return err
+		}
+		if members >= int64(workspace.MaxMembers) {
+			return appErrors.ErrQuotaExceeded
+		}
+	}
+
+	return tx.Create(&models.WorkspaceMember{
+		WorkspaceID: workspace.ID,
+		UserID:      userID,
+		Role:        models.WorkspaceRoleMember,
+	}).Error
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"flowday/internal/db"
+	"flowday/internal/dto"
+	appErrors "flowday/internal/errors"
+	"flowday/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// visibleLabels selects the labels a user can see: their own and those of
+// the projects they belong to.
+func visibleLabels(userID uint) *gorm.DB {
+	return db.DB.Model(&models.Label{}).Where(
+		"labels.user_id = ? OR labels.project_id IN (?)",
+		userID, memberProjects(userID, TaskFilter{IncludeArchived: true}),
+	)
+}
+
+// GetLabels lists the user's own labels and the labels of their projects,
+// or of a single project when projectID is set.
+func GetLabels(userID, projectID uint) ([]models.Label, error) {
+	query := visibleLabels(userID)
+	if projectID != 0 {
+		if _, err := authorizeProject(userID, projectID, models.RoleViewer); err != nil {
+			return nil, err
+		}
+		query = db.DB.Where("user_id = ? OR project_id = ?", userID, projectID)
+	}
+
+	labels := []models.Label{}
+	err := query.Order("name, id").Find(&labels).Error
+	return labels, err
+}
+
+// CreateLabel adds a personal label, or a project label when the request
+// names a project the user can edit.
+func CreateLabel(userID uint, req dto.CreateLabelRequest) (*models.Label, error) {
+	label := models.Label{Name: strings.TrimSpace(req.Name), Color: req.Color}
+	if req.ProjectID != 0 {
+		if _, err := authorizeProject(userID, req.ProjectID, models.RoleEditor); err != nil {
+			return nil, err
+		}
+		label.ProjectID = &req.ProjectID
+	} else {
+		label.UserID = &userID
+	}
+
+	if err := checkLabelName(&label); err != nil {
+		return nil, err
+	}
+	if err := db.DB.Create(&label).Error; err != nil {
+		return nil, err
+	}
+	return &label, nil
+}
+
+func UpdateLabel(userID, labelID uint, req dto.UpdateLabelRequest) (*models.Label, error) {
+	label, err := authorizeLabel(userID, labelID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		label.Name = strings.TrimSpace(*req.Name)
+		if err := checkLabelName(label); err != nil {
+			return nil, err
+		}
+		updates["name"] = label.Name
+	}
+	if req.Color.Set {
+		label.Color = req.Color.Value // empty when null
+		updates["color"] = label.Color
+	}
+	if len(updates) == 0 {
+		return label, nil
+	}
+
+	if err := db.DB.Model(label).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	return label, nil
+}
+
+// DeleteLabel removes a label and takes it off every task.
+func DeleteLabel(userID, labelID uint) error {
+	label, err := authorizeLabel(userID, labelID)
+	if err != nil {
+		return err
+	}
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM task_labels WHERE label_id = ?", label.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(label).Error
+	})
+}
+
+// AddTaskLabel puts a label on a task. The label must be the user's own or
+// belong to the task's project.
+func AddTaskLabel(userID, taskID, labelID uint) error {
+	task, label, err := authorizeTaskLabel(userID, taskID, labelID)
+	if err != nil {
+		return err
+	}
+	return db.DB.Model(task).Omit("Labels.*").Association("Labels").Append(label)
+}
+
+func RemoveTaskLabel(userID, taskID, labelID uint) error {
+	task, label, err := authorizeTaskLabel(userID, taskID, labelID)
+	if err != nil {
+		return err
+	}
+	return db.DB.Model(task).Association("Labels").Delete(label)
+}
+
+// authorizeLabel loads a label the user may change: their own, or one of a
+// project they can edit.
+func authorizeLabel(userID, labelID uint) (*models.Label, error) {
+	var label models.Label
+	err := visibleLabels(userID).First(&label, labelID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, appErrors.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if label.ProjectID != nil {
+		if _, err := authorizeProject(userID, *label.ProjectID, models.RoleEditor); err != nil {
+			return nil, err
+		}
+	}
+	return &label, nil
+}
+
+func authorizeTaskLabel(userID, taskID, labelID uint) (*models.Task, *models.Label, error) {
+	task, err := authorizeTask(userID, taskID, models.RoleEditor)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var label models.Label
+	err = visibleLabels(userID).First(&label, labelID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, appErrors.ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if label.ProjectID != nil && *label.ProjectID != task.ProjectID {
+		return nil, nil, fmt.Errorf("%w: label belongs to another project", appErrors.ErrInvalidInput)
+	}
+	return task, &label, nil
+}
+
+// checkLabelName keeps label names unique, ignoring case, among a user's
+// labels or a project's labels.
+func checkLabelName(label *models.Label) error {
+	if label.Name == "" {
+		return fmt.Errorf("%w: name is required", appErrors.ErrInvalidInput)
+	}
+
+	query := db.DB.Model(&models.Label{}).Where("lower(name) = lower(?) AND id <> ?", label.Name, label.ID)
+	if label.ProjectID != nil {
+		query = query.Where("project_id = ?", *label.ProjectID)
+	} else {
+		query = query.Where("user_id = ?", *label.UserID)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return appErrors.ErrLabelExists
+	}
+	return nil
+}
+
+// fillLabels sets the labels of tasks, leaving out other users' personal
+// labels.
+func fillLabels(userID uint, byID map[uint]*models.Task, ids []uint) error {
+	var rows []struct {
+		TaskID uint
+		models.Label
+	}
+	err := db.DB.Table("task_labels").
+		Select("task_labels.task_id, labels.*").
+		Joins("JOIN labels ON labels.id = task_labels.label_id").
+		Where("task_labels.task_id IN ? AND (labels.user_id = ? OR labels.project_id IS NOT NULL)", ids, userID).
+		Order("labels.name, labels.id").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	for _, task := range byID {
+		task.Labels = []models.Label{}
+	}
+	for _, row := range rows {
+		byID[row.TaskID].Labels = append(byID[row.TaskID].Labels, row.Label)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"flowday/internal/db"
+	appErrors "flowday/internal/errors"
+	"flowday/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var roleRank = map[string]int{
+	models.RoleViewer:    1,
+	models.RoleCommenter: 2,
+	models.RoleEditor:    3,
+	models.RoleOwner:     4,
+}
+
+// TaskFilter narrows queries across the user's projects. With LabelIDs set
+// only tasks carrying every one of those labels are kept, with AssigneeID
+// only tasks assigned to that user.
+type TaskFilter struct {
+	IncludeArchived bool
+	LabelIDs        []uint
+	AssigneeID      uint
+}
+
+// memberProjects selects the ids of every project the user belongs to, for
+// use as a subquery. Projects in the trash are always left out, archived
+// ones unless the filter includes them.
+func memberProjects(userID uint, filter TaskFilter) *gorm.DB {
+	query := db.DB.Model(&models.ProjectMember{}).
+		Select("project_members.project_id").
+		Joins("JOIN projects ON projects.id = project_members.project_id").
+		Where("project_members.user_id = ? AND projects.deleted_at IS NULL", userID)
+
+	if !filter.IncludeArchived {
+		query = query.Where("projects.archived_at IS NULL")
+	}
+	return query
+}
+
+// authorizeProject checks that the user holds at least the given role on the
+// project. Non-members get ErrNotFound so project ids are not disclosed, and
+// so does everyone for projects in the trash.
+func authorizeProject(userID, projectID uint, role string) (*models.ProjectMember, error) {
+	return authorizeMember(db.DB.Where("projects.deleted_at IS NULL"), userID, projectID, role)
+}
+
+// authorizeTrashedProject is authorizeProject for projects in the trash.
+func authorizeTrashedProject(userID, projectID uint, role string) (*models.ProjectMember, error) {
+	return authorizeMember(db.DB.Where("projects.deleted_at IS NOT NULL"), userID, projectID, role)
+}
+
+func authorizeMember(query *gorm.DB, userID, projectID uint, role string) (*models.ProjectMember, error) {
+	var member models.ProjectMember
+	err := query.
+		Joins("JOIN projects ON projects.id = project_members.project_id").
+		Where("project_members.project_id = ? AND project_members.user_id = ?", projectID, userID).
+		First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, appErrors.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if roleRank[member.Role] < roleRank[role] {
+		return nil, appErrors.ErrForbidden
+	}
+	return &member, nil
+}
+
+func GetProjectMembers(userID, projectID uint) ([]models.ProjectMember, error) {
+	if _, err := authorizeProject(userID, projectID, models.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	var members []models.ProjectMember
+	err := db.DB.
+		Where("project_id = ?", projectID).
+		Preload("User", publicUserColumns).
+		Order("created_at").
+		Find(&members).Error
+	return members, err
+}
+
+// errInviteInstead answers every direct add that isn't allowed, the same
+// way whether or not an account uses the address.
+var errInviteInstead = fmt.Errorf("%w: only members of the project's team workspace can be added directly, invite anyone else", appErrors.ErrInvalidInput)
+
+// AddProjectMember gives a member of the project's team workspace a role on
+// the project. Everyone else, and everyone for projects in a personal
+// workspace, has to be invited, so owners can't pull strangers into a
+// project or probe which addresses have accounts. Only owners manage
+// membership.
+func AddProjectMember(userID, projectID uint, email, role string) (*models.ProjectMember, error) {
+	if _, err := authorizeProject(userID, projectID, models.RoleOwner); err != nil {
+		return nil, err
+	}
+
+	var project models.Project
+	if err := db.DB.First(&project, projectID).Error; err != nil {
+		return nil, appErrors.ErrNotFound
+	}
+
+	var user models.User
+	err := db.DB.
+		Joins("JOIN workspace_members ON workspace_members.user_id = users.id").
+		Joins("JOIN workspaces ON workspaces.id = workspace_members.workspace_id").
+		Where("workspaces.id = ? AND workspaces.personal = ?", project.WorkspaceID, false).
+		Where("lower(users.email) = ?", models.NormalizeEmail(email)).
+		First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errInviteInstead
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var existing int64
+	if err := db.DB.Model(&models.ProjectMember{}).
+		Where("project_id = ? AND user_id = ?", projectID, user.ID).
+		Count(&existing).Error; err != nil {
+		return nil, err
+	}
+	if existing > 0 {
+		return nil, appErrors.ErrAlreadyMember
+	}
+
+	member := models.ProjectMember{ProjectID: projectID, UserID: user.ID, Role: role}
+	if err := db.DB.Create(&member).Error; err != nil {
+		return nil, err
+	}
+
+	member.User = user.Public()
+	return &member, nil
+}
+
+func UpdateProjectMemberRole(userID, projectID, memberID uint, role string) (*models.ProjectMember, error) {
+	if _, err := authorizeProject(userID, projectID, models.RoleOwner); err != nil {
+		return nil, err
+	}
+
+	var member models.ProjectMember
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("project_id = ? AND user_id = ?", projectID, memberID).First(&member).Error; err != nil {
+			return appErrors.ErrNotFound
+		}
+		if member.Role == models.RoleOwner && role != models.RoleOwner {
+			if err := keepAnOwner(tx, projectID); err != nil {
+				return err
+			}
+		}
+
+		member.Role = role
+		return tx.Model(&member).Update("role", role).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// RemoveProjectMember takes a user off the project. Owners can remove
+// anyone; everyone else can only remove themselves.
+func RemoveProjectMember(userID, projectID, memberID uint) error {
+	role := models.RoleOwner
+	if userID == memberID {
+		role = models.RoleViewer
+	}
+	if _, err := authorizeProject(userID, projectID, role); err != nil {
+		return err
+	}
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		var member models.ProjectMember
+		if err := tx.Where("project_id = ? AND user_id = ?", projectID, memberID).First(&member).Error; err != nil {
+			return appErrors.ErrNotFound
+		}
+		if member.Role == models.RoleOwner {
+			if err := keepAnOwner(tx, projectID); err != nil {
+				return err
+			}
+		}
+
+		if err := unassign(tx, memberID, []uint{projectID}); err != nil {
+			return err
+		}
+		return tx.Delete(&member).Error
+	})
+}
+
+// keepAnOwner fails unless the project has an owner besides the one about
+// to be demoted or removed.
+func keepAnOwner(tx *gorm.DB, projectID uint) error {
+	var owners int64
+	if err := tx.Model(&models.ProjectMember{}).
+		Where("project_id = ? AND role = ?", projectID, models.RoleOwner).
+		Count(&owners).Error; err != nil {
+		return err
+	}
+	if owners < 2 {
+		return appErrors.ErrLastOwner
+	}
+	return nil
+}
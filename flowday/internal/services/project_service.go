@@ -1,31 +1,321 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
 	"flowday/internal/db"
+	"flowday/internal/dto"
+	appErrors "flowday/internal/errors"
 	"flowday/internal/models"
+
+	"gorm.io/gorm"
 )
 
-func CreateProject(userID uint, name string) (*models.Project, error) {
+// CreateProject creates a project owned by the user (see
+// models.Project.AfterCreate) in the given workspace, or in their personal
+// workspace when workspaceID is zero. The workspace's other members get its
+// default project role.
+func CreateProject(userID uint, req dto.CreateProjectRequest) (*models.Project, error) {
+	workspaceID := req.WorkspaceID
+	if workspaceID == 0 {
+		personal, err := models.PersonalWorkspace(db.DB, userID)
+		if err != nil {
+			return nil, err
+		}
+		workspaceID = personal.ID
+	}
+
+	if _, err := GetWorkspace(userID, workspaceID); err != nil {
+		return nil, err
+	}
+
 	project := models.Project{
-		Name:   name,
-		UserID: userID,
+		Name:        req.Name,
+		Description: req.Description,
+		Color:       req.Color,
+		Icon:        req.Icon,
+		WorkspaceID: workspaceID,
+		UserID:      userID,
 	}
 
-	if err := db.DB.Create(&project).Error; err != nil {
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		workspace, err := lockWorkspace(tx, workspaceID)
+		if err != nil {
+			return err
+		}
+
+		if workspace.MaxProjects > 0 {
+			var projects int64
+			if err := tx.Model(&models.Project{}).Where("workspace_id = ?", workspaceID).Count(&projects).Error; err != nil {
+				return err
+			}
+			if projects >= int64(workspace.MaxProjects) {
+				return appErrors.ErrQuotaExceeded
+			}
+		}
+
+		if err := tx.Create(&project).Error; err != nil {
+			return err
+		}
+		if workspace.DefaultProjectRole == "" {
+			return nil
+		}
+
+		var others []uint
+		if err := tx.Model(&models.WorkspaceMember{}).
+			Where("workspace_id = ? AND user_id <> ?", workspaceID, userID).
+			Pluck("user_id", &others).Error; err != nil {
+			return err
+		}
+		for _, memberID := range others {
+			member := models.ProjectMember{ProjectID: project.ID, UserID: memberID, Role: workspace.DefaultProjectRole}
+			if err := tx.Create(&member).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
+	project.Role = models.RoleOwner
 	return &project, nil
 }
 
-func GetProjects(userID uint) ([]models.Project, error) {
+// GetProjects lists every project the user is a member of, with their role,
+// optionally only those in one workspace. Archived projects are left out
+// unless the filter includes them.
+func GetProjects(userID, workspaceID uint, filter TaskFilter) ([]models.Project, error) {
+	query := db.DB.
+		Select("projects.*, project_members.role AS role").
+		Joins("JOIN project_members ON project_members.project_id = projects.id").
+		Where("project_members.user_id = ?", userID)
+
+	if workspaceID != 0 {
+		if _, err := authorizeWorkspace(userID, workspaceID, false); err != nil {
+			return nil, err
+		}
+		query = query.Where("projects.workspace_id = ?", workspaceID)
+	}
+	if !filter.IncludeArchived {
+		query = query.Where("projects.archived_at IS NULL")
+	}
+
 	var projects []models.Project
-	err := db.DB.Where("user_id = ?", userID).Find(&projects).Error
+	err := query.Find(&projects).Error
 	return projects, err
 }
 
+func GetProject(userID, projectID uint) (*models.Project, error) {
+	member, err := authorizeProject(userID, projectID, models.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	var project models.Project
+	if err := db.DB.First(&project, projectID).Error; err != nil {
+		return nil, appErrors.ErrNotFound
+	}
+	project.Role = member.Role
+	return &project, nil
+}
+
+// UpdateProject lets editors change a project's name and presentation.
+func UpdateProject(userID, projectID uint, req dto.UpdateProjectRequest) (*models.Project, error) {
+	if _, err := authorizeProject(userID, projectID, models.RoleEditor); err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		updates["name"] = *req.Name
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.Color.Set {
+		updates["color"] = req.Color.Value // empty when null
+	}
+	if req.Icon != nil {
+		updates["icon"] = *req.Icon
+	}
+
+	if len(updates) > 0 {
+		if err := db.DB.Model(&models.Project{}).Where("id = ?", projectID).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return GetProject(userID, projectID)
+}
+
+// ArchiveProject hides or restores a project; only owners can do either.
+func ArchiveProject(userID, projectID uint, archived bool) (*models.Project, error) {
+	if _, err := authorizeProject(userID, projectID, models.RoleOwner); err != nil {
+		return nil, err
+	}
+
+	var archivedAt *time.Time
+	if archived {
+		now := time.Now()
+		archivedAt = &now
+	}
+
+	if err := db.DB.Model(&models.Project{}).Where("id = ?", projectID).Update("archived_at", archivedAt).Error; err != nil {
+		return nil, err
+	}
+
+	return GetProject(userID, projectID)
+}
+
+// DeleteProject moves a project, and with it its tasks, to the trash. Only
+// owners can delete.
 func DeleteProject(userID, projectID uint) error {
-	return db.DB.
-		Where("id = ? AND user_id = ?", projectID, userID).
-		Delete(&models.Project{}).Error
+	if _, err := authorizeProject(userID, projectID, models.RoleOwner); err != nil {
+		return err
+	}
+
+	return db.DB.Delete(&models.Project{}, projectID).Error
+}
+
+// GetTrash lists the deleted projects the user owns and can still restore.
+func GetTrash(userID uint) ([]models.Project, error) {
+	var projects []models.Project
+	err := db.DB.Unscoped().
+		Select("projects.*, project_members.role AS role").
+		Joins("JOIN project_members ON project_members.project_id = projects.id").
+		Where("project_members.user_id = ? AND project_members.role = ?", userID, models.RoleOwner).
+		Where("projects.deleted_at IS NOT NULL").
+		Order("projects.deleted_at DESC").
+		Find(&projects).Error
+	return projects, err
+}
+
+// RestoreProject takes a project out of the trash, within its workspace's
+// project quota.
+func RestoreProject(userID, projectID uint) (*models.Project, error) {
+	if _, err := authorizeTrashedProject(userID, projectID, models.RoleOwner); err != nil {
+		return nil, err
+	}
+
+	var project models.Project
+	if err := db.DB.Unscoped().First(&project, projectID).Error; err != nil {
+		return nil, appErrors.ErrNotFound
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		workspace, err := lockWorkspace(tx, project.WorkspaceID)
+		if err != nil && !errors.Is(err, appErrors.ErrNotFound) {
+			return err
+		}
+		if workspace != nil && workspace.MaxProjects > 0 {
+			var projects int64
+			if err := tx.Model(&models.Project{}).Where("workspace_id = ?", workspace.ID).Count(&projects).Error; err != nil {
+				return err
+			}
+			if projects >= int64(workspace.MaxProjects) {
+				return appErrors.ErrQuotaExceeded
+			}
+		}
+
+		return tx.Unscoped().Model(&project).Update("deleted_at", nil).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return GetProject(userID, projectID)
+}
+
+// PurgeTrash permanently deletes projects and tasks that were trashed
+// before the cutoff and returns how many trashed items went; tasks that
+// leave with their project are not counted separately.
+func PurgeTrash(before time.Time) (int, error) {
+	var projectIDs []uint
+	err := db.DB.Unscoped().Model(&models.Project{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+		Pluck("id", &projectIDs).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var tasks int64
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := deleteProjects(tx, projectIDs); err != nil {
+			return err
+		}
+
+		expired := tx.Unscoped().Model(&models.Task{}).
+			Select("id").
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", before)
+		if err := deleteTaskDetails(tx, expired); err != nil {
+			return err
+		}
+
+		result := tx.Unscoped().
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+			Delete(&models.Task{})
+		tasks = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(projectIDs) + int(tasks), nil
+}
+
+// StartTrashPurge purges expired trash every hour until ctx is done.
+func StartTrashPurge(ctx context.Context) {
+	if settings.TrashRetention <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				purged, err := PurgeTrash(time.Now().Add(-settings.TrashRetention))
+				if err != nil {
+					log.Println("trash purge failed:", err)
+				} else if purged > 0 {
+					log.Printf("purged %d items from the trash", purged)
+				}
+			}
+		}
+	}()
+}
+
+// deleteProjects hard-deletes projects with everything that belongs to
+// them. The foreign keys cascade to direct children, but join tables and
+// comment details have no cascade, so everything is removed explicitly.
+func deleteProjects(tx *gorm.DB, projectIDs []uint) error {
+	if len(projectIDs) == 0 {
+		return nil
+	}
+	tasks := tx.Unscoped().Model(&models.Task{}).Select("id").Where("project_id IN ?", projectIDs)
+	if err := deleteTaskDetails(tx, tasks); err != nil {
+		return err
+	}
+	for _, model := range []interface{}{
+		&models.Task{},
+		&models.ProjectMember{},
+		&models.ProjectInvitation{},
+		&models.WorkflowState{},
+		&models.WorkflowTransition{},
+		&models.Label{},
+	} {
+		if err := tx.Unscoped().Where("project_id IN ?", projectIDs).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Unscoped().Where("id IN ?", projectIDs).Delete(&models.Project{}).Error
 }
@@ -7,19 +7,23 @@ import (
 	"flowday/internal/models"
 )
 
-func GetTaskByRange(userId uint, from, to time.Time) ([]models.Task, error) {
-	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
-	end := time.Date(to.Year(), to.Month(), to.Day(), 23, 59, 59, 0, to.Location())
+// GetTaskByRange returns the tasks due from the start of from's day up to
+// the end of to's day, both taken in their own location.
+func GetTaskByRange(userId uint, from, to time.Time, filter TaskFilter) ([]models.Task, error) {
+	start, _ := dayBounds(from)
+	_, end := dayBounds(to)
 
 	var tasks []models.Task
-	err := db.DB.
-		Joins("JOIN projects ON projects.id = tasks.project_id").
+	err := filterTasks(db.DB, userId, filter).
 		Where(
-			"projects.user_id = ? AND tasks.due_date IS NOT NULL AND tasks.due_date >= ? AND tasks.due_date <= ?",
-			userId, start, end,
+			"tasks.project_id IN (?) AND tasks.due_date IS NOT NULL AND tasks.due_date >= ? AND tasks.due_date < ?",
+			memberProjects(userId, filter), start, end,
 		).
 		Preload("Project").
 		Find(&tasks).Error
+	if err != nil {
+		return nil, err
+	}
 
-	return tasks, err
+	return tasks, decorateTasks(userId, tasks)
 }
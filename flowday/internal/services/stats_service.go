@@ -4,51 +4,91 @@ import (
 	"time"
 
 	"flowday/internal/db"
+	"flowday/internal/models"
 )
 
 type TaskStats struct {
-	Total   int64 `json:"total"`
-	Done    int64 `json:"done"`
-	Overdue int64 `json:"overdue"`
-	Today   int64 `json:"today"`
+	Total      int64           `json:"total"`
+	Done       int64           `json:"done"`
+	Overdue    int64           `json:"overdue"`
+	Today      int64           `json:"today"`
+	Unassigned int64           `json:"unassigned"`
+	ByAssignee []AssigneeStats `json:"by_assignee"`
 }
 
-func GetTaskStats(userID uint) (*TaskStats, error) {
-	now := time.Now()
-	startToday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-	endToday := startToday.Add(24 * time.Hour)
+// AssigneeStats are the counts of TaskStats for the tasks assigned to one
+// user. A task with several assignees counts for each of them.
+type AssigneeStats struct {
+	UserID      uint   `json:"user_id"`
+	Email       string `json:"email"`
+	DisplayName string `json:"display_name"`
+	Total       int64  `json:"total"`
+	Done        int64  `json:"done"`
+	Overdue     int64  `json:"overdue"`
+	Today       int64  `json:"today"`
+}
+
+// GetTaskStats counts the user's tasks, leaving out the trash; "done" means
+// a state in the done category, and "today" is the calendar day of now in
+// now's location.
+func GetTaskStats(userID uint, now time.Time, filter TaskFilter) (*TaskStats, error) {
+	startToday, endToday := dayBounds(now)
+	now = now.UTC()
 
-	stats := TaskStats{}
+	stats := TaskStats{ByAssignee: []AssigneeStats{}}
 
-	db.DB.
-		Table("tasks").
-		Joins("JOIN projects ON projects.id = tasks.project_id").
-		Where("projects.user_id = ?", userID).
+	filterTasks(db.DB, userID, filter).
+		Model(&models.Task{}).
+		Where("tasks.project_id IN (?)", memberProjects(userID, filter)).
 		Count(&stats.Total)
 
-	db.DB.
-		Table("tasks").
-		Joins("JOIN projects ON projects.id = tasks.project_id").
-		Where("projects.user_id = ? AND tasks.status = ?", userID, "done").
+	filterTasks(db.DB, userID, filter).
+		Model(&models.Task{}).
+		Where("tasks.project_id IN (?) AND tasks.status_category = ?", memberProjects(userID, filter), models.CategoryDone).
 		Count(&stats.Done)
 
-	db.DB.
-		Table("tasks").
-		Joins("JOIN projects ON projects.id = tasks.project_id").
+	filterTasks(db.DB, userID, filter).
+		Model(&models.Task{}).
 		Where(
-			"projects.user_id = ? AND tasks.due_date IS NOT NULL AND tasks.due_date < ? AND tasks.status != ?",
-			userID, now, "done",
+			"tasks.project_id IN (?) AND tasks.due_date IS NOT NULL AND tasks.due_date < ? AND tasks.status_category != ?",
+			memberProjects(userID, filter), now, models.CategoryDone,
 		).
 		Count(&stats.Overdue)
 
-	db.DB.
-		Table("tasks").
-		Joins("JOIN projects ON projects.id = tasks.project_id").
+	filterTasks(db.DB, userID, filter).
+		Model(&models.Task{}).
 		Where(
-			"projects.user_id = ? AND tasks.due_date >= ? AND tasks.due_date < ?",
-			userID, startToday, endToday,
+			"tasks.project_id IN (?) AND tasks.due_date >= ? AND tasks.due_date < ?",
+			memberProjects(userID, filter), startToday, endToday,
 		).
 		Count(&stats.Today)
 
+	filterTasks(db.DB, userID, filter).
+		Model(&models.Task{}).
+		Where(
+			"tasks.project_id IN (?) AND tasks.id NOT IN (?)",
+			memberProjects(userID, filter), db.DB.Table("task_assignees").Select("task_id"),
+		).
+		Count(&stats.Unassigned)
+
+	err := filterTasks(db.DB, userID, filter).
+		Model(&models.Task{}).
+		Select(`task_assignees.user_id, users.email, users.display_name,
+			COUNT(*) AS total,
+			SUM(CASE WHEN tasks.status_category = ? THEN 1 ELSE 0 END) AS done,
+			SUM(CASE WHEN tasks.due_date IS NOT NULL AND tasks.due_date < ? AND tasks.status_category != ? THEN 1 ELSE 0 END) AS overdue,
+			SUM(CASE WHEN tasks.due_date >= ? AND tasks.due_date < ? THEN 1 ELSE 0 END) AS today`,
+			models.CategoryDone, now, models.CategoryDone, startToday, endToday,
+		).
+		Joins("JOIN task_assignees ON task_assignees.task_id = tasks.id").
+		Joins("JOIN users ON users.id = task_assignees.user_id").
+		Where("tasks.project_id IN (?)", memberProjects(userID, filter)).
+		Group("task_assignees.user_id, users.email, users.display_name").
+		Order("task_assignees.user_id").
+		Scan(&stats.ByAssignee).Error
+	if err != nil {
+		return nil, err
+	}
+
 	return &stats, nil
 }
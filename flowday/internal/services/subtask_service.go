@@ -0,0 +1,265 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"flowday/internal/db"
+	"flowday/internal/dto"
+	appErrors "flowday/internal/errors"
+	"flowday/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// GetSubtasks returns a task's direct subtasks in order.
+func GetSubtasks(userID, taskID uint) ([]models.Task, error) {
+	task, err := authorizeTask(userID, taskID, models.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	var subtasks []models.Task
+	err = db.DB.Where("parent_id = ?", task.ID).Order("position, id").Find(&subtasks).Error
+	if err != nil {
+		return nil, err
+	}
+	return subtasks, decorateTasks(userID, subtasks)
+}
+
+// MoveTask changes a task's parent and its place among its siblings. The
+// task keeps its subtasks, so the whole branch has to fit under the depth
+// limit at its new place.
+func MoveTask(userID, taskID uint, req dto.MoveTaskRequest) (*models.Task, error) {
+	task, err := authorizeTask(userID, taskID, models.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		parentID := task.ParentID
+		if req.ParentID.Set {
+			parentID = nil
+			if !req.ParentID.Null {
+				parentID = &req.ParentID.Value
+			}
+		}
+
+		if parentID != nil {
+			height, err := subtreeHeight(tx, task.ID)
+			if err != nil {
+				return err
+			}
+			if err := checkParent(tx, task.ProjectID, *parentID, task.ID, height); err != nil {
+				return err
+			}
+		}
+
+		task.ParentID = parentID
+		if err := tx.Model(task).Update("parent_id", parentID).Error; err != nil {
+			return err
+		}
+		return placeTask(tx, task, req.Position)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return task, decorateTask(userID, task)
+}
+
+// checkParent makes sure a task, or a branch of the given height rooted at
+// movingID, can go under parentID.
+func checkParent(tx *gorm.DB, projectID, parentID, movingID uint, height int) error {
+	var parent models.Task
+	err := tx.First(&parent, parentID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: parent task not found", appErrors.ErrInvalidParent)
+	}
+	if err != nil {
+		return err
+	}
+	if parent.ProjectID != projectID {
+		return fmt.Errorf("%w: parent task is in another project", appErrors.ErrInvalidParent)
+	}
+
+	depth := 0
+	for current := &parent; ; depth++ {
+		if movingID != 0 && current.ID == movingID {
+			return fmt.Errorf("%w: a task can't be moved under itself", appErrors.ErrInvalidParent)
+		}
+		if current.ParentID == nil {
+			break
+		}
+		var next models.Task
+		if err := tx.Unscoped().First(&next, *current.ParentID).Error; err != nil {
+			return err
+		}
+		current = &next
+	}
+
+	if depth+1+height > settings.MaxTaskDepth {
+		return fmt.Errorf("%w: subtasks can only nest %d levels deep", appErrors.ErrInvalidParent, settings.MaxTaskDepth)
+	}
+	return nil
+}
+
+// subtreeHeight counts the levels of a task's branch, the task included.
+func subtreeHeight(tx *gorm.DB, taskID uint) (int, error) {
+	height := 0
+	for level := []uint{taskID}; len(level) > 0; height++ {
+		var next []uint
+		if err := tx.Model(&models.Task{}).Where("parent_id IN ?", level).Pluck("id", &next).Error; err != nil {
+			return 0, err
+		}
+		level = next
+	}
+	return height, nil
+}
+
+// descendantIDs returns the ids of every task below the given ones, using
+// query to decide which tasks count.
+func descendantIDs(query *gorm.DB, taskIDs []uint) ([]uint, error) {
+	var ids []uint
+	for level := taskIDs; len(level) > 0; {
+		var next []uint
+		if err := query.Session(&gorm.Session{}).Model(&models.Task{}).Where("parent_id IN ?", level).Pluck("id", &next).Error; err != nil {
+			return nil, err
+		}
+		ids = append(ids, next...)
+		level = next
+	}
+	return ids, nil
+}
+
+// placeTask puts a task at position among its siblings, or after them when
+// position is nil, and renumbers the siblings.
+func placeTask(tx *gorm.DB, task *models.Task, position *int) error {
+	siblings := tx.Model(&models.Task{}).Where("project_id = ? AND id <> ?", task.ProjectID, task.ID)
+	if task.ParentID == nil {
+		siblings = siblings.Where("parent_id IS NULL")
+	} else {
+		siblings = siblings.Where("parent_id = ?", *task.ParentID)
+	}
+
+	var ids []uint
+	if err := siblings.Order("position, id").Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+
+	at := len(ids)
+	if position != nil && *position < at {
+		at = *position
+	}
+	ids = append(ids[:at], append([]uint{task.ID}, ids[at:]...)...)
+
+	for i, id := range ids {
+		if err := tx.Model(&models.Task{}).Where("id = ?", id).Update("position", i).Error; err != nil {
+			return err
+		}
+	}
+	task.Position = at
+	return nil
+}
+
+// nextPosition is the position after a parent's last subtask, or after the
+// last top-level task of the project when parentID is nil.
+func nextPosition(tx *gorm.DB, projectID uint, parentID *uint) (int, error) {
+	query := tx.Model(&models.Task{}).Where("project_id = ?", projectID)
+	if parentID == nil {
+		query = query.Where("parent_id IS NULL")
+	} else {
+		query = query.Where("parent_id = ?", *parentID)
+	}
+
+	var last *int
+	if err := query.Select("MAX(position)").Scan(&last).Error; err != nil {
+		return 0, err
+	}
+	if last == nil {
+		return 0, nil
+	}
+	return *last + 1, nil
+}
+
+// completeSubtasks moves a done task's open subtasks, at any depth, into
+// the same state. The workflow applies to them as to any task, so if one
+// of them may not move there the whole update is refused.
+func completeSubtasks(tx *gorm.DB, task *models.Task, updates map[string]interface{}) error {
+	ids, err := descendantIDs(tx, []uint{task.ID})
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+
+	var open []models.Task
+	if err := tx.Select("id", "title", "status").
+		Where("id IN ? AND status_category <> ?", ids, models.CategoryDone).
+		Find(&open).Error; err != nil {
+		return err
+	}
+	if len(open) == 0 {
+		return nil
+	}
+	workflow, err := loadWorkflow(tx, task.ProjectID)
+	if err != nil {
+		return err
+	}
+	status, _ := updates["status"].(string)
+	for _, subtask := range open {
+		if !workflow.allows(subtask.Status, status) {
+			return fmt.Errorf("%w: subtask %q cannot move from %q to %q",
+				appErrors.ErrInvalidTransition, subtask.Title, subtask.Status, status)
+		}
+	}
+
+	return tx.Model(&models.Task{}).
+		Where("id IN ? AND status_category <> ?", ids, models.CategoryDone).
+		Updates(map[string]interface{}{
+			"status":          updates["status"],
+			"status_category": models.CategoryDone,
+			"completed_at":    updates["completed_at"],
+		}).Error
+}
+
+// buildTree nests tasks under their parents in position order and returns
+// the roots.
+func buildTree(roots []models.Task, descendants []models.Task) []models.Task {
+	children := map[uint][]models.Task{}
+	for _, task := range descendants {
+		children[*task.ParentID] = append(children[*task.ParentID], task)
+	}
+
+	var attach func(tasks []models.Task)
+	attach = func(tasks []models.Task) {
+		for i := range tasks {
+			kids := children[tasks[i].ID]
+			sort.SliceStable(kids, func(a, b int) bool { return kids[a].Position < kids[b].Position })
+			attach(kids)
+			tasks[i].Subtasks = kids
+		}
+	}
+	attach(roots)
+	return roots
+}
+
+// fillProgress sets the subtask progress of the tasks that have subtasks.
+func fillProgress(byID map[uint]*models.Task, ids []uint) error {
+	var rows []struct {
+		ParentID uint
+		Done     int64
+		Total    int64
+	}
+	err := db.DB.Model(&models.Task{}).
+		Select("parent_id, COUNT(*) AS total, SUM(CASE WHEN status_category = ? THEN 1 ELSE 0 END) AS done", models.CategoryDone).
+		Where("parent_id IN ?", ids).
+		Group("parent_id").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		byID[row.ParentID].Progress = &models.TaskProgress{Done: row.Done, Total: row.Total}
+	}
+	return nil
+}
@@ -2,22 +2,58 @@ package services
 
 import (
 	"errors"
+	"fmt"
+	"time"
 
 	"flowday/internal/db"
+	appErrors "flowday/internal/errors"
 	"flowday/internal/models"
+
+	"gorm.io/gorm"
 )
 
+// CreateTask puts the task in its project's default state unless it asks
+// for another one. Subtasks go after their siblings.
 func CreateTask(userID uint, task *models.Task) error {
-	var project models.Project
-	if err := db.DB.
-		Where("id = ? AND user_id = ?", task.ProjectID, userID).
-		First(&project).Error; err != nil {
-		return errors.New("project not found")
+	if _, err := authorizeProject(userID, task.ProjectID, models.RoleEditor); err != nil {
+		return err
+	}
+	if err := checkSchedule(task.StartDate, task.DueDate, task.AllDay); err != nil {
+		return err
+	}
+	if task.ParentID != nil {
+		if err := checkParent(db.DB, task.ProjectID, *task.ParentID, 0, 1); err != nil {
+			return err
+		}
+	}
+	position, err := nextPosition(db.DB, task.ProjectID, task.ParentID)
+	if err != nil {
+		return err
+	}
+	task.Position = position
+
+	state, err := resolveStatus(task.ProjectID, "", task.Status)
+	if err != nil {
+		return err
+	}
+	task.Status = state.Key
+	task.StatusCategory = state.Category
+	if state.Category == models.CategoryDone && task.CompletedAt == nil {
+		now := time.Now().UTC()
+		task.CompletedAt = &now
 	}
 
-	return db.DB.Create(task).Error
+	if err := db.DB.Create(task).Error; err != nil {
+		return err
+	}
+	return decorateTask(userID, task)
 }
 
+// GetTasksByProjectPaginated lists a project's tasks, or the tasks of all
+// the user's projects when projectID is 0. The view is "flat" for every
+// task, "top" for top-level tasks only, or "tree" for top-level tasks with
+// their subtasks nested inside; pagination and filters apply to the tasks
+// at the top.
 func GetTasksByProjectPaginated(
 	userID uint,
 	projectID uint,
@@ -25,6 +61,8 @@ func GetTasksByProjectPaginated(
 	offset int,
 	order string,
 	dir string,
+	view string,
+	filter TaskFilter,
 ) ([]models.Task, error) {
 
 	// 1) defaults
@@ -41,6 +79,7 @@ func GetTasksByProjectPaginated(
 		"due_date":   true,
 		"priority":   true,
 		"status":     true,
+		"position":   true,
 	}
 	if !allowedOrder[order] {
 		order = "created_at"
@@ -57,42 +96,371 @@ func GetTasksByProjectPaginated(
 	}
 
 	// 4) query
+	query := filterTasks(db.DB, userID, filter)
+	if projectID == 0 {
+		query = query.Where("tasks.project_id IN (?)", memberProjects(userID, filter))
+	} else {
+		if _, err := authorizeProject(userID, projectID, models.RoleViewer); err != nil {
+			return nil, err
+		}
+		query = query.Where("tasks.project_id = ?", projectID)
+	}
+	if view == "top" || view == "tree" {
+		query = query.Where("tasks.parent_id IS NULL")
+	}
+
 	var tasks []models.Task
-	err := db.DB.
-		Joins("JOIN projects ON projects.id = tasks.project_id").
-		Where("projects.user_id = ? AND tasks.project_id = ?", userID, projectID).
+	err := query.
 		Preload("Project").
 		Order(order + " " + dir).
 		Limit(limit).
 		Offset(offset).
 		Find(&tasks).Error
+	if err != nil {
+		return nil, err
+	}
 
-	return tasks, err
+	if view == "tree" && len(tasks) > 0 {
+		rootIDs := make([]uint, len(tasks))
+		for i, task := range tasks {
+			rootIDs[i] = task.ID
+		}
+		ids, err := descendantIDs(db.DB, rootIDs)
+		if err != nil {
+			return nil, err
+		}
+
+		var descendants []models.Task
+		if len(ids) > 0 {
+			if err := db.DB.Where("id IN ?", ids).Order("position, id").Find(&descendants).Error; err != nil {
+				return nil, err
+			}
+		}
+		tasks = buildTree(tasks, descendants)
+	}
+
+	return tasks, decorateTasks(userID, tasks)
 }
 
 func GetTasksByProject(userID, projectID uint) ([]models.Task, error) {
-	var tasks []models.Task
+	if _, err := authorizeProject(userID, projectID, models.RoleViewer); err != nil {
+		return nil, err
+	}
 
+	var tasks []models.Task
 	err := db.DB.
-		Joins("JOIN projects ON projects.id = tasks.project_id").
-		Where("projects.user_id = ? AND tasks.project_id = ?", userID, projectID).
+		Where("tasks.project_id = ?", projectID).
 		Preload("Project").
 		Find(&tasks).Error
 
 	return tasks, err
 }
 
+// authorizeTask loads a task and checks the user's role on its project.
+func authorizeTask(userID, taskID uint, role string) (*models.Task, error) {
+	return authorizeTaskIn(db.DB, userID, taskID, role)
+}
+
+// authorizeTrashedTask is authorizeTask for tasks in the trash.
+func authorizeTrashedTask(userID, taskID uint, role string) (*models.Task, error) {
+	return authorizeTaskIn(db.DB.Unscoped().Where("deleted_at IS NOT NULL"), userID, taskID, role)
+}
+
+func authorizeTaskIn(query *gorm.DB, userID, taskID uint, role string) (*models.Task, error) {
+	var task models.Task
+	err := query.First(&task, taskID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, appErrors.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := authorizeProject(userID, task.ProjectID, role); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// GetTask returns a single task with its project and direct subtasks for
+// anyone who can see the project.
+func GetTask(userID, taskID uint) (*models.Task, error) {
+	task, err := authorizeTask(userID, taskID, models.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.DB.Preload("Project").First(task, task.ID).Error; err != nil {
+		return nil, err
+	}
+	if err := db.DB.Where("parent_id = ?", task.ID).Order("position, id").Find(&task.Subtasks).Error; err != nil {
+		return nil, err
+	}
+
+	return task, decorateTask(userID, task)
+}
+
 func UpdateTask(userID, taskID uint, updates map[string]interface{}) error {
-	return db.DB.
-		Joins("JOIN projects ON projects.id = tasks.project_id").
-		Where("tasks.id = ? AND projects.user_id = ?", taskID, userID).
-		Model(&models.Task{}).
-		Updates(updates).Error
+	task, err := authorizeTask(userID, taskID, models.RoleEditor)
+	if err != nil {
+		return err
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	if err := checkScheduleUpdate(task, updates); err != nil {
+		return err
+	}
+	if status, ok := updates["status"].(string); ok {
+		state, err := resolveStatus(task.ProjectID, task.Status, status)
+		if err != nil {
+			return err
+		}
+		updates["status_category"] = state.Category
+		stampCompletion(task, updates)
+	}
+
+	wasDone := task.StatusCategory == models.CategoryDone
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		// The task may have been deleted since it was loaded.
+		result := tx.Model(task).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return appErrors.ErrNotFound
+		}
+
+		// Finishing a task finishes its steps too.
+		if updates["status_category"] == models.CategoryDone && !wasDone {
+			return completeSubtasks(tx, task, updates)
+		}
+		return nil
+	})
 }
 
+// checkSchedule refuses a task that starts after it is due. An all-day
+// task is due until the end of its day.
+func checkSchedule(start, due *time.Time, allDay bool) error {
+	if start == nil || due == nil {
+		return nil
+	}
+	end := *due
+	if allDay {
+		end = end.AddDate(0, 0, 1)
+	}
+	if start.After(end) {
+		return fmt.Errorf("%w: start_date is after due_date", appErrors.ErrInvalidInput)
+	}
+	return nil
+}
+
+// checkScheduleUpdate checks the dates a task will have after the update,
+// taking whichever of them the update leaves alone from the stored task.
+func checkScheduleUpdate(task *models.Task, updates map[string]interface{}) error {
+	start, due, allDay := task.StartDate, task.DueDate, task.AllDay
+	if value, ok := updates["start_date"]; ok {
+		start = nil
+		if date, ok := value.(time.Time); ok {
+			start = &date
+		}
+	}
+	if value, ok := updates["due_date"]; ok {
+		due = nil
+		if date, ok := value.(time.Time); ok {
+			due = &date
+		}
+	}
+	if value, ok := updates["all_day"].(bool); ok {
+		allDay = value
+	}
+	return checkSchedule(start, due, allDay)
+}
+
+// stampCompletion sets completed_at when a task enters a done state and
+// clears it when it is reopened, unless the update sets completed_at itself.
+func stampCompletion(task *models.Task, updates map[string]interface{}) {
+	if _, explicit := updates["completed_at"]; explicit {
+		return
+	}
+
+	done := updates["status_category"] == models.CategoryDone
+	switch {
+	case done && task.CompletedAt == nil:
+		updates["completed_at"] = time.Now().UTC()
+	case !done:
+		updates["completed_at"] = nil
+	}
+}
+
+// DeleteTask moves a task and its subtasks to the trash together.
 func DeleteTask(userID, taskID uint) error {
-	return db.DB.
-		Joins("JOIN projects ON projects.id = tasks.project_id").
-		Where("tasks.id = ? AND projects.user_id = ?", taskID, userID).
-		Delete(&models.Task{}).Error
+	task, err := authorizeTask(userID, taskID, models.RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	ids, err := descendantIDs(db.DB, []uint{task.ID})
+	if err != nil {
+		return err
+	}
+	return db.DB.Where("id IN ?", append(ids, task.ID)).Delete(&models.Task{}).Error
+}
+
+// GetTrashedTasks lists deleted tasks across the user's projects, most
+// recently deleted first.
+func GetTrashedTasks(userID uint) ([]models.Task, error) {
+	var tasks []models.Task
+	err := db.DB.Unscoped().
+		Where("tasks.deleted_at IS NOT NULL AND tasks.project_id IN (?)",
+			memberProjects(userID, TaskFilter{IncludeArchived: true})).
+		Preload("Project").
+		Order("tasks.deleted_at DESC").
+		Find(&tasks).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return tasks, decorateTasks(userID, tasks)
+}
+
+// RestoreTask brings a task back from the trash with the subtasks that
+// were deleted along with it. If its parent is still in the trash the task
+// comes back as a top-level task.
+func RestoreTask(userID, taskID uint) (*models.Task, error) {
+	task, err := authorizeTrashedTask(userID, taskID, models.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		trashed := tx.Unscoped().Where("deleted_at = ?", task.DeletedAt.Time)
+		ids, err := descendantIDs(trashed, []uint{task.ID})
+		if err != nil {
+			return err
+		}
+		err = tx.Unscoped().Model(&models.Task{}).
+			Where("id IN ?", append(ids, task.ID)).
+			Update("deleted_at", nil).Error
+		if err != nil {
+			return err
+		}
+
+		if task.ParentID != nil {
+			var count int64
+			if err := tx.Model(&models.Task{}).Where("id = ?", *task.ParentID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				task.ParentID = nil
+				if err := tx.Unscoped().Model(task).Update("parent_id", nil).Error; err != nil {
+					return err
+				}
+				return placeTask(tx, task, nil)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	task.DeletedAt = gorm.DeletedAt{}
+	return task, decorateTask(userID, task)
+}
+
+// PurgeTask permanently deletes a task that is already in the trash, along
+// with its subtasks.
+func PurgeTask(userID, taskID uint) error {
+	task, err := authorizeTrashedTask(userID, taskID, models.RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	ids, err := descendantIDs(db.DB.Unscoped(), []uint{task.ID})
+	if err != nil {
+		return err
+	}
+	ids = append(ids, task.ID)
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := deleteTaskDetails(tx, ids); err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("id IN ?", ids).Delete(&models.Task{}).Error
+	})
+}
+
+// filterTasks applies the label and assignee parts of a filter to a task
+// query. Labels the user can't see match nothing.
+func filterTasks(query *gorm.DB, userID uint, filter TaskFilter) *gorm.DB {
+	for _, labelID := range filter.LabelIDs {
+		query = query.Where("tasks.id IN (?)", db.DB.Table("task_labels").
+			Select("task_labels.task_id").
+			Where("task_labels.label_id IN (?)", visibleLabels(userID).Select("labels.id").Where("labels.id = ?", labelID)))
+	}
+	if filter.AssigneeID != 0 {
+		query = query.Where("tasks.id IN (?)", db.DB.Table("task_assignees").
+			Select("task_assignees.task_id").
+			Where("task_assignees.user_id = ?", filter.AssigneeID))
+	}
+	return query
+}
+
+// deleteTaskDetails removes what hangs off tasks that are about to be
+// deleted for good; tasks is a list of ids or a subquery selecting them.
+func deleteTaskDetails(tx *gorm.DB, tasks interface{}) error {
+	if err := tx.Where("task_id IN (?)", tasks).Delete(&models.ChecklistItem{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Exec("DELETE FROM task_labels WHERE task_id IN (?)", tasks).Error; err != nil {
+		return err
+	}
+	if err := deleteComments(tx, tx.Model(&models.Comment{}).Select("id").Where("task_id IN (?)", tasks)); err != nil {
+		return err
+	}
+	return tx.Exec("DELETE FROM task_assignees WHERE task_id IN (?)", tasks).Error
+}
+
+// decorateTasks fills in the fields of tasks, and of their nested subtasks,
+// that are computed or loaded separately, as seen by the given user.
+func decorateTasks(userID uint, tasks []models.Task) error {
+	byID := map[uint]*models.Task{}
+	var collect func(tasks []models.Task)
+	collect = func(tasks []models.Task) {
+		for i := range tasks {
+			byID[tasks[i].ID] = &tasks[i]
+			collect(tasks[i].Subtasks)
+		}
+	}
+	collect(tasks)
+	if len(byID) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(byID))
+	for id := range byID {
+		ids = append(ids, id)
+	}
+
+	if err := fillProgress(byID, ids); err != nil {
+		return err
+	}
+	if err := fillChecklists(byID, ids); err != nil {
+		return err
+	}
+	if err := fillLabels(userID, byID, ids); err != nil {
+		return err
+	}
+	return fillAssignees(byID, ids)
+}
+
+// decorateTask is decorateTasks for a single task.
+func decorateTask(userID uint, task *models.Task) error {
+	tasks := []models.Task{*task}
+	if err := decorateTasks(userID, tasks); err != nil {
+		return err
+	}
+	*task = tasks[0]
+	return nil
 }
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"time"
+
+	"flowday/internal/db"
+	"flowday/internal/models"
+)
+
+// UserLocation returns the user's stored time zone, or UTC when it is
+// missing or no longer known to the tz database.
+func UserLocation(userID uint) *time.Location {
+	var user models.User
+	if err := db.DB.Select("time_zone").First(&user, userID).Error; err != nil {
+		return time.UTC
+	}
+
+	loc, err := time.LoadLocation(user.TimeZone)
+	if err != nil || user.TimeZone == "" {
+		return time.UTC
+	}
+	return loc
+}
+
+// dayBounds returns the start of the calendar day containing t and the start
+// of the next one, in t's location. Days are not always 24 hours long, so the
+// end comes from the calendar rather than a fixed duration. Both bounds are
+// returned in UTC, which is how due dates are stored.
+func dayBounds(t time.Time) (start, end time.Time) {
+	start = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	end = start.AddDate(0, 0, 1)
+	return start.UTC(), end.UTC()
+}
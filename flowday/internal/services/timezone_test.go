@@ -0,0 +1,37 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDayBoundsAcrossDST(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("tz database not available")
+	}
+
+	cases := []struct {
+		name  string
+		day   time.Time
+		hours float64
+	}{
+		{"Spring Forward", time.Date(2026, time.March, 8, 12, 0, 0, 0, newYork), 23},
+		{"Fall Back", time.Date(2026, time.November, 1, 12, 0, 0, 0, newYork), 25},
+		{"Ordinary Day", time.Date(2026, time.June, 1, 12, 0, 0, 0, newYork), 24},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			start, end := dayBounds(tc.day)
+			assert.Equal(t, tc.hours, end.Sub(start).Hours())
+			assert.Equal(t, time.UTC, start.Location())
+
+			local := end.In(newYork)
+			assert.Equal(t, tc.day.Day()+1, local.Day())
+			assert.Zero(t, local.Hour())
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"flowday/internal/auth"
+	"flowday/internal/db"
+	"flowday/internal/dto"
+	appErrors "flowday/internal/errors"
+	"flowday/internal/models"
+
+	"golang.org/x/text/language"
+	"gorm.io/gorm"
+)
+
+// publicUserColumns loads only the columns of models.PublicUser, for use
+// when preloading users that others will see.
+func publicUserColumns(tx *gorm.DB) *gorm.DB {
+	return tx.Select("id", "email", "display_name")
+}
+
+func GetProfile(userID uint) (*models.User, error) {
+	var user models.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		return nil, appErrors.ErrNotFound
+	}
+	return &user, nil
+}
+
+func UpdateProfile(userID uint, req dto.UpdateProfileRequest) (*models.User, error) {
+	updates := map[string]interface{}{}
+
+	if req.DisplayName != nil {
+		updates["display_name"] = *req.DisplayName
+	}
+	if req.TimeZone != nil {
+		loc, err := time.LoadLocation(*req.TimeZone)
+		if err != nil || *req.TimeZone == "" || *req.TimeZone == "Local" {
+			return nil, fmt.Errorf("%w: unknown time zone %q", appErrors.ErrInvalidInput, *req.TimeZone)
+		}
+		updates["time_zone"] = loc.String()
+	}
+	if req.Locale != nil {
+		tag, err := language.Parse(*req.Locale)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid locale %q", appErrors.ErrInvalidInput, *req.Locale)
+		}
+		updates["locale"] = tag.String()
+	}
+	if req.WeekStart != nil {
+		updates["week_start"] = *req.WeekStart
+	}
+
+	if len(updates) > 0 {
+		if err := db.DB.Model(&models.User{}).Where("id = ?", userID).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return GetProfile(userID)
+}
+
+// DeleteAccount removes the user together with the projects nobody else
+// works in, the tasks in them, their personal workspace and every
+// credential they hold. Projects they own alone but share with others pass
+// to the highest-ranked remaining member. The last admin of a team
+// workspace can't leave it. The caller confirms with their password, or
+// for SSO-only accounts a TOTP code or a fresh login.
+func DeleteAccount(userID, sessionID uint, password, code, ip string) error {
+	if err := auth.ConfirmIdentity(userID, sessionID, password, code, ip); err != nil {
+		return err
+	}
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		var soleOwned []uint
+		err := tx.Model(&models.ProjectMember{}).
+			Where("user_id = ? AND role = ?", userID, models.RoleOwner).
+			Where("project_id NOT IN (?)", tx.Model(&models.ProjectMember{}).
+				Select("project_id").
+				Where("user_id <> ? AND role = ?", userID, models.RoleOwner)).
+			Pluck("project_id", &soleOwned).Error
+		if err != nil {
+			return err
+		}
+
+		// Team workspaces keep someone who can manage them.
+		var administered []uint
+		if err := tx.Model(&models.WorkspaceMember{}).
+			Where("user_id = ? AND role = ?", userID, models.WorkspaceRoleAdmin).
+			Where("workspace_id NOT IN (?)", tx.Model(&models.Workspace{}).Select("id").Where("personal_user_id = ?", userID)).
+			Pluck("workspace_id", &administered).Error; err != nil {
+			return err
+		}
+		for _, workspaceID := range administered {
+			if err := keepAnAdmin(tx, workspaceID); err != nil {
+				return err
+			}
+		}
+
+		var unshared []uint
+		for _, projectID := range soleOwned {
+			heir, err := successor(tx, projectID, userID)
+			if err != nil {
+				return err
+			}
+			if heir == nil {
+				unshared = append(unshared, projectID)
+				continue
+			}
+			if err := tx.Model(heir).Update("role", models.RoleOwner).Error; err != nil {
+				return err
+			}
+		}
+		if err := deleteProjects(tx, unshared); err != nil {
+			return err
+		}
+
+		labels := tx.Model(&models.Label{}).Select("id").Where("user_id = ?", userID)
+		if err := tx.Exec("DELETE FROM task_labels WHERE label_id IN (?)", labels).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM task_assignees WHERE user_id = ?", userID).Error; err != nil {
+			return err
+		}
+		// Comments stay for the rest of the team, without an author.
+		if err := tx.Exec("DELETE FROM comment_mentions WHERE user_id = ?", userID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Comment{}).Where("user_id = ?", userID).Update("user_id", nil).Error; err != nil {
+			return err
+		}
+
+		for _, model := range []interface{}{
+			&models.Label{},
+			&models.Session{},
+			&models.PersonalAccessToken{},
+			&models.UserToken{},
+			&models.RecoveryCode{},
+			&models.ExternalIdentity{},
+			&models.ProjectMember{},
+			&models.WorkspaceMember{},
+		} {
+			if err := tx.Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		// The personal workspace goes too, unless projects others took over
+		// remain in it, trashed ones included.
+		personal := tx.Model(&models.Workspace{}).Select("id").Where("personal_user_id = ?", userID)
+		var remaining int64
+		if err := tx.Unscoped().Model(&models.Project{}).Where("workspace_id IN (?)", personal).Count(&remaining).Error; err != nil {
+			return err
+		}
+		if remaining == 0 {
+			if err := tx.Where("personal_user_id = ?", userID).Delete(&models.Workspace{}).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Delete(&models.User{}, userID).Error
+	})
+}
+
+// successor picks who takes over a project from a leaving owner: the
+// member with the highest role, the longest-standing one among equals. It
+// returns nil when nobody else is a member.
+func successor(tx *gorm.DB, projectID, leavingID uint) (*models.ProjectMember, error) {
+	var members []models.ProjectMember
+	if err := tx.
+		Where("project_id = ? AND user_id <> ?", projectID, leavingID).
+		Order("created_at, id").
+		Find(&members).Error; err != nil {
+		return nil, err
+	}
+
+	var heir *models.ProjectMember
+	for i := range members {
+		if heir == nil || roleRank[members[i].Role] > roleRank[heir.Role] {
+			heir = &members[i]
+		}
+	}
+	return heir, nil
+}
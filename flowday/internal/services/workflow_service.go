@@ -0,0 +1,221 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"flowday/internal/db"
+	appErrors "flowday/internal/errors"
+	"flowday/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Workflow is the set of states a project's tasks move through and the
+// moves between them that are allowed.
+type Workflow struct {
+	States      []models.WorkflowState      `json:"states"`
+	Transitions []models.WorkflowTransition `json:"transitions"`
+}
+
+// state returns the state with the given key, or nil.
+func (w *Workflow) state(key string) *models.WorkflowState {
+	for i := range w.States {
+		if w.States[i].Key == key {
+			return &w.States[i]
+		}
+	}
+	return nil
+}
+
+func (w *Workflow) defaultState() *models.WorkflowState {
+	for i := range w.States {
+		if w.States[i].IsDefault {
+			return &w.States[i]
+		}
+	}
+	return &w.States[0]
+}
+
+// allows reports whether a task may move from one state to another. With
+// no transitions defined every move is allowed, and so is any move away
+// from a state the workflow no longer knows.
+func (w *Workflow) allows(from, to string) bool {
+	if from == to || len(w.Transitions) == 0 || w.state(from) == nil {
+		return true
+	}
+	for _, t := range w.Transitions {
+		if t.From == from && t.To == to {
+			return true
+		}
+	}
+	return false
+}
+
+// loadWorkflow returns the project's workflow, or the default one when the
+// project hasn't defined its own.
+func loadWorkflow(tx *gorm.DB, projectID uint) (*Workflow, error) {
+	workflow := Workflow{Transitions: []models.WorkflowTransition{}}
+	err := tx.Where("project_id = ?", projectID).Order("position, id").Find(&workflow.States).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(workflow.States) == 0 {
+		workflow.States = models.DefaultWorkflow()
+		return &workflow, nil
+	}
+
+	err = tx.Where("project_id = ?", projectID).Order("id").Find(&workflow.Transitions).Error
+	if err != nil {
+		return nil, err
+	}
+	return &workflow, nil
+}
+
+// GetWorkflow returns a project's workflow to anyone who can see the project.
+func GetWorkflow(userID, projectID uint) (*Workflow, error) {
+	if _, err := authorizeProject(userID, projectID, models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return loadWorkflow(db.DB, projectID)
+}
+
+// ReplaceWorkflow swaps the project's workflow for a new one. States still
+// used by tasks, including those in the trash, can't be removed. Tasks
+// take on the category of their state in the new workflow, and with it
+// their completion time.
+func ReplaceWorkflow(userID, projectID uint, workflow Workflow) (*Workflow, error) {
+	if _, err := authorizeProject(userID, projectID, models.RoleOwner); err != nil {
+		return nil, err
+	}
+	if err := validateWorkflow(&workflow); err != nil {
+		return nil, err
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var used []string
+		err := tx.Unscoped().Model(&models.Task{}).
+			Where("project_id = ?", projectID).
+			Distinct().Pluck("status", &used).Error
+		if err != nil {
+			return err
+		}
+		for _, key := range used {
+			if workflow.state(key) == nil {
+				return fmt.Errorf("%w: state %q is still used by tasks", appErrors.ErrInvalidWorkflow, key)
+			}
+		}
+
+		for _, model := range []interface{}{&models.WorkflowState{}, &models.WorkflowTransition{}} {
+			if err := tx.Where("project_id = ?", projectID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		for i := range workflow.States {
+			workflow.States[i].ID = 0
+			workflow.States[i].ProjectID = projectID
+			workflow.States[i].Position = i
+			workflow.States[i].CreatedAt = time.Time{}
+		}
+		if err := tx.Create(&workflow.States).Error; err != nil {
+			return err
+		}
+		for i := range workflow.Transitions {
+			workflow.Transitions[i].ID = 0
+			workflow.Transitions[i].ProjectID = projectID
+		}
+		if len(workflow.Transitions) > 0 {
+			if err := tx.Create(&workflow.Transitions).Error; err != nil {
+				return err
+			}
+		}
+
+		// Completion follows the category as in stampCompletion: tasks that
+		// become done are stamped now, done ones keep their time.
+		now := time.Now().UTC()
+		for _, state := range workflow.States {
+			updates := map[string]interface{}{
+				"status_category": state.Category,
+				"completed_at":    nil,
+			}
+			if state.Category == models.CategoryDone {
+				updates["completed_at"] = gorm.Expr("COALESCE(completed_at, ?)", now)
+			}
+
+			err := tx.Unscoped().Model(&models.Task{}).
+				Where("project_id = ? AND status = ?", projectID, state.Key).
+				Updates(updates).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if workflow.Transitions == nil {
+		workflow.Transitions = []models.WorkflowTransition{}
+	}
+	return &workflow, nil
+}
+
+func validateWorkflow(workflow *Workflow) error {
+	if len(workflow.States) == 0 {
+		return fmt.Errorf("%w: at least one state is required", appErrors.ErrInvalidWorkflow)
+	}
+
+	keys := map[string]bool{}
+	defaults := 0
+	for _, state := range workflow.States {
+		if state.Key == "" {
+			return fmt.Errorf("%w: every state needs a key", appErrors.ErrInvalidWorkflow)
+		}
+		if keys[state.Key] {
+			return fmt.Errorf("%w: duplicate state %q", appErrors.ErrInvalidWorkflow, state.Key)
+		}
+		keys[state.Key] = true
+
+		switch state.Category {
+		case models.CategoryTodo, models.CategoryInProgress, models.CategoryDone:
+		default:
+			return fmt.Errorf("%w: state %q has unknown category %q", appErrors.ErrInvalidWorkflow, state.Key, state.Category)
+		}
+		if state.IsDefault {
+			defaults++
+		}
+	}
+	if defaults != 1 {
+		return fmt.Errorf("%w: exactly one state must be the default", appErrors.ErrInvalidWorkflow)
+	}
+
+	for _, t := range workflow.Transitions {
+		if !keys[t.From] || !keys[t.To] {
+			return fmt.Errorf("%w: transition %q to %q uses an unknown state", appErrors.ErrInvalidWorkflow, t.From, t.To)
+		}
+	}
+	return nil
+}
+
+// resolveStatus checks a task's new status against its project's workflow
+// and returns the state; an empty status means the default state.
+func resolveStatus(projectID uint, from, to string) (*models.WorkflowState, error) {
+	workflow, err := loadWorkflow(db.DB, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if to == "" {
+		return workflow.defaultState(), nil
+	}
+
+	state := workflow.state(to)
+	if state == nil {
+		return nil, fmt.Errorf("%w: %q", appErrors.ErrInvalidStatus, to)
+	}
+	if from != "" && !workflow.allows(from, to) {
+		return nil, fmt.Errorf("%w: %q to %q", appErrors.ErrInvalidTransition, from, to)
+	}
+	return state, nil
+}
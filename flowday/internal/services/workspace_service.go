@@ -0,0 +1,299 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"flowday/internal/db"
+	"flowday/internal/dto"
+	appErrors "flowday/internal/errors"
+	"flowday/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// authorizeWorkspace checks that the user belongs to the workspace, and is
+// an admin when admin is set. Non-members get ErrNotFound.
+func authorizeWorkspace(userID, workspaceID uint, admin bool) (*models.WorkspaceMember, error) {
+	var member models.WorkspaceMember
+	err := db.DB.Where("workspace_id = ? AND user_id = ?", workspaceID, userID).First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, appErrors.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if admin && member.Role != models.WorkspaceRoleAdmin {
+		return nil, appErrors.ErrForbidden
+	}
+	return &member, nil
+}
+
+// lockWorkspace reads the workspace within tx after writing to its row, so
+// quota checks on one workspace run one at a time until tx ends. Call it
+// before counting what the quota limits.
+func lockWorkspace(tx *gorm.DB, workspaceID uint) (*models.Workspace, error) {
+	result := tx.Model(&models.Workspace{}).Where("id = ?", workspaceID).UpdateColumn("id", gorm.Expr("id"))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, appErrors.ErrNotFound
+	}
+
+	var workspace models.Workspace
+	if err := tx.First(&workspace, workspaceID).Error; err != nil {
+		return nil, err
+	}
+	return &workspace, nil
+}
+
+// GetWorkspaces lists the user's workspaces with their role, personal
+// workspace included.
+func GetWorkspaces(userID uint) ([]models.Workspace, error) {
+	var workspaces []models.Workspace
+	err := db.DB.
+		Select("workspaces.*, workspace_members.role AS role").
+		Joins("JOIN workspace_members ON workspace_members.workspace_id = workspaces.id").
+		Where("workspace_members.user_id = ?", userID).
+		Order("workspaces.personal DESC, workspaces.name").
+		Find(&workspaces).Error
+	return workspaces, err
+}
+
+func GetWorkspace(userID, workspaceID uint) (*models.Workspace, error) {
+	member, err := authorizeWorkspace(userID, workspaceID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var workspace models.Workspace
+	if err := db.DB.First(&workspace, workspaceID).Error; err != nil {
+		return nil, appErrors.ErrNotFound
+	}
+	workspace.Role = member.Role
+	return &workspace, nil
+}
+
+// CreateWorkspace creates a team workspace with the user as its admin.
+func CreateWorkspace(userID uint, req dto.CreateWorkspaceRequest) (*models.Workspace, error) {
+	workspace := models.Workspace{Name: req.Name, DefaultProjectRole: req.DefaultProjectRole}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&workspace).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.WorkspaceMember{
+			WorkspaceID: workspace.ID,
+			UserID:      userID,
+			Role:        models.WorkspaceRoleAdmin,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	workspace.Role = models.WorkspaceRoleAdmin
+	return &workspace, nil
+}
+
+func UpdateWorkspace(userID, workspaceID uint, req dto.UpdateWorkspaceRequest) (*models.Workspace, error) {
+	if _, err := authorizeWorkspace(userID, workspaceID, true); err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		updates["name"] = *req.Name
+	}
+	if req.MaxProjects != nil {
+		updates["max_projects"] = *req.MaxProjects
+	}
+	if req.MaxMembers != nil {
+		updates["max_members"] = *req.MaxMembers
+	}
+	if req.DefaultProjectRole != nil {
+		updates["default_project_role"] = *req.DefaultProjectRole
+	}
+
+	if len(updates) > 0 {
+		if err := db.DB.Model(&models.Workspace{}).Where("id = ?", workspaceID).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return GetWorkspace(userID, workspaceID)
+}
+
+func GetWorkspaceMembers(userID, workspaceID uint) ([]models.WorkspaceMember, error) {
+	if _, err := authorizeWorkspace(userID, workspaceID, false); err != nil {
+		return nil, err
+	}
+
+	var members []models.WorkspaceMember
+	err := db.DB.
+		Where("workspace_id = ?", workspaceID).
+		Preload("User", publicUserColumns).
+		Order("created_at").
+		Find(&members).Error
+	return members, err
+}
+
+// AddWorkspaceMember adds an existing user to a team workspace and grants
+// them the default role on its projects.
+func AddWorkspaceMember(userID, workspaceID uint, email, role string) (*models.WorkspaceMember, error) {
+	workspace, err := GetWorkspace(userID, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if workspace.Role != models.WorkspaceRoleAdmin {
+		return nil, appErrors.ErrForbidden
+	}
+	if workspace.Personal {
+		return nil, fmt.Errorf("%w: personal workspaces cannot be shared", appErrors.ErrInvalidInput)
+	}
+
+	var user models.User
+	if err := db.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, appErrors.ErrNotFound
+	}
+
+	member := models.WorkspaceMember{WorkspaceID: workspaceID, UserID: user.ID, Role: role}
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		workspace, err := lockWorkspace(tx, workspaceID)
+		if err != nil {
+			return err
+		}
+
+		var members int64
+		if err := tx.Model(&models.WorkspaceMember{}).Where("workspace_id = ?", workspaceID).Count(&members).Error; err != nil {
+			return err
+		}
+
+		var existing int64
+		if err := tx.Model(&models.WorkspaceMember{}).
+			Where("workspace_id = ? AND user_id = ?", workspaceID, user.ID).
+			Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return appErrors.ErrAlreadyMember
+		}
+		if workspace.MaxMembers > 0 && members >= int64(workspace.MaxMembers) {
+			return appErrors.ErrQuotaExceeded
+		}
+
+		if err := tx.Create(&member).Error; err != nil {
+			return err
+		}
+		if workspace.DefaultProjectRole == "" {
+			return nil
+		}
+
+		var projectIDs []uint
+		if err := tx.Unscoped().Model(&models.Project{}).Where("workspace_id = ?", workspaceID).Pluck("id", &projectIDs).Error; err != nil {
+			return err
+		}
+		for _, projectID := range projectIDs {
+			err := tx.Where(models.ProjectMember{ProjectID: projectID, UserID: user.ID}).
+				Attrs(models.ProjectMember{Role: workspace.DefaultProjectRole}).
+				FirstOrCreate(&models.ProjectMember{}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	member.User = user.Public()
+	return &member, nil
+}
+
+func UpdateWorkspaceMemberRole(userID, workspaceID, memberID uint, role string) (*models.WorkspaceMember, error) {
+	if _, err := authorizeWorkspace(userID, workspaceID, true); err != nil {
+		return nil, err
+	}
+
+	var member models.WorkspaceMember
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("workspace_id = ? AND user_id = ?", workspaceID, memberID).First(&member).Error; err != nil {
+			return appErrors.ErrNotFound
+		}
+		if member.Role == models.WorkspaceRoleAdmin && role != models.WorkspaceRoleAdmin {
+			if err := keepAnAdmin(tx, workspaceID); err != nil {
+				return err
+			}
+		}
+
+		member.Role = role
+		return tx.Model(&member).Update("role", role).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// RemoveWorkspaceMember takes a user out of the workspace and all of its
+// projects. Admins can remove anyone; members can only leave. Nobody can
+// leave projects they are the only owner of.
+func RemoveWorkspaceMember(userID, workspaceID, memberID uint) error {
+	if _, err := authorizeWorkspace(userID, workspaceID, userID != memberID); err != nil {
+		return err
+	}
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		var member models.WorkspaceMember
+		if err := tx.Where("workspace_id = ? AND user_id = ?", workspaceID, memberID).First(&member).Error; err != nil {
+			return appErrors.ErrNotFound
+		}
+		if member.Role == models.WorkspaceRoleAdmin {
+			if err := keepAnAdmin(tx, workspaceID); err != nil {
+				return err
+			}
+		}
+
+		// Projects in the trash are left to the purge.
+		live := tx.Model(&models.Project{}).Select("id").Where("workspace_id = ?", workspaceID)
+		projects := tx.Unscoped().Model(&models.Project{}).Select("id").Where("workspace_id = ?", workspaceID)
+
+		var owned []uint
+		if err := tx.Model(&models.ProjectMember{}).
+			Where("user_id = ? AND role = ? AND project_id IN (?)", memberID, models.RoleOwner, live).
+			Pluck("project_id", &owned).Error; err != nil {
+			return err
+		}
+		for _, projectID := range owned {
+			if err := keepAnOwner(tx, projectID); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("user_id = ? AND project_id IN (?)", memberID, projects).Delete(&models.ProjectMember{}).Error; err != nil {
+			return err
+		}
+		if err := unassign(tx, memberID, projects); err != nil {
+			return err
+		}
+		return tx.Delete(&member).Error
+	})
+}
+
+// keepAnAdmin fails unless the workspace has an admin besides the one about
+// to be demoted or removed.
+func keepAnAdmin(tx *gorm.DB, workspaceID uint) error {
+	var admins int64
+	if err := tx.Model(&models.WorkspaceMember{}).
+		Where("workspace_id = ? AND role = ?", workspaceID, models.WorkspaceRoleAdmin).
+		Count(&admins).Error; err != nil {
+		return err
+	}
+	if admins < 2 {
+		return appErrors.ErrLastAdmin
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package tokens
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"flowday/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+// Key is one signing key. Keys loaded from a public PEM file can verify but
+// never sign. A key with RetiresAt set stops verifying after that moment.
+type Key struct {
+	ID        string
+	Algorithm string
+	CreatedAt time.Time
+	RetiresAt time.Time
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+func (k *Key) CanSign() bool {
+	return k.signKey != nil
+}
+
+func (k *Key) method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+// PublicKey returns the verification key of an asymmetric key, or nil for
+// HMAC secrets which must never be published.
+func (k *Key) PublicKey() crypto.PublicKey {
+	if k.Algorithm == HS256 {
+		return nil
+	}
+	return k.verifyKey
+}
+
+func LoadKey(kc config.JWTKey) (*Key, error) {
+	if kc.ID == "" {
+		return nil, errors.New("jwt key id is required")
+	}
+
+	key := &Key{ID: kc.ID, Algorithm: kc.Algorithm}
+
+	switch kc.Algorithm {
+	case HS256:
+		if len(kc.Secret) < 32 {
+			return nil, fmt.Errorf("jwt key %q: HS256 secret must be at least 32 bytes", kc.ID)
+		}
+		key.signKey = []byte(kc.Secret)
+		key.verifyKey = []byte(kc.Secret)
+		return key, nil
+	case RS256, EdDSA:
+		pem, err := os.ReadFile(kc.File)
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %q: %w", kc.ID, err)
+		}
+		if err := key.parsePEM(pem); err != nil {
+			return nil, fmt.Errorf("jwt key %q: %w", kc.ID, err)
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("jwt key %q: unsupported algorithm %q", kc.ID, kc.Algorithm)
+	}
+}
+
+// NewKey wraps an in-memory private key (*rsa.PrivateKey or
+// ed25519.PrivateKey).
+func NewKey(id string, private crypto.Signer) (*Key, error) {
+	switch k := private.(type) {
+	case *rsa.PrivateKey:
+		return &Key{ID: id, Algorithm: RS256, signKey: k, verifyKey: &k.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &Key{ID: id, Algorithm: EdDSA, signKey: k, verifyKey: k.Public()}, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", private)
+	}
+}
+
+func (k *Key) parsePEM(data []byte) error {
+	switch k.Algorithm {
+	case RS256:
+		if private, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+			k.signKey, k.verifyKey = private, &private.PublicKey
+			return nil
+		}
+		public, err := jwt.ParseRSAPublicKeyFromPEM(data)
+		if err != nil {
+			return err
+		}
+		k.verifyKey = public
+	case EdDSA:
+		if private, err := jwt.ParseEdPrivateKeyFromPEM(data); err == nil {
+			k.signKey, k.verifyKey = private, private.(ed25519.PrivateKey).Public()
+			return nil
+		}
+		public, err := jwt.ParseEdPublicKeyFromPEM(data)
+		if err != nil {
+			return err
+		}
+		k.verifyKey = public
+	}
+	return nil
+}
+
+// GenerateKey creates a fresh asymmetric key for rotation. The key id is
+// derived from the public key so every instance names it the same way.
+func GenerateKey(algorithm string) (*Key, error) {
+	var private crypto.Signer
+	var err error
+
+	switch algorithm {
+	case RS256:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case EdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("cannot generate %s keys", algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(der)
+
+	key, err := NewKey(hex.EncodeToString(sum[:8]), private)
+	if err != nil {
+		return nil, err
+	}
+	key.CreatedAt = time.Now()
+	return key, nil
+}
+
+func (k *Key) privatePEM() ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.signKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
@@ -0,0 +1,114 @@
+package tokens
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"flowday/internal/db"
+	"flowday/internal/models"
+	"flowday/internal/sealbox"
+
+	"gorm.io/gorm"
+)
+
+// KeyStore persists rotated keys so that every instance signs and verifies
+// with the same set.
+type KeyStore interface {
+	Keys() ([]*Key, error)
+	// Activate saves next as the active key and retires previous, the id
+	// of the active stored key or "" for the static one, at retireAt. When
+	// previous is no longer active because another instance rotated first,
+	// it saves nothing and returns errKeyRotated.
+	Activate(next *Key, previous string, retireAt time.Time) error
+}
+
+var errKeyRotated = errors.New("signing key was rotated concurrently")
+
+// DBKeyStore keeps rotated keys in the signing_keys table, their private
+// keys sealed with AES-GCM under Secret. It refuses to store keys without
+// one, since anyone who can read the database could then sign tokens.
+type DBKeyStore struct {
+	Secret string
+}
+
+func (s DBKeyStore) Keys() ([]*Key, error) {
+	var rows []models.SigningKey
+	if err := db.DB.
+		Where("retires_at IS NULL OR retires_at > ?", time.Now()).
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	keys := make([]*Key, 0, len(rows))
+	for _, row := range rows {
+		key := &Key{ID: row.KID, Algorithm: row.Algorithm, CreatedAt: row.CreatedAt}
+		pem, err := s.open(row.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %q: %w", row.KID, err)
+		}
+		if err := key.parsePEM(pem); err != nil {
+			return nil, fmt.Errorf("signing key %q: %w", row.KID, err)
+		}
+		if row.RetiresAt != nil {
+			key.RetiresAt = *row.RetiresAt
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (s DBKeyStore) Activate(next *Key, previous string, retireAt time.Time) error {
+	pem, err := next.privatePEM()
+	if err != nil {
+		return err
+	}
+	private, err := s.seal(pem)
+	if err != nil {
+		return err
+	}
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if previous != "" {
+			result := tx.Model(&models.SigningKey{}).
+				Where("k_id = ? AND retires_at IS NULL", previous).
+				Update("retires_at", retireAt)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errKeyRotated
+			}
+		}
+
+		// Only one instance gets to insert while no other key is active.
+		result := tx.Exec(`INSERT INTO signing_keys (k_id, algorithm, private_key, created_at)
+			SELECT ?, ?, ?, ? WHERE NOT EXISTS (SELECT 1 FROM signing_keys WHERE retires_at IS NULL)`,
+			next.ID, next.Algorithm, private, next.CreatedAt)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errKeyRotated
+		}
+
+		// Keys whose tokens have all expired are of no further use.
+		return tx.
+			Where("retires_at IS NOT NULL AND retires_at < ?", time.Now()).
+			Delete(&models.SigningKey{}).Error
+	})
+}
+
+func (s DBKeyStore) seal(pem []byte) (string, error) {
+	return sealbox.New(s.Secret).Seal(pem)
+}
+
+// open returns the PEM of a stored private key. Plain PEM is accepted so
+// keys saved before a secret was configured keep working.
+func (s DBKeyStore) open(stored string) ([]byte, error) {
+	if !strings.HasPrefix(stored, sealbox.Prefix) {
+		return []byte(stored), nil
+	}
+	return sealbox.New(s.Secret).Open(stored)
+}
@@ -0,0 +1,348 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"flowday/internal/config"
+	appErrors "flowday/internal/errors"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Minimum time between key reloads triggered by an unknown kid, so garbage
+// tokens cannot hammer the key store.
+const reloadCooldown = 10 * time.Second
+
+// PurposeMFA marks the short-lived token handed out between the password
+// and the second factor. Access tokens have no purpose.
+const PurposeMFA = "mfa"
+
+// Claims is the payload of every token Flowday issues.
+type Claims struct {
+	UserID    uint   `json:"user_id"`
+	SessionID uint   `json:"sid,omitempty"`
+	Purpose   string `json:"purpose,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Service signs tokens with its active key and verifies them against every
+// key it knows, selected by the "kid" header.
+type Service struct {
+	mu          sync.RWMutex
+	issuer      string
+	audience    string
+	accessTTL   time.Duration
+	rotateEvery time.Duration
+	static      *Key
+	active      *Key
+	configured  map[string]*Key
+	keys        map[string]*Key
+	store       KeyStore
+	lastReload  time.Time
+}
+
+func New(cfg config.JWT) (*Service, error) {
+	active, err := LoadKey(cfg.Active)
+	if err != nil {
+		return nil, err
+	}
+	if !active.CanSign() {
+		return nil, fmt.Errorf("jwt key %q: active key needs a private key", active.ID)
+	}
+	if cfg.RotateEvery > 0 && active.Algorithm == HS256 {
+		return nil, errors.New("jwt key rotation needs an RS256 or EdDSA key")
+	}
+
+	s := &Service{
+		issuer:      cfg.Issuer,
+		audience:    cfg.Audience,
+		accessTTL:   cfg.AccessTTL,
+		rotateEvery: cfg.RotateEvery,
+		static:      active,
+		active:      active,
+		configured:  map[string]*Key{active.ID: active},
+	}
+
+	for _, kc := range cfg.VerifyKeys {
+		key, err := LoadKey(kc)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := s.configured[key.ID]; dup {
+			return nil, fmt.Errorf("jwt key %q configured twice", key.ID)
+		}
+		s.configured[key.ID] = key
+	}
+
+	s.keys = s.configured
+	return s, nil
+}
+
+func (s *Service) AccessTTL() time.Duration {
+	return s.accessTTL
+}
+
+// UseStore makes the service sign with the newest key in the store and
+// accept every key the store still holds, on top of the configured ones.
+func (s *Service) UseStore(store KeyStore) error {
+	s.store = store
+	return s.reload()
+}
+
+// Rotate activates a new key. The previous active key keeps verifying until
+// every access token it could have signed has expired; for the static key
+// from config that is worked out in reload.
+func (s *Service) Rotate(next *Key) error {
+	if s.store == nil {
+		return errors.New("key rotation needs a key store")
+	}
+
+	s.mu.RLock()
+	previous := s.active
+	s.mu.RUnlock()
+
+	previousID := ""
+	if previous != s.static {
+		previousID = previous.ID
+	}
+	// Losing the race to another instance is fine: its key is picked up.
+	err := s.store.Activate(next, previousID, time.Now().Add(s.accessTTL))
+	if err != nil && !errors.Is(err, errKeyRotated) {
+		return err
+	}
+
+	return s.reload()
+}
+
+// RotateIfDue rotates when the active key is older than the configured
+// rotation interval. The static key from config always counts as due.
+func (s *Service) RotateIfDue() error {
+	if s.rotateEvery <= 0 {
+		return nil
+	}
+
+	// Pick up a rotation another instance may have done first.
+	if err := s.reload(); err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	active := s.active
+	s.mu.RUnlock()
+
+	if active != s.static && time.Since(active.CreatedAt) < s.rotateEvery {
+		return nil
+	}
+
+	next, err := GenerateKey(s.static.Algorithm)
+	if err != nil {
+		return err
+	}
+	log.Printf("rotating JWT signing key to %s", next.ID)
+	return s.Rotate(next)
+}
+
+// StartRotation checks for due rotations until ctx is done.
+func (s *Service) StartRotation(ctx context.Context) {
+	if s.rotateEvery <= 0 {
+		return
+	}
+
+	interval := s.rotateEvery / 10
+	if interval > time.Hour {
+		interval = time.Hour
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.RotateIfDue(); err != nil {
+					log.Println("JWT key rotation failed:", err)
+				}
+			}
+		}
+	}()
+}
+
+func (s *Service) reload() error {
+	if s.store == nil {
+		return nil
+	}
+
+	stored, err := s.store.Keys()
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]*Key, len(s.configured)+len(stored))
+	for id, key := range s.configured {
+		keys[id] = key
+	}
+
+	active := s.static
+	var oldest time.Time
+	for _, key := range stored {
+		keys[key.ID] = key
+		if key.RetiresAt.IsZero() && (active == s.static || key.CreatedAt.After(active.CreatedAt)) {
+			active = key
+		}
+		if oldest.IsZero() || key.CreatedAt.Before(oldest) {
+			oldest = key.CreatedAt
+		}
+	}
+
+	// Once a rotated key signs, the static key retires an access TTL after
+	// the oldest stored key, a moment every instance and restart agrees on.
+	// It then stops verifying and leaves the JWKS.
+	if active != s.static {
+		retiring := *s.static
+		retiring.RetiresAt = oldest.Add(s.accessTTL)
+		if retiring.retired() {
+			delete(keys, s.static.ID)
+		} else {
+			keys[s.static.ID] = &retiring
+		}
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.active = active
+	s.lastReload = time.Now()
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Issue signs claims with the active key. Issuer, audience and timestamps
+// are filled in; ExpiresAt defaults to the access token TTL.
+func (s *Service) Issue(claims Claims) (string, error) {
+	s.mu.RLock()
+	key := s.active
+	s.mu.RUnlock()
+
+	now := time.Now()
+	claims.Issuer = s.issuer
+	claims.Audience = jwt.ClaimStrings{s.audience}
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	if claims.ExpiresAt == nil {
+		claims.ExpiresAt = jwt.NewNumericDate(now.Add(s.accessTTL))
+	}
+
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.ID
+
+	return token.SignedString(key.signKey)
+}
+
+// Verify parses and validates a token. Anything other than the algorithms
+// of the configured keys (including "none") is rejected, as are tokens for
+// another issuer or audience and tokens without an expiry.
+func (s *Service) Verify(tokenStr string) (*Claims, error) {
+	claims := &Claims{}
+
+	token, err := jwt.ParseWithClaims(tokenStr, claims, s.keyFunc,
+		jwt.WithValidMethods([]string{HS256, RS256, EdDSA}),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithAudience(s.audience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || !token.Valid {
+		return nil, appErrors.ErrUnauthorized
+	}
+
+	return claims, nil
+}
+
+func (s *Service) keyFunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	key, ok := s.lookup(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if key.retired() {
+		return nil, fmt.Errorf("key %q is retired", kid)
+	}
+	// The key decides the algorithm, never the token; this is what stops
+	// an RS256 public key from being used as an HS256 secret.
+	if t.Method.Alg() != key.Algorithm {
+		return nil, errors.New("unexpected signing method")
+	}
+
+	return key.verifyKey, nil
+}
+
+// lookup finds a key by id. An unknown id may come from a key another
+// instance just rotated in, so the store is re-read once before giving up.
+func (s *Service) lookup(kid string) (*Key, bool) {
+	s.mu.RLock()
+	key, ok := s.keys[kid]
+	stale := s.store != nil && time.Since(s.lastReload) > reloadCooldown
+	s.mu.RUnlock()
+
+	if ok || !stale {
+		return key, ok
+	}
+	if err := s.reload(); err != nil {
+		log.Println("JWT key reload failed:", err)
+		return nil, false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok = s.keys[kid]
+	return key, ok
+}
+
+func (k *Key) retired() bool {
+	return !k.RetiresAt.IsZero() && time.Now().After(k.RetiresAt)
+}
+
+var std *Service
+
+// Init builds the process-wide token service used by auth and middleware.
+// With rotation enabled, keys are shared through the database.
+func Init(cfg config.JWT) error {
+	s, err := New(cfg)
+	if err != nil {
+		return err
+	}
+
+	if cfg.RotateEvery > 0 {
+		if cfg.KeyEncryptionSecret == "" {
+			return errors.New("jwt key rotation needs JWT_KEY_ENCRYPTION_SECRET to seal the stored keys")
+		}
+		if err := s.UseStore(DBKeyStore{Secret: cfg.KeyEncryptionSecret}); err != nil {
+			return err
+		}
+		if err := s.RotateIfDue(); err != nil {
+			return err
+		}
+	}
+
+	std = s
+	return nil
+}
+
+func Default() *Service {
+	return std
+}
+
+func Issue(claims Claims) (string, error) {
+	return std.Issue(claims)
+}
+
+func Verify(tokenStr string) (*Claims, error) {
+	return std.Verify(tokenStr)
+}
@@ -0,0 +1,286 @@
+package tokens
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"flowday/internal/config"
+	"flowday/internal/db"
+	"flowday/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// writeKey stores a private key as PKCS#8 PEM and returns its path
+func writeKey(t *testing.T, name string, key interface{}) string {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), name+".pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+	return path
+}
+
+func testConfig(active config.JWTKey, verify ...config.JWTKey) config.JWT {
+	return config.JWT{
+		Active:     active,
+		VerifyKeys: verify,
+		Issuer:     "flowday",
+		Audience:   "flowday-api",
+		AccessTTL:  time.Minute,
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	keys := []config.JWTKey{
+		{ID: "hs", Algorithm: HS256, Secret: "0123456789abcdef0123456789abcdef"},
+		{ID: "rs", Algorithm: RS256, File: writeKey(t, "rs", rsaKey)},
+		{ID: "ed", Algorithm: EdDSA, File: writeKey(t, "ed", edKey)},
+	}
+
+	for _, kc := range keys {
+		t.Run(kc.Algorithm, func(t *testing.T) {
+			s, err := New(testConfig(kc))
+			require.NoError(t, err)
+
+			token, err := s.Issue(Claims{UserID: 7, SessionID: 3})
+			require.NoError(t, err)
+
+			claims, err := s.Verify(token)
+			require.NoError(t, err)
+			assert.Equal(t, uint(7), claims.UserID)
+			assert.Equal(t, uint(3), claims.SessionID)
+		})
+	}
+}
+
+func TestVerifyKeysDuringRotation(t *testing.T) {
+	oldKey := config.JWTKey{ID: "old", Algorithm: HS256, Secret: "old-secret-old-secret-old-secret-1"}
+	newKey := config.JWTKey{ID: "new", Algorithm: HS256, Secret: "new-secret-new-secret-new-secret-2"}
+
+	before, err := New(testConfig(oldKey))
+	require.NoError(t, err)
+	token, _ := before.Issue(Claims{UserID: 1})
+
+	after, err := New(testConfig(newKey, oldKey))
+	require.NoError(t, err)
+	_, err = after.Verify(token)
+	assert.NoError(t, err, "tokens signed by a verify-only key stay valid")
+
+	dropped, err := New(testConfig(newKey))
+	require.NoError(t, err)
+	_, err = dropped.Verify(token)
+	assert.Error(t, err)
+}
+
+func TestRejectsAlgorithmConfusion(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	s, err := New(testConfig(config.JWTKey{ID: "rs", Algorithm: RS256, File: writeKey(t, "rs", rsaKey)}))
+	require.NoError(t, err)
+
+	// HS256 token "signed" with the RSA public key bytes
+	pub, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": 1, "iss": "flowday", "aud": "flowday-api",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	forged.Header["kid"] = "rs"
+	token, _ := forged.SignedString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}))
+
+	_, err = s.Verify(token)
+	assert.Error(t, err)
+}
+
+func TestRejectsWeakSecret(t *testing.T) {
+	_, err := New(testConfig(config.JWTKey{ID: "hs", Algorithm: HS256, Secret: "short"}))
+	assert.Error(t, err)
+}
+
+// memStore is an in-memory KeyStore
+type memStore struct {
+	keys map[string]*Key
+}
+
+func (m *memStore) Keys() ([]*Key, error) {
+	var keys []*Key
+	for _, k := range m.keys {
+		if !k.retired() {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+func (m *memStore) Activate(next *Key, previous string, retireAt time.Time) error {
+	if previous != "" {
+		m.keys[previous].RetiresAt = retireAt
+	}
+	m.keys[next.ID] = next
+	return nil
+}
+
+func TestRotation(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	cfg := testConfig(config.JWTKey{ID: "static", Algorithm: EdDSA, File: writeKey(t, "ed", edKey)})
+	cfg.RotateEvery = time.Hour
+
+	s, err := New(cfg)
+	require.NoError(t, err)
+	store := &memStore{keys: map[string]*Key{}}
+	require.NoError(t, s.UseStore(store))
+
+	// The static key is always due, so the first check rotates away from it.
+	require.NoError(t, s.RotateIfDue())
+	first := s.active
+	assert.NotEqual(t, "static", first.ID)
+	oldToken, _ := s.Issue(Claims{UserID: 1})
+
+	require.NoError(t, s.RotateIfDue())
+	assert.Equal(t, first, s.active, "rotation is not due yet")
+
+	second, err := GenerateKey(EdDSA)
+	require.NoError(t, err)
+	second.CreatedAt = first.CreatedAt.Add(time.Second)
+	require.NoError(t, s.Rotate(second))
+	assert.Equal(t, second.ID, s.active.ID)
+
+	_, err = s.Verify(oldToken)
+	assert.NoError(t, err, "retired key verifies until its tokens expire")
+
+	kids := map[string]bool{}
+	for _, jwk := range s.JWKS().Keys {
+		kids[jwk.Kid] = true
+		assert.Equal(t, "OKP", jwk.Kty)
+	}
+	assert.True(t, kids[first.ID] && kids[second.ID] && kids["static"])
+
+	store.keys[first.ID].RetiresAt = time.Now().Add(-time.Second)
+	_, err = s.Verify(oldToken)
+	assert.Error(t, err)
+}
+
+func TestRotationRetiresStaticKey(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	cfg := testConfig(config.JWTKey{ID: "static", Algorithm: EdDSA, File: writeKey(t, "ed", edKey)})
+	cfg.RotateEvery = time.Hour
+
+	s, err := New(cfg)
+	require.NoError(t, err)
+	store := &memStore{keys: map[string]*Key{}}
+	require.NoError(t, s.UseStore(store))
+	staticToken, _ := s.Issue(Claims{UserID: 1})
+
+	require.NoError(t, s.RotateIfDue())
+	_, err = s.Verify(staticToken)
+	assert.NoError(t, err, "the static key verifies until its tokens expire")
+
+	// An access TTL after the first rotation the static key is gone.
+	for _, key := range store.keys {
+		key.CreatedAt = time.Now().Add(-2 * cfg.AccessTTL)
+	}
+	require.NoError(t, s.reload())
+
+	_, err = s.Verify(staticToken)
+	assert.Error(t, err)
+	for _, jwk := range s.JWKS().Keys {
+		assert.NotEqual(t, "static", jwk.Kid)
+	}
+}
+
+func TestDBKeyStoreSealsPrivateKeys(t *testing.T) {
+	key, err := GenerateKey(EdDSA)
+	require.NoError(t, err)
+	pem, err := key.privatePEM()
+	require.NoError(t, err)
+
+	store := DBKeyStore{Secret: "key-encryption-secret"}
+	sealed, err := store.seal(pem)
+	require.NoError(t, err)
+	assert.NotContains(t, sealed, "PRIVATE KEY")
+
+	opened, err := store.open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, pem, opened)
+
+	_, err = DBKeyStore{Secret: "another-secret"}.open(sealed)
+	assert.Error(t, err)
+	_, err = DBKeyStore{}.open(sealed)
+	assert.Error(t, err)
+
+	plain, err := store.open(string(pem))
+	require.NoError(t, err)
+	assert.Equal(t, pem, plain, "keys stored before a secret was set still load")
+}
+
+func TestRotationNeedsKeyEncryptionSecret(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	cfg := testConfig(config.JWTKey{ID: "static", Algorithm: EdDSA, File: writeKey(t, "ed", edKey)})
+	cfg.RotateEvery = time.Hour
+	assert.Error(t, Init(cfg))
+
+	key, err := GenerateKey(EdDSA)
+	require.NoError(t, err)
+	pem, err := key.privatePEM()
+	require.NoError(t, err)
+	_, err = DBKeyStore{}.seal(pem)
+	assert.Error(t, err)
+}
+
+func TestConcurrentRotationKeepsOneActiveKey(t *testing.T) {
+	database, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	db.DB = database
+	require.NoError(t, db.DB.AutoMigrate(&models.SigningKey{}))
+
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	cfg := testConfig(config.JWTKey{ID: "static", Algorithm: EdDSA, File: writeKey(t, "ed", edKey)})
+	cfg.RotateEvery = time.Hour
+	store := DBKeyStore{Secret: "key-encryption-secret"}
+
+	// two instances that both still sign with the static key
+	first, err := New(cfg)
+	require.NoError(t, err)
+	require.NoError(t, first.UseStore(store))
+	second, err := New(cfg)
+	require.NoError(t, err)
+	require.NoError(t, second.UseStore(store))
+
+	require.NoError(t, first.Rotate(mustGenerate(t)))
+	require.NoError(t, second.Rotate(mustGenerate(t)), "losing the race is not an error")
+	assert.Equal(t, first.active.ID, second.active.ID)
+
+	// and again, from the key they now share
+	require.NoError(t, second.Rotate(mustGenerate(t)))
+	require.NoError(t, first.Rotate(mustGenerate(t)))
+	assert.Equal(t, second.active.ID, first.active.ID)
+
+	var active int64
+	db.DB.Model(&models.SigningKey{}).Where("retires_at IS NULL").Count(&active)
+	assert.EqualValues(t, 1, active)
+}
+
+func mustGenerate(t *testing.T) *Key {
+	key, err := GenerateKey(EdDSA)
+	require.NoError(t, err)
+	return key
+}
+
+func TestJWKSHidesSecrets(t *testing.T) {
+	s, err := New(testConfig(config.JWTKey{ID: "hs", Algorithm: HS256, Secret: "0123456789abcdef0123456789abcdef"}))
+	require.NoError(t, err)
+	assert.Empty(t, s.JWKS().Keys)
+}
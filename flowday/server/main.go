@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
 	"log"
 
+	"flowday/internal/auth"
+	"flowday/internal/config"
 	"flowday/internal/db"
+	"flowday/internal/mailer"
 	"flowday/internal/router"
+	"flowday/internal/services"
+	"flowday/internal/tokens"
 
-	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 )
 
@@ -15,11 +20,27 @@ func main() {
 		log.Println("No .env file found")
 	}
 
+	cfg := config.Load()
+
 	db.Init()
 	db.Migrate()
 
-	r := gin.Default()
-	router.Setup(r)
+	if err := tokens.Init(cfg.JWT); err != nil {
+		log.Fatal("Failed to load JWT keys: ", err)
+	}
+	tokens.Default().StartRotation(context.Background())
+
+	if err := mailer.Init(cfg.Mail); err != nil {
+		log.Fatal("Failed to set up mailer: ", err)
+	}
+	auth.Init(cfg.Auth)
+	services.Init(cfg.Projects)
+	services.StartTrashPurge(context.Background())
+
+	r, err := router.New(cfg.HTTP)
+	if err != nil {
+		log.Fatal("Failed to set up router: ", err)
+	}
 
 	log.Println("Flowday running on :8080")
 	r.Run(":8080")